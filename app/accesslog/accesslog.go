@@ -0,0 +1,178 @@
+package accesslog
+
+import (
+	"encoding/json"
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+	"github.com/spf13/viper"
+	"io"
+	"microservice/app/core"
+	"net/http"
+	"time"
+)
+
+// Sinks of access_log.sink
+const (
+	SinkFile   = "file"
+	SinkStdout = "stdout"
+	SinkKafka  = "kafka"
+)
+
+// Sink receives JSON lines of access log
+type Sink interface {
+	Write(line []byte) error
+}
+
+var (
+	log   core.Logger
+	lines chan []byte
+)
+
+// Init starts writing access log to configured sink (kafka should be initialized before)
+func Init(logger core.Logger) error {
+	if !viper.GetBool("access_log.enabled") {
+		return nil
+	}
+	log = logger
+
+	var sink Sink
+	var err error
+	switch viper.GetString("access_log.sink") {
+	case SinkFile, "":
+		sink, err = NewFileSink(viper.GetString("access_log.file.path"))
+	case SinkStdout:
+		sink = NewStdoutSink()
+	case SinkKafka:
+		sink, err = NewKafkaSink(viper.GetString("access_log.kafka.topic"))
+	default:
+		err = errors.Errorf("unknown access log sink %s", viper.GetString("access_log.sink"))
+	}
+	if err != nil {
+		return err
+	}
+
+	size := viper.GetInt("access_log.buffer")
+	if size <= 0 {
+		size = 1024
+	}
+	lines = make(chan []byte, size)
+	go run(sink, lines)
+	return nil
+}
+
+// run writes lines in background, so slow sink (kafka) does not delay responses
+func run(sink Sink, lines chan []byte) {
+	for line := range lines {
+		if err := sink.Write(line); err != nil {
+			log.WarnWrap(err, "cannot write access log")
+		}
+	}
+}
+
+// GinMW writes access log line after request
+func GinMW(ctx *gin.Context) {
+	if lines == nil {
+		ctx.Next()
+		return
+	}
+
+	start := time.Now()
+	record := newRecord(start, ctx.Request.Method, ctx.Request.URL.Path)
+	ctx.Request = ctx.Request.WithContext(withRecord(ctx.Request.Context(), record))
+
+	ctx.Next()
+
+	SetRequestId(ctx.Request.Context(), core.RequestId(ctx.Request.Context()))
+	record.write(start, ctx.Writer.Status(), ctx.Request.ContentLength, int64(ctx.Writer.Size()))
+}
+
+// HTTPMiddleware writes access log line of requests which don`t pass gin (native gRPC and gRPC-Web proxy),
+// request id and gRPC code are set by handler
+func HTTPMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if lines == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		start := time.Now()
+		record := newRecord(start, r.Method, r.URL.Path)
+		body := &countingBody{ReadCloser: r.Body}
+		r.Body = body
+		writer := &countingWriter{ResponseWriter: w, status: http.StatusOK}
+
+		next.ServeHTTP(writer, r.WithContext(withRecord(r.Context(), record)))
+
+		record.write(start, writer.status, body.n, writer.n)
+	})
+}
+
+func newRecord(start time.Time, method, path string) *Record {
+	return &Record{entry: Entry{
+		Timestamp: start,
+		Method:    method,
+		Path:      path,
+	}}
+}
+
+// write sends line of finished request to sink
+func (r *Record) write(start time.Time, status int, bytesIn, bytesOut int64) {
+	entry := r.finish()
+	entry.Status = status
+	entry.BytesIn = bytesIn
+	entry.BytesOut = bytesOut
+	if entry.BytesOut < 0 {
+		entry.BytesOut = 0
+	}
+	if entry.BytesIn < 0 {
+		entry.BytesIn = 0 // chunked body
+	}
+	entry.TotalMs = millis(time.Since(start))
+
+	line, err := json.Marshal(entry)
+	if err != nil {
+		log.WarnWrap(err, "cannot marshal access log")
+		return
+	}
+	select {
+	case lines <- line:
+	default:
+		log.Warn("access log buffer is full, line of request %s is dropped", entry.RequestId)
+	}
+}
+
+// countingBody counts bytes of streamed request
+type countingBody struct {
+	io.ReadCloser
+	n int64
+}
+
+func (b *countingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.n += int64(n)
+	return n, err
+}
+
+// countingWriter keeps status and counts bytes of response, gRPC requires http.Flusher
+type countingWriter struct {
+	http.ResponseWriter
+	status int
+	n      int64
+}
+
+func (w *countingWriter) WriteHeader(status int) {
+	w.status = status
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	n, err := w.ResponseWriter.Write(p)
+	w.n += int64(n)
+	return n, err
+}
+
+func (w *countingWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
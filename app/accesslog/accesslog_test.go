@@ -0,0 +1,82 @@
+package accesslog
+
+import (
+	"encoding/json"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestGinMW(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	lines = make(chan []byte, 1)
+	defer func() { lines = nil }()
+
+	engine := gin.New()
+	engine.ContextWithFallback = true // as in rest server
+	engine.Use(GinMW)
+	engine.POST("/test", func(ctx *gin.Context) {
+		SetRoute(ctx, 7, "dbc", "DBCService", "GetCategories")
+		SetUser(ctx, 42)
+		AddAuth(ctx, 2*time.Millisecond)
+		AddUpstream(ctx, 5*time.Millisecond, "OK")
+		AddUpstream(ctx, 3*time.Millisecond, "NotFound")
+		ctx.String(404, "not found")
+	})
+
+	req := httptest.NewRequest(http.MethodPost, "/test", strings.NewReader(`{"id":1}`))
+	engine.ServeHTTP(httptest.NewRecorder(), req)
+
+	var entry Entry
+	require.NoError(t, json.Unmarshal(<-lines, &entry))
+	require.Equal(t, int64(7), entry.RouteId)
+	require.Equal(t, "dbc", entry.Instance)
+	require.Equal(t, "GetCategories", entry.RpcMethod)
+	require.NotNil(t, entry.UserId)
+	require.Equal(t, int32(42), *entry.UserId)
+
+	require.Equal(t, 404, entry.Status)
+	require.Equal(t, "NotFound", entry.GrpcCode)
+	require.Equal(t, int64(8), entry.BytesIn)
+	require.Equal(t, int64(9), entry.BytesOut)
+
+	require.Equal(t, float64(2), entry.AuthMs)
+	require.Equal(t, float64(8), entry.UpstreamMs)
+	require.Greater(t, entry.TotalMs, float64(0))
+}
+
+func TestHTTPMiddleware(t *testing.T) {
+	lines = make(chan []byte, 1)
+	defer func() { lines = nil }()
+
+	handler := HTTPMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		SetRequestId(ctx, "req-1")
+		SetRoute(ctx, 7, "dbc", "DBCService", "GetCategories")
+		SetGrpcCode(ctx, "PermissionDenied")
+		_, _ = io.ReadAll(r.Body)
+		w.Header().Set("Content-Type", "application/grpc")
+		_, _ = w.Write([]byte("12345"))
+		w.(http.Flusher).Flush()
+	}))
+
+	// Streamed body has no content length
+	req := httptest.NewRequest(http.MethodPost, "/pb.DBCService/GetCategories", io.MultiReader(strings.NewReader("abc")))
+	req.ContentLength = -1
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	var entry Entry
+	require.NoError(t, json.Unmarshal(<-lines, &entry))
+	require.Equal(t, "req-1", entry.RequestId)
+	require.Equal(t, "/pb.DBCService/GetCategories", entry.Path)
+	require.Equal(t, int64(7), entry.RouteId)
+	require.Equal(t, 200, entry.Status)
+	require.Equal(t, "PermissionDenied", entry.GrpcCode)
+	require.Equal(t, int64(3), entry.BytesIn)
+	require.Equal(t, int64(5), entry.BytesOut)
+}
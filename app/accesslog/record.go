@@ -0,0 +1,137 @@
+package accesslog
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Entry is one JSON line of access log
+type Entry struct {
+	Timestamp time.Time `json:"timestamp"`
+	RequestId string    `json:"request_id,omitempty"`
+	Method    string    `json:"method"`
+	Path      string    `json:"path"`
+	RouteId   int64     `json:"route_id,omitempty"`
+	Instance  string    `json:"instance,omitempty"`
+	Service   string    `json:"service,omitempty"`
+	RpcMethod string    `json:"rpc_method,omitempty"`
+	UserId    *int32    `json:"user_id,omitempty"`
+	Status    int       `json:"status"`
+	GrpcCode  string    `json:"grpc_code,omitempty"`
+	BytesIn   int64     `json:"bytes_in"`
+	BytesOut  int64     `json:"bytes_out"`
+
+	// Latencies in milliseconds, upstream is a sum of instance calls (composite calls are parallel)
+	AuthMs     float64 `json:"auth_ms"`
+	UpstreamMs float64 `json:"upstream_ms"`
+	TotalMs    float64 `json:"total_ms"`
+}
+
+// Record is filled by use cases during request
+type Record struct {
+	mu       sync.Mutex
+	entry    Entry
+	auth     time.Duration
+	upstream time.Duration
+}
+
+type recordKey struct{}
+
+func withRecord(ctx context.Context, r *Record) context.Context {
+	return context.WithValue(ctx, recordKey{}, r)
+}
+
+func fromContext(ctx context.Context) *Record {
+	if ctx == nil {
+		return nil
+	}
+	r, _ := ctx.Value(recordKey{}).(*Record)
+	return r
+}
+
+// SetRoute sets matched route of request
+func SetRoute(ctx context.Context, id int64, instance, service, method string) {
+	r := fromContext(ctx)
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.entry.RouteId = id
+	r.entry.Instance = instance
+	r.entry.Service = service
+	r.entry.RpcMethod = method
+}
+
+// SetUser sets authorized user of request
+func SetUser(ctx context.Context, userId int32) {
+	r := fromContext(ctx)
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.entry.UserId = &userId
+}
+
+// SetRequestId sets id of request
+func SetRequestId(ctx context.Context, id string) {
+	r := fromContext(ctx)
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.entry.RequestId = id
+}
+
+// SetGrpcCode sets gRPC code of proxied call if no instance call has failed
+func SetGrpcCode(ctx context.Context, code string) {
+	r := fromContext(ctx)
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.entry.GrpcCode == "" || r.entry.GrpcCode == "OK" {
+		r.entry.GrpcCode = code
+	}
+}
+
+// AddAuth adds auth verify latency
+func AddAuth(ctx context.Context, d time.Duration) {
+	r := fromContext(ctx)
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.auth += d
+}
+
+// AddUpstream adds instance call latency, first failed gRPC code is kept
+func AddUpstream(ctx context.Context, d time.Duration, code string) {
+	r := fromContext(ctx)
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.upstream += d
+	if r.entry.GrpcCode == "" || r.entry.GrpcCode == "OK" {
+		r.entry.GrpcCode = code
+	}
+}
+
+func (r *Record) finish() Entry {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.entry.AuthMs = millis(r.auth)
+	r.entry.UpstreamMs = millis(r.upstream)
+	return r.entry
+}
+
+func millis(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
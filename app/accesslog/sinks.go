@@ -0,0 +1,67 @@
+package accesslog
+
+import (
+	"github.com/pkg/errors"
+	"microservice/app/kafka"
+	"os"
+	"path/filepath"
+)
+
+type fileSink struct {
+	file *os.File
+}
+
+// NewFileSink appends lines to file
+func NewFileSink(path string) (Sink, error) {
+	if path == "" {
+		path = "./logs/access.log"
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, errors.Wrapf(err, "cannot create directory of %s", path)
+	}
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot open access log %s", path)
+	}
+	return &fileSink{file: file}, nil
+}
+
+func (s *fileSink) Write(line []byte) error {
+	_, err := s.file.Write(append(line, '\n'))
+	return err
+}
+
+type stdoutSink struct{}
+
+// NewStdoutSink prints lines to stdout
+func NewStdoutSink() Sink {
+	return stdoutSink{}
+}
+
+func (stdoutSink) Write(line []byte) error {
+	_, err := os.Stdout.Write(append(line, '\n'))
+	return err
+}
+
+type kafkaSink struct {
+	topic *kafka.KafkaTopic[[]byte]
+}
+
+// NewKafkaSink produces each line as message of topic
+func NewKafkaSink(topic string) (Sink, error) {
+	if !kafka.Enabled() {
+		return nil, errors.New("kafka is disabled for access log")
+	}
+	if topic == "" {
+		return nil, errors.New("empty kafka topic of access log")
+	}
+	t, err := kafka.Topic[[]byte](topic)
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot create kafka topic %s for access log", topic)
+	}
+	return &kafkaSink{topic: t}, nil
+}
+
+func (s *kafkaSink) Write(line []byte) error {
+	return s.topic.Produce(line)
+}
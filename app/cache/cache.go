@@ -0,0 +1,55 @@
+package cache
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"github.com/pkg/errors"
+	"github.com/spf13/viper"
+	"time"
+)
+
+// Entry is cached response
+type Entry struct {
+	Body        []byte
+	ContentType string
+	ETag        string
+	StoredAt    time.Time
+	FreshUntil  time.Time
+	StaleUntil  time.Time // entry may be returned while it is refreshed
+}
+
+func (e *Entry) IsFresh(now time.Time) bool {
+	return now.Before(e.FreshUntil)
+}
+
+func (e *Entry) IsExpired(now time.Time) bool {
+	return !now.Before(e.StaleUntil)
+}
+
+// Store keeps entries until StaleUntil
+type Store interface {
+	Get(ctx context.Context, key string) (*Entry, error)
+	Set(ctx context.Context, key string, entry *Entry) error
+
+	// Purge removes entries with prefix for which match returns true (nil - all)
+	Purge(ctx context.Context, prefix string, match func(key string) bool) (int, error)
+}
+
+func NewStore() (Store, error) {
+	driver := viper.GetString("cache.store")
+	switch driver {
+	case "", "memory":
+		return NewMemoryStore(viper.GetInt("cache.memory.max_entries")), nil
+	case "storage":
+		return NewStorageStore(viper.GetUint64("cache.storage.max_value_size"))
+	default:
+		return nil, errors.Errorf("unknown cache store %s", driver)
+	}
+}
+
+// ETag is a strong validator of response body
+func ETag(body []byte) string {
+	sum := sha256.Sum256(body)
+	return `"` + hex.EncodeToString(sum[:16]) + `"`
+}
@@ -0,0 +1,77 @@
+package cache
+
+import (
+	"context"
+	"github.com/stretchr/testify/require"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testEntry(body string, fresh, stale time.Duration) *Entry {
+	now := time.Now()
+	return &Entry{
+		Body:       []byte(body),
+		ETag:       ETag([]byte(body)),
+		StoredAt:   now,
+		FreshUntil: now.Add(fresh),
+		StaleUntil: now.Add(stale),
+	}
+}
+
+func TestMemoryStoreLRU(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore(2)
+
+	require.NoError(t, store.Set(ctx, "a", testEntry("a", time.Minute, time.Minute)))
+	require.NoError(t, store.Set(ctx, "b", testEntry("b", time.Minute, time.Minute)))
+
+	// "a" is used, so "b" is evicted
+	entry, err := store.Get(ctx, "a")
+	require.NoError(t, err)
+	require.Equal(t, "a", string(entry.Body))
+	require.NoError(t, store.Set(ctx, "c", testEntry("c", time.Minute, time.Minute)))
+
+	entry, err = store.Get(ctx, "b")
+	require.NoError(t, err)
+	require.Nil(t, entry)
+
+	entry, err = store.Get(ctx, "c")
+	require.NoError(t, err)
+	require.NotNil(t, entry)
+}
+
+func TestMemoryStoreExpiration(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore(10)
+
+	// Stale entry is still returned
+	require.NoError(t, store.Set(ctx, "stale", testEntry("stale", -time.Second, time.Minute)))
+	entry, err := store.Get(ctx, "stale")
+	require.NoError(t, err)
+	require.False(t, entry.IsFresh(time.Now()))
+
+	require.NoError(t, store.Set(ctx, "expired", testEntry("expired", -time.Second, -time.Second)))
+	entry, err = store.Get(ctx, "expired")
+	require.NoError(t, err)
+	require.Nil(t, entry)
+}
+
+func TestMemoryStorePurge(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore(10)
+
+	for _, key := range []string{"1:/a", "1:/b", "2:/a"} {
+		require.NoError(t, store.Set(ctx, key, testEntry(key, time.Minute, time.Minute)))
+	}
+
+	count, err := store.Purge(ctx, "", func(key string) bool {
+		return strings.HasSuffix(key, "/a")
+	})
+	require.NoError(t, err)
+	require.Equal(t, 2, count)
+
+	count, err = store.Purge(ctx, "1:", nil)
+	require.NoError(t, err)
+	require.Equal(t, 1, count)
+}
@@ -0,0 +1,61 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"git.mills.io/prologic/bitcask"
+	"github.com/pkg/errors"
+	"microservice/app"
+	"time"
+)
+
+// StorageStore keeps entries in app.Storage (bitcask), they survive restarts
+type StorageStore struct {
+	storage *app.Storage
+}
+
+func NewStorageStore(maxValueSize uint64) (*StorageStore, error) {
+	var opts []bitcask.Option
+	if maxValueSize > 0 {
+		opts = append(opts, bitcask.WithMaxValueSize(maxValueSize))
+	}
+	storage, err := app.NewStorage("cache", opts...)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot open cache storage")
+	}
+	return &StorageStore{storage: storage}, nil
+}
+
+func (s *StorageStore) Get(_ context.Context, key string) (*Entry, error) {
+	value, err := s.storage.GetBytes(key)
+	if err != nil || value == nil {
+		return nil, err
+	}
+
+	entry := &Entry{}
+	if err := json.Unmarshal(value, entry); err != nil {
+		return nil, errors.Wrapf(err, "cannot decode cache entry %s", key)
+	}
+	if entry.IsExpired(time.Now()) {
+		return nil, nil
+	}
+	return entry, nil
+}
+
+func (s *StorageStore) Set(_ context.Context, key string, entry *Entry) error {
+	ttl := time.Until(entry.StaleUntil)
+	if ttl <= 0 {
+		return nil
+	}
+	value, err := json.Marshal(entry)
+	if err != nil {
+		return errors.Wrapf(err, "cannot encode cache entry %s", key)
+	}
+	return s.storage.PutBytesWithTTL(key, value, ttl)
+}
+
+func (s *StorageStore) Purge(_ context.Context, prefix string, match func(key string) bool) (int, error) {
+	return s.storage.DeleteIf(prefix, func(key string) bool {
+		return match == nil || match(key)
+	})
+}
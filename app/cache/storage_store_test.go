@@ -0,0 +1,42 @@
+package cache
+
+import (
+	"context"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestStorageStoreTTL(t *testing.T) {
+	ctx := context.Background()
+	viper.Set("storage.path", t.TempDir())
+	defer viper.Set("storage.path", nil)
+
+	store, err := NewStorageStore(0)
+	require.NoError(t, err)
+
+	// Stale entry is kept until StaleUntil
+	require.NoError(t, store.Set(ctx, "1:/a", testEntry("a", -time.Second, time.Minute)))
+	entry, err := store.Get(ctx, "1:/a")
+	require.NoError(t, err)
+	require.Equal(t, "a", string(entry.Body))
+	require.False(t, entry.IsFresh(time.Now()))
+
+	require.NoError(t, store.Set(ctx, "1:/b", testEntry("b", -time.Second, -time.Second)))
+	entry, err = store.Get(ctx, "1:/b")
+	require.NoError(t, err)
+	require.Nil(t, entry)
+
+	require.NoError(t, store.Set(ctx, "1:/c", testEntry("c", 50*time.Millisecond, 100*time.Millisecond)))
+	time.Sleep(150 * time.Millisecond)
+	entry, err = store.Get(ctx, "1:/c")
+	require.NoError(t, err)
+	require.Nil(t, entry)
+
+	_, err = store.Purge(ctx, "1:", nil)
+	require.NoError(t, err)
+	entry, err = store.Get(ctx, "1:/a")
+	require.NoError(t, err)
+	require.Nil(t, entry)
+}
@@ -1,5 +1,10 @@
 package core
 
+import (
+	"crypto/sha256"
+	"encoding/hex"
+)
+
 type AccessRole int32
 
 const (
@@ -7,3 +12,9 @@ const (
 	RoleUser       AccessRole = 1
 	RoleSuperAdmin AccessRole = 10
 )
+
+// TokenHash identifies token in logs without exposing it
+func TokenHash(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:6])
+}
@@ -1,5 +1,10 @@
 package core
 
+import "context"
+
+// Fields are structured values of log line
+type Fields map[string]interface{}
+
 type Logger interface {
 	Debug(msg string, args ...interface{})
 	Warn(msg string, args ...interface{})
@@ -12,4 +17,10 @@ type Logger interface {
 	InfoWrap(err error, msg string, args ...interface{})
 	ErrorWrap(err error, msg string, args ...interface{})
 	FatalWrap(err error, msg string, args ...interface{})
+
+	// With returns logger which adds fields to each line
+	With(fields Fields) Logger
+
+	// WithContext returns logger which adds request id of ctx to each line
+	WithContext(ctx context.Context) Logger
 }
@@ -0,0 +1,52 @@
+package core
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+)
+
+// RequestIdHeader is accepted from client, echoed in response and passed to instances
+const RequestIdHeader = "X-Request-ID"
+
+// RequestIdField is a log field of request id
+const RequestIdField = "request_id"
+
+type requestIdKey struct{}
+
+// WithRequestId stores request id in ctx
+func WithRequestId(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIdKey{}, id)
+}
+
+// RequestId returns request id of ctx or empty string
+func RequestId(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	id, _ := ctx.Value(requestIdKey{}).(string)
+	return id
+}
+
+// NewRequestId generates random UUID v4
+func NewRequestId() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
+
+// AcceptRequestId returns client`s id or new one if client`s id is not
+// short printable ASCII (it goes to logs and metadata)
+func AcceptRequestId(id string) string {
+	if id == "" || len(id) > 128 {
+		return NewRequestId()
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] < 0x21 || id[i] > 0x7e {
+			return NewRequestId()
+		}
+	}
+	return id
+}
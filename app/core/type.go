@@ -6,11 +6,24 @@ const (
 	NotFound        = "not_found"
 	ValidationError = "validation_error"
 	Unauthorised    = "unauthorised"
+	TooManyRequests = "too_many_requests"
+	Unavailable     = "unavailable"
+
+	PayloadTooLarge      = "payload_too_large"
+	UnsupportedMediaType = "unsupported_media_type"
 )
 
 type Status struct {
 	Code    string `json:"code"`
 	Message string `json:"message"`
+
+	// Incorrect fields of validation_error
+	Errors []FieldError `json:"errors,omitempty"`
+}
+
+type FieldError struct {
+	Path    string `json:"path"`
+	Message string `json:"message"`
 }
 
 type StatusResponse struct {
@@ -3,6 +3,7 @@ package app
 import (
 	"errors"
 	"reflect"
+	"strings"
 )
 
 type ServerError struct {
@@ -18,3 +19,46 @@ func UCaseError(code string) ServerError {
 func IsServerError(err error) bool {
 	return reflect.TypeOf(err) == reflect.TypeOf(ServerError{})
 }
+
+// ValidationError is an incorrect client`s input (Path is a field path)
+type ValidationError struct {
+	Path    string
+	Message string
+}
+
+func (e ValidationError) Error() string {
+	if e.Path == "" {
+		return e.Message
+	}
+	return e.Path + ": " + e.Message
+}
+
+// ValidationErrors are all incorrect fields of client`s input
+type ValidationErrors []ValidationError
+
+func (e ValidationErrors) Error() string {
+	msgs := make([]string, len(e))
+	for i, err := range e {
+		msgs[i] = err.Error()
+	}
+	return strings.Join(msgs, "; ")
+}
+
+func IsValidationError(err error) bool {
+	var v ValidationError
+	var list ValidationErrors
+	return errors.As(err, &v) || errors.As(err, &list)
+}
+
+// ValidationFields returns each incorrect field of validation error
+func ValidationFields(err error) []ValidationError {
+	var list ValidationErrors
+	if errors.As(err, &list) {
+		return list
+	}
+	var v ValidationError
+	if errors.As(err, &v) {
+		return []ValidationError{v}
+	}
+	return nil
+}
@@ -13,8 +13,10 @@ import (
 	"google.golang.org/grpc/metadata"
 	"google.golang.org/grpc/reflection"
 	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
 	"io/ioutil"
 	"net"
+	"net/http"
 	"strconv"
 )
 
@@ -31,34 +33,14 @@ func InitGRPCServer() (*grpc.Server, *runtime.ServeMux, error) {
 	// TSL
 	tslEnable := viper.GetString("app.grpc.tsl") == "true"
 	if tslEnable {
-		crt := "./cert/service.pem"
-		key := "./cert/service.key"
-		caN := "./cert/ca.cert"
-
-		// Load the certificates from disk
-		certificate, err := tls.LoadX509KeyPair(crt, key)
-		if err != nil {
-			return nil, nil, errors.New("cannot initialize GRPC Server")
-		}
-
-		// CreateIfNotExists a certificate pool from the certificate authority
-		certPool := x509.NewCertPool()
-		ca, err := ioutil.ReadFile(caN)
+		creds, err := loadServerTLS(
+			viper.GetString("app.grpc.cert"),
+			viper.GetString("app.grpc.key"),
+			viper.GetString("app.grpc.ca"),
+		)
 		if err != nil {
-			return nil, nil, errors.New("cannot initialize GRPC Server")
+			return nil, nil, errors.Wrap(err, "cannot initialize GRPC Server")
 		}
-
-		// Append the client certificates from the CA
-		if ok := certPool.AppendCertsFromPEM(ca); !ok {
-			return nil, nil, errors.New("failed to append client certs")
-		}
-
-		// CreateIfNotExists the TLS credentials
-		creds := credentials.NewTLS(&tls.Config{
-			ClientAuth:   tls.RequireAndVerifyClientCert,
-			Certificates: []tls.Certificate{certificate},
-			ClientCAs:    certPool,
-		})
 		options = append(options, grpc.Creds(creds))
 	}
 
@@ -73,11 +55,61 @@ func InitGRPCServer() (*grpc.Server, *runtime.ServeMux, error) {
 		return nil, nil, errors.New("cannot initialize GRPC Server")
 	}
 
-	grpcMux = runtime.NewServeMux()
+	// REST for annotated services, JSON is the same as in instances (snake_case)
+	grpcMux = runtime.NewServeMux(
+		runtime.WithMarshalerOption(runtime.MIMEWildcard, &runtime.JSONPb{
+			MarshalOptions: protojson.MarshalOptions{
+				UseProtoNames:   true,
+				EmitUnpopulated: true,
+			},
+			UnmarshalOptions: protojson.UnmarshalOptions{
+				DiscardUnknown: true,
+			},
+		}),
+		runtime.WithErrorHandler(gatewayErrorLogging),
+	)
 
 	return grpcServer, grpcMux, nil
 }
 
+// loadServerTLS makes mTLS credentials: clients should have certificate signed by CA
+func loadServerTLS(crt, key, caN string) (credentials.TransportCredentials, error) {
+	if crt == "" {
+		crt = "./cert/service.pem"
+	}
+	if key == "" {
+		key = "./cert/service.key"
+	}
+	if caN == "" {
+		caN = "./cert/ca.cert"
+	}
+
+	// Load the certificates from disk
+	certificate, err := tls.LoadX509KeyPair(crt, key)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot load server certificate")
+	}
+
+	// CreateIfNotExists a certificate pool from the certificate authority
+	certPool := x509.NewCertPool()
+	ca, err := ioutil.ReadFile(caN)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot read CA certificate")
+	}
+
+	// Append the client certificates from the CA
+	if ok := certPool.AppendCertsFromPEM(ca); !ok {
+		return nil, errors.New("failed to append client certs")
+	}
+
+	// CreateIfNotExists the TLS credentials
+	return credentials.NewTLS(&tls.Config{
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		Certificates: []tls.Certificate{certificate},
+		ClientCAs:    certPool,
+	}), nil
+}
+
 func RunGRPCServer() {
 
 	gRPCPort := viper.GetString("app.grpc.port")
@@ -115,6 +147,21 @@ func InitGRPCService[T any](s func(grpc.ServiceRegistrar, T), src T) {
 	s(grpcServer, src)
 }
 
+// InitGRPCGateway registers REST handlers (google.api.http) calling src in process
+func InitGRPCGateway[T any](s func(context.Context, *runtime.ServeMux, T) error, src T) error {
+	return s(context.Background(), grpcMux, src)
+}
+
+// InitGRPCGatewayPath serves custom handler on REST mux (not a gRPC method)
+func InitGRPCGatewayPath(method, path string, h runtime.HandlerFunc) error {
+	return grpcMux.HandlePath(method, path, h)
+}
+
+// GRPCMux is REST handler for services registered with InitGRPCGateway
+func GRPCMux() *runtime.ServeMux {
+	return grpcMux
+}
+
 // Logging interceptor
 
 func errorLogging(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp interface{}, err error) {
@@ -123,6 +170,10 @@ func errorLogging(ctx context.Context, req interface{}, info *grpc.UnaryServerIn
 
 	// Log if error
 	if err != nil {
+		// Statuses are answers for client (auth etc.)
+		if _, ok := err.(interface{ GRPCStatus() *status.Status }); ok {
+			return h, err
+		}
 		log.Error("%v", err)
 		return h, status.Error(codes.Internal, err.Error())
 	}
@@ -130,6 +181,15 @@ func errorLogging(ctx context.Context, req interface{}, info *grpc.UnaryServerIn
 	return h, nil
 }
 
+// gatewayErrorLogging is errorLogging for REST calls (they don`t pass interceptors)
+func gatewayErrorLogging(ctx context.Context, mux *runtime.ServeMux, m runtime.Marshaler, w http.ResponseWriter, r *http.Request, err error) {
+	if _, ok := status.FromError(err); !ok {
+		log.Error("%v", err)
+		err = status.Error(codes.Internal, err.Error())
+	}
+	runtime.DefaultHTTPErrorHandler(ctx, mux, m, w, r, err)
+}
+
 // Tools
 
 func ExtractRequestUserId(ctx context.Context) (int32, error) {
@@ -0,0 +1,41 @@
+package app
+
+import (
+	"github.com/pkg/errors"
+	"google.golang.org/grpc/encoding"
+)
+
+// RawFrame is a gRPC message which is forwarded without decoding
+type RawFrame struct {
+	Data []byte
+}
+
+type rawCodec struct{}
+
+// RawCodec passes RawFrame bytes as is
+func RawCodec() encoding.Codec {
+	return &rawCodec{}
+}
+
+func (*rawCodec) Marshal(v interface{}) ([]byte, error) {
+	frame, ok := v.(*RawFrame)
+	if !ok {
+		return nil, errors.Errorf("raw codec cannot marshal %T", v)
+	}
+	return frame.Data, nil
+}
+
+func (*rawCodec) Unmarshal(data []byte, v interface{}) error {
+	frame, ok := v.(*RawFrame)
+	if !ok {
+		return errors.Errorf("raw codec cannot unmarshal to %T", v)
+	}
+	// Fresh copy: marshalled data of previous message may be still queued by transport
+	frame.Data = append([]byte(nil), data...)
+	return nil
+}
+
+// Name is the same as proto codec, so content-type is application/grpc+proto
+func (*rawCodec) Name() string {
+	return "proto"
+}
@@ -4,10 +4,12 @@ import (
 	"github.com/jasonlvhit/gocron"
 	"github.com/spf13/viper"
 	"go.uber.org/dig"
+	"microservice/app/metrics"
 	"reflect"
 	"runtime"
 	"strconv"
 	"strings"
+	"time"
 )
 
 func NewJob(job interface{}, period *gocron.Job) {
@@ -20,8 +22,16 @@ func NewJob(job interface{}, period *gocron.Job) {
 		log.FatalWrap(err, "cannot initialize new job")
 	}
 
+	// Short name for metrics (package.Constructor)
+	label := name[strings.LastIndex(name, "/")+1:]
+
 	err = scope.Invoke(func(j Job) {
-		err := period.Do(j.Run)
+		err := period.Do(func() error {
+			start := time.Now()
+			err := j.Run()
+			metrics.JobDuration.WithLabelValues(label, metrics.Result(err)).Observe(metrics.Since(start))
+			return err
+		})
 		if err != nil {
 			log.Fatal("cannot DO cron %s", name)
 		}
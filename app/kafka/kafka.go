@@ -6,6 +6,7 @@ import (
 	"github.com/spf13/viper"
 	"microservice/app"
 	"microservice/app/core"
+	"microservice/app/metrics"
 	"path"
 )
 
@@ -61,6 +62,11 @@ func InitKafka(l core.Logger) error {
 	return nil
 }
 
+// Enabled is true when kafka is initialized
+func Enabled() bool {
+	return k != nil
+}
+
 type KafkaTopic[T any] struct {
 	topic        string
 	encoder      Encoder
@@ -115,6 +121,7 @@ func (t *KafkaTopic[T]) Produce(obj T) error {
 	}
 
 	_, offset, err := k.producer.SendMessage(message)
+	metrics.KafkaProduced.WithLabelValues(t.topic, metrics.Result(err)).Inc()
 	if err != nil {
 		return err
 	}
@@ -153,6 +160,7 @@ func (t *KafkaTopic[T]) StartPolling() (chan *Message[T], error) {
 				Details: message,
 			}
 			err := t.encoder.Encode(message.Value, &msg.Value)
+			metrics.KafkaConsumed.WithLabelValues(t.topic, metrics.Result(err)).Inc()
 			if err != nil {
 				//e have incorrect format - skip it
 				logger.ErrorWrap(err, "cannot encode kafka message to receiver type")
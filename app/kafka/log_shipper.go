@@ -0,0 +1,31 @@
+package kafka
+
+import (
+	"github.com/pkg/errors"
+	"microservice/app/logs_hooks"
+)
+
+// LogShipper produces error log entries to topic
+type LogShipper struct {
+	topic *KafkaTopic[*logs_hooks.ShippedEntry]
+}
+
+func NewLogShipper(topic string) (logs_hooks.Shipper, error) {
+	if !Enabled() {
+		return nil, errors.New("kafka is disabled for logs shipping")
+	}
+	t, err := Topic[*logs_hooks.ShippedEntry](topic)
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot create kafka topic %s for logs", topic)
+	}
+	return &LogShipper{topic: t}, nil
+}
+
+func (s *LogShipper) Ship(entries []*logs_hooks.ShippedEntry) error {
+	for _, entry := range entries {
+		if err := s.topic.Produce(entry); err != nil {
+			return err
+		}
+	}
+	return nil
+}
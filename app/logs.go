@@ -1,6 +1,7 @@
 package app
 
 import (
+	"context"
 	"fmt"
 	"github.com/pkg/errors"
 	logrus "github.com/sirupsen/logrus"
@@ -8,11 +9,26 @@ import (
 	"microservice/app/core"
 	"microservice/app/logs_hooks"
 	"os"
+	"os/signal"
 	"path"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
 )
 
 var (
 	log core.Logger
+
+	// logFiles are files of hooks, they are reopened on SIGHUP by single goroutine
+	logFiles   []*logs_hooks.RotatingFile
+	logFilesMu sync.Mutex
+	reopenOnce sync.Once
+
+	// shipHook sends errors to alerting sink (see InitLogShipping)
+	shipHook *logs_hooks.ShipHook
 )
 
 func InitLogs(rootDir ...string) (core.Logger, error) {
@@ -30,80 +46,308 @@ func InitLogs(rootDir ...string) (core.Logger, error) {
 		}
 	}
 
-	//log.SetFormatter(&easy.Formatter{
-	//	TimestampFormat: "2006-01-02 15:04:05",
-	//	LogFormat:       "[%lvl%]: %time% - %msg%\n",
-	//})
-
 	logrusLogger := logrus.New()
-	logrusLogger.SetFormatter(&logrus.TextFormatter{
-		ForceColors:      true,
-		DisableTimestamp: true,
-	})
-	logrusLogger.SetReportCaller(true)
+	formatter, err := logFormatter(viper.GetString("log.format"))
+	if err != nil {
+		return nil, err
+	}
+	logrusLogger.SetFormatter(formatter)
+
+	level := logrus.InfoLevel
 	if viper.GetString("app.debug") == "true" {
-		logrusLogger.SetLevel(logrus.TraceLevel)
-	} else {
-		logrusLogger.SetLevel(logrus.InfoLevel)
+		level = logrus.TraceLevel
+	}
+	if name := viper.GetString("log.level"); name != "" {
+		if level, err = logrus.ParseLevel(name); err != nil {
+			return nil, errors.Wrap(err, "invalid log.level")
+		}
+	}
+	levels, err := packageLevels(viper.GetStringMapString("log.levels"))
+	if err != nil {
+		return nil, err
 	}
 
+	// logrus passes lines of the most verbose level, DefaultLogger filters them by package
+	minLevel := level
+	for _, l := range levels {
+		if l > minLevel {
+			minLevel = l
+		}
+	}
+	logrusLogger.SetLevel(minLevel)
+
 	// hooks
-	logrusLogger.AddHook(logs_hooks.NewToFileHook(basePath))
-	logrusLogger.AddHook(logs_hooks.NewToFileErrorHook(basePath))
+	redactHook, err := logs_hooks.NewRedactHook(
+		viper.GetStringSlice("log.redact.keys"),
+		viper.GetStringSlice("log.redact.patterns"),
+	)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot init logs redaction")
+	}
+	logrusLogger.AddHook(redactHook)
 
-	log = NewDefaultLogger(logrusLogger)
+	rotate := logs_hooks.RotateOptions{
+		MaxSize:  viper.GetInt64("log.rotate.max_size_mb") * 1024 * 1024,
+		Interval: viper.GetDuration("log.rotate.interval"),
+		MaxAge:   viper.GetDuration("log.rotate.max_age"),
+		MaxCount: viper.GetInt("log.rotate.max_count"),
+		Compress: viper.GetBool("log.rotate.compress"),
+	}
+	allHook, err := logs_hooks.NewToFileHook(basePath, rotate)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot init all logs file")
+	}
+	errorHook, err := logs_hooks.NewToFileErrorHook(basePath, rotate)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot init errors logs file")
+	}
+	logrusLogger.AddHook(allHook)
+	logrusLogger.AddHook(errorHook)
+	logFilesMu.Lock()
+	logFiles = []*logs_hooks.RotatingFile{allHook.File(), errorHook.File()}
+	logFilesMu.Unlock()
+	reopenOnce.Do(func() { go reopenLogsOnHup() })
+
+	log = &DefaultLogger{
+		logger: logrus.NewEntry(logrusLogger),
+		level:  level,
+		levels: levels,
+	}
 	return log, nil
 }
 
+// reopenLogsOnHup reopens log files on SIGHUP (after they are moved by logrotate)
+func reopenLogsOnHup() {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	for range hup {
+		logFilesMu.Lock()
+		files := logFiles
+		logFilesMu.Unlock()
+		for _, file := range files {
+			if err := file.Reopen(); err != nil {
+				fmt.Printf("Cannot reopen log file. %s\n", err.Error())
+			}
+		}
+	}
+}
+
+// InitLogShipping forwards errors to log.ship.sink: kafka, webhook or syslog.
+// It is called after kafka is initialized, so kafka shipper is passed by bootstrap
+func InitLogShipping(kafkaShipper func(topic string) (logs_hooks.Shipper, error)) error {
+	if !viper.GetBool("log.ship.enabled") {
+		return nil
+	}
+	logger, ok := log.(*DefaultLogger)
+	if !ok {
+		return errors.New("logs are not initialized")
+	}
+
+	var shipper logs_hooks.Shipper
+	var err error
+	switch sink := viper.GetString("log.ship.sink"); sink {
+	case "kafka":
+		shipper, err = kafkaShipper(viper.GetString("log.ship.kafka.topic"))
+	case "webhook":
+		shipper, err = logs_hooks.NewWebhookShipper(
+			viper.GetString("log.ship.webhook.url"),
+			viper.GetDuration("log.ship.webhook.timeout"),
+		)
+	case "syslog":
+		shipper, err = logs_hooks.NewSyslogShipper(
+			viper.GetString("log.ship.syslog.network"),
+			viper.GetString("log.ship.syslog.address"),
+			viper.GetString("log.ship.syslog.tag"),
+		)
+	default:
+		err = errors.Errorf("unknown logs shipping sink %s", sink)
+	}
+	if err != nil {
+		return errors.Wrap(err, "cannot init logs shipping")
+	}
+
+	shipHook = logs_hooks.NewShipHook(shipper, logs_hooks.ShipOptions{
+		Buffer:        viper.GetInt("log.ship.buffer"),
+		BatchSize:     viper.GetInt("log.ship.batch_size"),
+		FlushInterval: viper.GetDuration("log.ship.flush_interval"),
+		RateLimit:     viper.GetInt("log.ship.rate_limit"),
+		DedupeWindow:  viper.GetDuration("log.ship.dedupe_window"),
+	})
+	logger.logger.Logger.AddHook(shipHook)
+	return nil
+}
+
+// CloseLogs ships pending errors and closes log files (waits for compression of rotated ones)
+func CloseLogs() {
+	if shipHook != nil {
+		shipHook.Flush(5 * time.Second)
+	}
+	for _, file := range logFiles {
+		_ = file.Close()
+	}
+}
+
+// logFormatter returns formatter of log.format: text (colored), json or logfmt
+func logFormatter(format string) (logrus.Formatter, error) {
+	switch format {
+	case "", "text":
+		return &logrus.TextFormatter{
+			ForceColors:      true,
+			DisableTimestamp: true,
+		}, nil
+	case "json":
+		return &logrus.JSONFormatter{
+			TimestampFormat: time.RFC3339Nano,
+		}, nil
+	case "logfmt":
+		return &logrus.TextFormatter{
+			DisableColors:   true,
+			FullTimestamp:   true,
+			TimestampFormat: time.RFC3339Nano,
+		}, nil
+	default:
+		return nil, errors.Errorf("unknown log format %s", format)
+	}
+}
+
+// packageLevels parses log.levels (package name -> level, e.g. services: debug)
+func packageLevels(names map[string]string) (map[string]logrus.Level, error) {
+	if len(names) == 0 {
+		return nil, nil
+	}
+	levels := make(map[string]logrus.Level, len(names))
+	for pkg, name := range names {
+		level, err := logrus.ParseLevel(name)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid log level of package %s", pkg)
+		}
+		levels[pkg] = level
+	}
+	return levels, nil
+}
+
 type DefaultLogger struct {
-	logger *logrus.Logger
+	logger *logrus.Entry
+
+	// level is default, levels are by package name of caller
+	level  logrus.Level
+	levels map[string]logrus.Level
 }
 
 func NewDefaultLogger(logger *logrus.Logger) *DefaultLogger {
-	return &DefaultLogger{logger: logger}
+	return &DefaultLogger{
+		logger: logrus.NewEntry(logger),
+		level:  logger.GetLevel(),
+	}
+}
+
+func (l *DefaultLogger) With(fields core.Fields) core.Logger {
+	return &DefaultLogger{
+		logger: l.logger.WithFields(logrus.Fields(fields)),
+		level:  l.level,
+		levels: l.levels,
+	}
+}
+
+func (l *DefaultLogger) WithContext(ctx context.Context) core.Logger {
+	id := core.RequestId(ctx)
+	if id == "" {
+		return l
+	}
+	return l.With(core.Fields{core.RequestIdField: id})
 }
 
 func (l *DefaultLogger) Debug(msg string, args ...interface{}) {
-	l.logger.Debugf(msg, args...)
+	l.log(logrus.DebugLevel, msg, args...)
 }
 
 func (l *DefaultLogger) Warn(msg string, args ...interface{}) {
-	l.logger.Warnf(msg, args...)
+	l.log(logrus.WarnLevel, msg, args...)
 }
 
 func (l *DefaultLogger) Info(msg string, args ...interface{}) {
-	l.logger.Infof(msg, args...)
+	l.log(logrus.InfoLevel, msg, args...)
 }
 
 func (l *DefaultLogger) Error(msg string, args ...interface{}) {
-	l.logger.Errorf(msg, args...)
+	l.log(logrus.ErrorLevel, msg, args...)
 }
 
 func (l *DefaultLogger) Fatal(msg string, args ...interface{}) {
-	l.logger.Fatalf(msg, args...)
+	l.log(logrus.FatalLevel, msg, args...)
 }
 
 func (l *DefaultLogger) DebugWrap(err error, msg string, args ...interface{}) {
-	msg = fmt.Sprintf(msg, args...)
-	l.logger.Debugf("%s: %s", msg, err.Error())
+	l.log(logrus.DebugLevel, "%s: %s", fmt.Sprintf(msg, args...), err.Error())
 }
 
 func (l *DefaultLogger) WarnWrap(err error, msg string, args ...interface{}) {
-	msg = fmt.Sprintf(msg, args...)
-	l.logger.Warnf("%s: %s", msg, err.Error())
+	l.log(logrus.WarnLevel, "%s: %s", fmt.Sprintf(msg, args...), err.Error())
 }
 
 func (l *DefaultLogger) InfoWrap(err error, msg string, args ...interface{}) {
-	msg = fmt.Sprintf(msg, args...)
-	l.logger.Infof("%s: %s", msg, err.Error())
+	l.log(logrus.InfoLevel, "%s: %s", fmt.Sprintf(msg, args...), err.Error())
 }
 
 func (l *DefaultLogger) ErrorWrap(err error, msg string, args ...interface{}) {
-	msg = fmt.Sprintf(msg, args...)
-	l.logger.Errorf("%s: %s", msg, err.Error())
+	l.log(logrus.ErrorLevel, "%s: %s", fmt.Sprintf(msg, args...), err.Error())
 }
 
 func (l *DefaultLogger) FatalWrap(err error, msg string, args ...interface{}) {
-	msg = fmt.Sprintf(msg, args...)
-	l.logger.Fatalf("%s: %s", msg, err.Error())
+	l.log(logrus.FatalLevel, "%s: %s", fmt.Sprintf(msg, args...), err.Error())
+}
+
+// log writes line if level is enabled for caller`s package. Caller is added as field,
+// because logrus ReportCaller would point to this file
+func (l *DefaultLogger) log(level logrus.Level, msg string, args ...interface{}) {
+	// log <- Debug/Info/... <- caller
+	pc, file, line, ok := runtime.Caller(2)
+	if !l.enabled(level, pc, ok) {
+		return
+	}
+
+	entry := l.logger
+	if ok {
+		entry = entry.WithField(logs_hooks.CallerField, callerLocation(file, line))
+	}
+	if level == logrus.FatalLevel {
+		entry.Fatalf(msg, args...)
+		return
+	}
+	entry.Logf(level, msg, args...)
+}
+
+func (l *DefaultLogger) enabled(level logrus.Level, pc uintptr, ok bool) bool {
+	if len(l.levels) == 0 || !ok {
+		return level <= l.level
+	}
+	if pkgLevel, ok := l.levels[callerPackage(pc)]; ok {
+		return level <= pkgLevel
+	}
+	return level <= l.level
+}
+
+// callerLocation keeps package directory and file name (services/auth_service.go:87)
+func callerLocation(file string, line int) string {
+	dir, name := path.Split(file)
+	return path.Base(dir) + "/" + name + ":" + strconv.Itoa(line)
+}
+
+var callerPackages sync.Map
+
+// callerPackage returns last element of package path (microservice/services.(*AuthService).Verify -> services)
+func callerPackage(pc uintptr) string {
+	if pkg, ok := callerPackages.Load(pc); ok {
+		return pkg.(string)
+	}
+	name := ""
+	if fn := runtime.FuncForPC(pc); fn != nil {
+		name = fn.Name()
+	}
+	name = name[strings.LastIndex(name, "/")+1:]
+	if i := strings.Index(name, "."); i >= 0 {
+		name = name[:i]
+	}
+	callerPackages.Store(pc, name)
+	return name
 }
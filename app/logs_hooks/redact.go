@@ -0,0 +1,70 @@
+package logs_hooks
+
+import (
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"regexp"
+	"strings"
+)
+
+const redacted = "[REDACTED]"
+
+// RedactHook hides sensitive values in message and fields,
+// it should be added first, so next hooks and formatter get redacted entry
+type RedactHook struct {
+	keys     map[string]bool
+	patterns []*regexp.Regexp
+}
+
+// Default keys and patterns are always redacted, config adds own ones to them
+var (
+	defaultRedactKeys     = []string{"token", "authorization", "password", "secret"}
+	defaultRedactPatterns = []string{
+		`eyJ[\w-]+\.[\w-]+\.[\w-]*`, // JWT
+		`(?i)bearer\s+\S+`,
+	}
+)
+
+// NewRedactHook hides values of fields with keys and parts of message matched by patterns
+func NewRedactHook(keys []string, patterns []string) (*RedactHook, error) {
+	keys = append(append([]string{}, defaultRedactKeys...), keys...)
+	patterns = append(append([]string{}, defaultRedactPatterns...), patterns...)
+
+	hook := &RedactHook{keys: make(map[string]bool, len(keys))}
+	for _, key := range keys {
+		hook.keys[strings.ToLower(key)] = true
+	}
+	for _, pattern := range patterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid redact pattern %s: %w", pattern, err)
+		}
+		hook.patterns = append(hook.patterns, re)
+	}
+	return hook, nil
+}
+
+func (hook *RedactHook) Fire(entry *log.Entry) error {
+	for key, value := range entry.Data {
+		if hook.keys[strings.ToLower(key)] {
+			entry.Data[key] = redacted
+			continue
+		}
+		if s, ok := value.(string); ok {
+			entry.Data[key] = hook.redact(s)
+		}
+	}
+	entry.Message = hook.redact(entry.Message)
+	return nil
+}
+
+func (hook *RedactHook) redact(s string) string {
+	for _, re := range hook.patterns {
+		s = re.ReplaceAllString(s, redacted)
+	}
+	return s
+}
+
+func (hook *RedactHook) Levels() []log.Level {
+	return log.AllLevels
+}
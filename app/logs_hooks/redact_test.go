@@ -0,0 +1,30 @@
+package logs_hooks
+
+import (
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestRedactHookDefaults(t *testing.T) {
+	hook, err := NewRedactHook([]string{"api_key"}, nil)
+	require.NoError(t, err)
+
+	entry := &log.Entry{
+		Message: "verify Bearer abc and eyJhbGciOiJIUzI1NiJ9.eyJpZCI6MX0.abc",
+		Data: log.Fields{
+			"Authorization": "secret",
+			"token":         "secret",
+			"api_key":       "secret",
+			"token_hash":    "1a2b3c",
+		},
+	}
+	require.NoError(t, hook.Fire(entry))
+	require.Equal(t, "verify [REDACTED] and [REDACTED]", entry.Message)
+	require.Equal(t, log.Fields{
+		"Authorization": "[REDACTED]",
+		"token":         "[REDACTED]",
+		"api_key":       "[REDACTED]",
+		"token_hash":    "1a2b3c",
+	}, entry.Data)
+}
@@ -0,0 +1,247 @@
+package logs_hooks
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	rotatedTimeFormat = "20060102T150405.000"
+	// failed rotation is retried after delay, lines are written to current file meanwhile
+	rotateRetryDelay = time.Minute
+)
+
+// rename is replaced in tests
+var rename = os.Rename
+
+// RotateOptions of RotatingFile, zero values disable each limit
+type RotateOptions struct {
+	MaxSize  int64         // bytes of current file
+	Interval time.Duration // age of current file
+	MaxAge   time.Duration // age of rotated files
+	MaxCount int           // number of rotated files
+	Compress bool          // gzip rotated files
+}
+
+// RotatingFile appends to path and moves it to path-<time>.log when it is too large or old
+type RotatingFile struct {
+	path string
+	opts RotateOptions
+
+	mu       sync.Mutex
+	file     *os.File
+	size     int64
+	openedAt time.Time
+	failedAt time.Time
+
+	// rotated files are compressed and removed in background one by one
+	mill sync.Mutex
+	wg   sync.WaitGroup
+}
+
+func NewRotatingFile(path string, opts RotateOptions) (*RotatingFile, error) {
+	f := &RotatingFile{path: path, opts: opts}
+	if err := f.open(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+func (f *RotatingFile) open() error {
+	file, err := os.OpenFile(f.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("cannot open log file %s: %w", f.path, err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		_ = file.Close()
+		return fmt.Errorf("cannot stat log file %s: %w", f.path, err)
+	}
+	f.file = file
+	f.size = info.Size()
+	f.openedAt = time.Now()
+	return nil
+}
+
+func (f *RotatingFile) Write(p []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.file == nil {
+		return 0, os.ErrClosed
+	}
+	if f.shouldRotate(int64(len(p))) && time.Since(f.failedAt) >= rotateRetryDelay {
+		if err := f.rotate(); err != nil {
+			f.failedAt = time.Now()
+			fmt.Printf("Cannot rotate log file, writing to current one. %s\n", err.Error())
+		}
+	}
+	n, err := f.file.Write(p)
+	f.size += int64(n)
+	return n, err
+}
+
+func (f *RotatingFile) shouldRotate(next int64) bool {
+	if f.size == 0 {
+		return false
+	}
+	if f.opts.MaxSize > 0 && f.size+next > f.opts.MaxSize {
+		return true
+	}
+	return f.opts.Interval > 0 && time.Since(f.openedAt) >= f.opts.Interval
+}
+
+// Rotate moves current file aside and opens new one
+func (f *RotatingFile) Rotate() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.file == nil {
+		return os.ErrClosed
+	}
+	return f.rotate()
+}
+
+// rotate renames file while it is open, so on failure current file stays usable
+func (f *RotatingFile) rotate() error {
+	ext := filepath.Ext(f.path)
+	rotated := strings.TrimSuffix(f.path, ext) + "-" + time.Now().Format(rotatedTimeFormat) + ext
+	if err := rename(f.path, rotated); err != nil {
+		return fmt.Errorf("cannot rotate log file %s: %w", f.path, err)
+	}
+
+	current := f.file
+	if err := f.open(); err != nil {
+		// keep writing to rotated file rather than losing lines
+		return err
+	}
+	if err := current.Close(); err != nil {
+		fmt.Printf("Cannot close rotated log file %s. %s\n", rotated, err.Error())
+	}
+
+	f.wg.Add(1)
+	go func() {
+		defer f.wg.Done()
+		f.millRotated(rotated)
+	}()
+	return nil
+}
+
+// Reopen closes and opens file by path again (after external logrotate, on SIGHUP)
+func (f *RotatingFile) Reopen() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.file != nil {
+		_ = f.file.Close()
+		f.file = nil
+	}
+	return f.open()
+}
+
+// Close closes file and waits for background compression
+func (f *RotatingFile) Close() error {
+	f.mu.Lock()
+	var err error
+	if f.file != nil {
+		err = f.file.Close()
+		f.file = nil
+	}
+	f.mu.Unlock()
+
+	f.wg.Wait()
+	return err
+}
+
+func (f *RotatingFile) millRotated(rotated string) {
+	f.mill.Lock()
+	defer f.mill.Unlock()
+
+	if f.opts.Compress {
+		if err := compressFile(rotated); err != nil {
+			fmt.Printf("Cannot compress rotated log file %s. %s\n", rotated, err.Error())
+		}
+	}
+	if err := f.removeOld(); err != nil {
+		fmt.Printf("Cannot remove old log files of %s. %s\n", f.path, err.Error())
+	}
+}
+
+// removeOld applies MaxCount and MaxAge to rotated files
+func (f *RotatingFile) removeOld() error {
+	if f.opts.MaxCount <= 0 && f.opts.MaxAge <= 0 {
+		return nil
+	}
+
+	ext := filepath.Ext(f.path)
+	prefix := filepath.Base(strings.TrimSuffix(f.path, ext)) + "-"
+	entries, err := os.ReadDir(filepath.Dir(f.path))
+	if err != nil {
+		return err
+	}
+
+	// Names have sortable time, newest are first
+	var rotated []os.DirEntry
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasPrefix(name, prefix) {
+			continue
+		}
+		stamp := strings.TrimSuffix(strings.TrimSuffix(name, ".gz"), ext)
+		if _, err := time.Parse(rotatedTimeFormat, strings.TrimPrefix(stamp, prefix)); err != nil {
+			continue
+		}
+		rotated = append(rotated, entry)
+	}
+	sort.Slice(rotated, func(i, j int) bool {
+		return rotated[i].Name() > rotated[j].Name()
+	})
+
+	for i, entry := range rotated {
+		remove := f.opts.MaxCount > 0 && i >= f.opts.MaxCount
+		if !remove && f.opts.MaxAge > 0 {
+			if info, err := entry.Info(); err == nil && time.Since(info.ModTime()) > f.opts.MaxAge {
+				remove = true
+			}
+		}
+		if remove {
+			if err := os.Remove(filepath.Join(filepath.Dir(f.path), entry.Name())); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func compressFile(path string) error {
+	src, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	dst, err := os.OpenFile(path+".gz", os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	gz := gzip.NewWriter(dst)
+	if _, err = io.Copy(gz, src); err != nil {
+		_ = dst.Close()
+		_ = os.Remove(path + ".gz")
+		return err
+	}
+	if err = gz.Close(); err != nil {
+		_ = dst.Close()
+		_ = os.Remove(path + ".gz")
+		return err
+	}
+	if err = dst.Close(); err != nil {
+		return err
+	}
+	return os.Remove(path)
+}
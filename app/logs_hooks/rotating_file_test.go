@@ -0,0 +1,95 @@
+package logs_hooks
+
+import (
+	"github.com/stretchr/testify/require"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func rotatedFiles(t *testing.T, dir string) []string {
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	var names []string
+	for _, entry := range entries {
+		if strings.HasPrefix(entry.Name(), "all-") {
+			names = append(names, entry.Name())
+		}
+	}
+	return names
+}
+
+func readFile(t *testing.T, path string) string {
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	return string(data)
+}
+
+func TestRotatingFileSize(t *testing.T) {
+	dir := t.TempDir()
+	file, err := NewRotatingFile(filepath.Join(dir, "all.log"), RotateOptions{
+		MaxSize:  10,
+		MaxCount: 2,
+		Compress: true,
+	})
+	require.NoError(t, err)
+
+	for i := 0; i < 4; i++ {
+		_, err = file.Write([]byte("12345678\n"))
+		require.NoError(t, err)
+		time.Sleep(2 * time.Millisecond) // names of rotated files have milliseconds
+	}
+	require.NoError(t, file.Close())
+
+	names := rotatedFiles(t, dir)
+	require.Len(t, names, 2)
+	for _, name := range names {
+		require.True(t, strings.HasSuffix(name, ".log.gz"), "rotated file is not compressed: %s", name)
+	}
+	require.Equal(t, "12345678\n", readFile(t, filepath.Join(dir, "all.log")))
+}
+
+func TestRotatingFileReopen(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "all.log")
+	file, err := NewRotatingFile(path, RotateOptions{})
+	require.NoError(t, err)
+	defer file.Close()
+
+	_, _ = file.Write([]byte("old\n"))
+	require.NoError(t, os.Rename(path, path+".1"))
+	require.NoError(t, file.Reopen())
+	_, _ = file.Write([]byte("new\n"))
+
+	require.Equal(t, "new\n", readFile(t, path))
+}
+
+func TestRotatingFileRenameFailure(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "all.log")
+	file, err := NewRotatingFile(path, RotateOptions{MaxSize: 10})
+	require.NoError(t, err)
+	defer file.Close()
+
+	rename = func(string, string) error { return os.ErrPermission }
+	defer func() { rename = os.Rename }()
+
+	for i := 0; i < 3; i++ {
+		_, err = file.Write([]byte("12345678\n"))
+		require.NoError(t, err, "write %d after failed rotation", i)
+	}
+	require.Error(t, file.Rotate())
+	_, err = file.Write([]byte("end\n"))
+	require.NoError(t, err)
+
+	require.Equal(t, "12345678\n12345678\n12345678\nend\n", readFile(t, path))
+	require.Empty(t, rotatedFiles(t, dir))
+
+	rename = os.Rename
+	require.NoError(t, file.Rotate())
+	_, err = file.Write([]byte("new\n"))
+	require.NoError(t, err)
+	require.Equal(t, "new\n", readFile(t, path), "file is not rotated after recovery")
+}
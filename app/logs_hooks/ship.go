@@ -0,0 +1,242 @@
+package logs_hooks
+
+import (
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"sync"
+	"time"
+)
+
+// ShippedEntry is error sent to alerting sink
+type ShippedEntry struct {
+	Time    time.Time              `json:"time"`
+	Level   string                 `json:"level"`
+	Message string                 `json:"message"`
+	Caller  string                 `json:"caller,omitempty"`
+	Fields  map[string]interface{} `json:"fields,omitempty"`
+
+	// Count of same entries (level, caller, message) merged into this one
+	Count int `json:"count"`
+}
+
+// Shipper sends batch of entries (kafka topic, webhook, syslog)
+type Shipper interface {
+	Ship(entries []*ShippedEntry) error
+}
+
+// ShipOptions of ShipHook, zero values disable each limit
+type ShipOptions struct {
+	Buffer        int           // entries waiting for background sender
+	BatchSize     int           // entries in one Ship call
+	FlushInterval time.Duration // max delay of entry
+	RateLimit     int           // shipped entries per minute
+	DedupeWindow  time.Duration // same entries are counted, not shipped, during window
+}
+
+// ShipHook forwards error, fatal and panic entries to shipper in background,
+// Fire never blocks (entries are dropped when buffer is full)
+type ShipHook struct {
+	shipper Shipper
+	opts    ShipOptions
+	entries chan *ShippedEntry
+	flush   chan chan struct{}
+
+	// state of background sender
+	pending    []*ShippedEntry
+	pendingIdx map[string]*ShippedEntry
+	shipped    map[string]time.Time
+	suppressed map[string]*ShippedEntry // first suppressed entry, counts the rest
+	rateStart  time.Time
+	rateCount  int
+	dropped    int
+
+	droppedMu   sync.Mutex
+	fullDropped int
+}
+
+func NewShipHook(shipper Shipper, opts ShipOptions) *ShipHook {
+	if opts.Buffer <= 0 {
+		opts.Buffer = 1000
+	}
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = 100
+	}
+	if opts.FlushInterval <= 0 {
+		opts.FlushInterval = 5 * time.Second
+	}
+	hook := &ShipHook{
+		shipper:    shipper,
+		opts:       opts,
+		entries:    make(chan *ShippedEntry, opts.Buffer),
+		flush:      make(chan chan struct{}),
+		pendingIdx: map[string]*ShippedEntry{},
+		shipped:    map[string]time.Time{},
+		suppressed: map[string]*ShippedEntry{},
+	}
+	go hook.run()
+	return hook
+}
+
+func (hook *ShipHook) Levels() []log.Level {
+	return []log.Level{
+		log.PanicLevel,
+		log.FatalLevel,
+		log.ErrorLevel,
+	}
+}
+
+func (hook *ShipHook) Fire(entry *log.Entry) error {
+	shipped := &ShippedEntry{
+		Time:    entry.Time,
+		Level:   entry.Level.String(),
+		Message: entry.Message,
+		Caller:  caller(entry),
+		Count:   1,
+	}
+	if len(entry.Data) != 0 {
+		shipped.Fields = make(map[string]interface{}, len(entry.Data))
+		for k, v := range entry.Data {
+			if k == CallerField {
+				continue
+			}
+			if err, ok := v.(error); ok {
+				v = err.Error()
+			}
+			shipped.Fields[k] = v
+		}
+	}
+
+	select {
+	case hook.entries <- shipped:
+	default:
+		hook.droppedMu.Lock()
+		hook.fullDropped++
+		hook.droppedMu.Unlock()
+	}
+
+	// Process exits after fatal, so it is sent now
+	if entry.Level <= log.FatalLevel {
+		hook.Flush(5 * time.Second)
+	}
+	return nil
+}
+
+// Flush ships pending entries and waits at most timeout
+func (hook *ShipHook) Flush(timeout time.Duration) {
+	done := make(chan struct{})
+	select {
+	case hook.flush <- done:
+	case <-time.After(timeout):
+		return
+	}
+	select {
+	case <-done:
+	case <-time.After(timeout):
+	}
+}
+
+func (hook *ShipHook) run() {
+	ticker := time.NewTicker(hook.opts.FlushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case entry := <-hook.entries:
+			hook.add(entry, time.Now())
+			if len(hook.pending) >= hook.opts.BatchSize {
+				hook.ship(time.Now())
+			}
+		case <-ticker.C:
+			hook.ship(time.Now())
+		case done := <-hook.flush:
+			for len(hook.entries) > 0 {
+				hook.add(<-hook.entries, time.Now())
+			}
+			hook.ship(time.Now())
+			close(done)
+		}
+	}
+}
+
+func (hook *ShipHook) add(entry *ShippedEntry, now time.Time) {
+	key := entry.Level + "|" + entry.Caller + "|" + entry.Message
+
+	// Same entry in current batch
+	if pending, ok := hook.pendingIdx[key]; ok {
+		pending.Count++
+		return
+	}
+
+	// Same entry was shipped recently
+	if at, ok := hook.shipped[key]; ok && now.Sub(at) < hook.opts.DedupeWindow {
+		if suppressed, ok := hook.suppressed[key]; ok {
+			suppressed.Count++
+		} else {
+			hook.suppressed[key] = entry
+		}
+		return
+	}
+
+	if hook.opts.RateLimit > 0 {
+		if now.Sub(hook.rateStart) >= time.Minute {
+			hook.rateStart, hook.rateCount = now, 0
+		}
+		if hook.rateCount >= hook.opts.RateLimit {
+			hook.dropped++
+			return
+		}
+		hook.rateCount++
+	}
+
+	if suppressed, ok := hook.suppressed[key]; ok {
+		entry.Count += suppressed.Count
+		delete(hook.suppressed, key)
+	}
+	hook.pending = append(hook.pending, entry)
+	hook.pendingIdx[key] = entry
+}
+
+func (hook *ShipHook) ship(now time.Time) {
+	hook.droppedMu.Lock()
+	dropped := hook.dropped + hook.fullDropped
+	hook.dropped, hook.fullDropped = 0, 0
+	hook.droppedMu.Unlock()
+	if dropped > 0 {
+		hook.pending = append(hook.pending, &ShippedEntry{
+			Time:    now,
+			Level:   log.WarnLevel.String(),
+			Message: fmt.Sprintf("%d error entries were dropped by rate limit or full buffer", dropped),
+			Count:   1,
+		})
+	}
+
+	// Counts suppressed till the end of window are shipped as one entry
+	for key, at := range hook.shipped {
+		if now.Sub(at) < hook.opts.DedupeWindow {
+			continue
+		}
+		delete(hook.shipped, key)
+		if suppressed, ok := hook.suppressed[key]; ok {
+			delete(hook.suppressed, key)
+			if pending, ok := hook.pendingIdx[key]; ok {
+				pending.Count += suppressed.Count
+				continue
+			}
+			hook.pending = append(hook.pending, suppressed)
+			hook.pendingIdx[key] = suppressed
+		}
+	}
+	if len(hook.pending) == 0 {
+		return
+	}
+
+	if err := hook.shipper.Ship(hook.pending); err != nil {
+		fmt.Printf("Cannot ship %d error entries. %s\n", len(hook.pending), err.Error())
+	}
+	if hook.opts.DedupeWindow > 0 {
+		for key := range hook.pendingIdx {
+			hook.shipped[key] = now
+		}
+	}
+	hook.pending = nil
+	hook.pendingIdx = map[string]*ShippedEntry{}
+}
@@ -0,0 +1,42 @@
+//go:build !windows && !plan9
+
+package logs_hooks
+
+import (
+	"encoding/json"
+	"log/syslog"
+)
+
+// SyslogShipper writes each entry as JSON message to syslog (network is udp, tcp or empty for local)
+type SyslogShipper struct {
+	writer *syslog.Writer
+}
+
+func NewSyslogShipper(network, address, tag string) (*SyslogShipper, error) {
+	writer, err := syslog.Dial(network, address, syslog.LOG_ERR|syslog.LOG_DAEMON, tag)
+	if err != nil {
+		return nil, err
+	}
+	return &SyslogShipper{writer: writer}, nil
+}
+
+func (s *SyslogShipper) Ship(entries []*ShippedEntry) error {
+	for _, entry := range entries {
+		msg, err := json.Marshal(entry)
+		if err != nil {
+			return err
+		}
+		switch entry.Level {
+		case "panic", "fatal":
+			err = s.writer.Crit(string(msg))
+		case "error":
+			err = s.writer.Err(string(msg))
+		default:
+			err = s.writer.Warning(string(msg))
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
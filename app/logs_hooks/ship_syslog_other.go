@@ -0,0 +1,16 @@
+//go:build windows || plan9
+
+package logs_hooks
+
+import "fmt"
+
+// SyslogShipper is not supported on this OS
+type SyslogShipper struct{}
+
+func NewSyslogShipper(network, address, tag string) (*SyslogShipper, error) {
+	return nil, fmt.Errorf("syslog is not supported")
+}
+
+func (s *SyslogShipper) Ship(entries []*ShippedEntry) error {
+	return fmt.Errorf("syslog is not supported")
+}
@@ -0,0 +1,89 @@
+package logs_hooks
+
+import (
+	"errors"
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+	"io"
+	"sync"
+	"testing"
+	"time"
+)
+
+type testShipper struct {
+	mu      sync.Mutex
+	batches [][]*ShippedEntry
+}
+
+func (s *testShipper) Ship(entries []*ShippedEntry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.batches = append(s.batches, entries)
+	return nil
+}
+
+func TestShipHook(t *testing.T) {
+	shipper := &testShipper{}
+	hook := NewShipHook(shipper, ShipOptions{
+		FlushInterval: time.Hour,
+		RateLimit:     2,
+		DedupeWindow:  time.Minute,
+	})
+
+	logger := log.New()
+	logger.SetOutput(io.Discard)
+	logger.AddHook(hook)
+
+	entry := logger.WithField("request_id", "1").WithField("error", errors.New("cause"))
+	entry.Error("first")
+	entry.Error("first")
+	logger.Error("second")
+	logger.Error("third") // rate limit
+	logger.Warn("warning")
+	hook.Flush(time.Second)
+
+	logger.Error("first") // dedupe window
+	hook.Flush(time.Second)
+
+	shipper.mu.Lock()
+	defer shipper.mu.Unlock()
+	require.Len(t, shipper.batches, 1)
+	batch := shipper.batches[0]
+	require.Len(t, batch, 3, "expected first, second and dropped entries")
+	require.Equal(t, "first", batch[0].Message)
+	require.Equal(t, 2, batch[0].Count)
+	require.Equal(t, "1", batch[0].Fields["request_id"])
+	require.Equal(t, "cause", batch[0].Fields["error"])
+	require.Equal(t, "second", batch[1].Message)
+	require.Equal(t, "warning", batch[2].Level)
+}
+
+func TestShipHookSuppressedCount(t *testing.T) {
+	shipper := &testShipper{}
+	hook := NewShipHook(shipper, ShipOptions{
+		FlushInterval: time.Hour,
+		DedupeWindow:  50 * time.Millisecond,
+	})
+
+	logger := log.New()
+	logger.SetOutput(io.Discard)
+	logger.AddHook(hook)
+
+	logger.Error("burst")
+	hook.Flush(time.Second)
+	logger.Error("burst")
+	logger.Error("burst")
+	hook.Flush(time.Second)
+
+	// Burst stopped, suppressed entries are shipped after window
+	time.Sleep(60 * time.Millisecond)
+	hook.Flush(time.Second)
+
+	shipper.mu.Lock()
+	defer shipper.mu.Unlock()
+	require.Len(t, shipper.batches, 2)
+	require.Len(t, shipper.batches[1], 1)
+	require.Equal(t, "burst", shipper.batches[1][0].Message)
+	require.Equal(t, 2, shipper.batches[1][0].Count)
+	require.Empty(t, hook.suppressed)
+}
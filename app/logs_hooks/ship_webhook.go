@@ -0,0 +1,44 @@
+package logs_hooks
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// WebhookShipper posts JSON array of entries to url
+type WebhookShipper struct {
+	url    string
+	client *http.Client
+}
+
+func NewWebhookShipper(url string, timeout time.Duration) (*WebhookShipper, error) {
+	if url == "" {
+		return nil, fmt.Errorf("empty webhook url")
+	}
+	if timeout <= 0 {
+		timeout = 10 * time.Second
+	}
+	return &WebhookShipper{
+		url:    url,
+		client: &http.Client{Timeout: timeout},
+	}, nil
+}
+
+func (s *WebhookShipper) Ship(entries []*ShippedEntry) error {
+	body, err := json.Marshal(entries)
+	if err != nil {
+		return err
+	}
+	res, err := s.client.Post(s.url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode >= 300 {
+		return fmt.Errorf("webhook responded with status %d", res.StatusCode)
+	}
+	return nil
+}
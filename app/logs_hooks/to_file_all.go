@@ -3,42 +3,25 @@ package logs_hooks
 import (
 	"fmt"
 	log "github.com/sirupsen/logrus"
-	"os"
+	"path"
 	"strings"
 )
 
-var (
-	all_logs *os.File
-)
+// ToFileAllHook writes lines of all levels except trace to logs/all.log
+type ToFileAllHook struct {
+	file *RotatingFile
+}
 
-func initFileAll(rootDir string) {
-	logFile := rootDir + "/logs/all.log"
-	f, err := os.OpenFile(logFile, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+func NewToFileHook(rootDir string, opts RotateOptions) (*ToFileAllHook, error) {
+	file, err := NewRotatingFile(path.Join(rootDir, "logs", "all.log"), opts)
 	if err != nil {
-		fmt.Println("Failed to create all logfile" + logFile)
-		panic(err)
+		return nil, err
 	}
-	//defer f.Close()
-	all_logs = f
-}
-
-type ToFileAllHook struct{}
-
-func NewToFileHook(rootDir string) *ToFileAllHook {
-	initFileAll(rootDir)
-	return &ToFileAllHook{}
+	return &ToFileAllHook{file: file}, nil
 }
 
 func (hook *ToFileAllHook) Fire(entry *log.Entry) error {
-
-	msg := fmt.Sprintf("[%s](%s) %s:%d %s\n",
-		strings.ToUpper(entry.Level.String()),
-		entry.Time.Format("2006-01-02 15:04:05"),
-		entry.Caller.File,
-		entry.Caller.Line,
-		entry.Message)
-
-	_, err := all_logs.WriteString(msg)
+	_, err := hook.file.Write([]byte(formatLine(entry)))
 	if err != nil {
 		fmt.Printf("Cannot write to all log file. %s", err.Error())
 		return err
@@ -56,3 +39,27 @@ func (hook *ToFileAllHook) Levels() []log.Level {
 		log.DebugLevel,
 	}
 }
+
+// File is used for rotation and reopen
+func (hook *ToFileAllHook) File() *RotatingFile {
+	return hook.file
+}
+
+// CallerField is a log field with file:line of caller
+const CallerField = "caller"
+
+func formatLine(entry *log.Entry) string {
+	return fmt.Sprintf("[%s](%s) %s %s\n",
+		strings.ToUpper(entry.Level.String()),
+		entry.Time.Format("2006-01-02 15:04:05"),
+		caller(entry),
+		entry.Message)
+}
+
+func caller(entry *log.Entry) string {
+	if entry.Caller != nil {
+		return fmt.Sprintf("%s:%d", entry.Caller.File, entry.Caller.Line)
+	}
+	c, _ := entry.Data[CallerField].(string)
+	return c
+}
@@ -3,42 +3,24 @@ package logs_hooks
 import (
 	"fmt"
 	log "github.com/sirupsen/logrus"
-	"os"
-	"strings"
+	"path"
 )
 
-var (
-	errors_logs *os.File
-)
+// ToFileErrorHook writes errors to logs/errors.log
+type ToFileErrorHook struct {
+	file *RotatingFile
+}
 
-func initFile(rootDir string) {
-	logFile := rootDir + "/logs/errors.log"
-	f, err := os.OpenFile(logFile, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+func NewToFileErrorHook(rootDir string, opts RotateOptions) (*ToFileErrorHook, error) {
+	file, err := NewRotatingFile(path.Join(rootDir, "logs", "errors.log"), opts)
 	if err != nil {
-		fmt.Println("Failed to create error logfile" + logFile)
-		panic(err)
+		return nil, err
 	}
-	//defer f.Close()
-	errors_logs = f
-}
-
-type ToFileErrorHook struct{}
-
-func NewToFileErrorHook(rootDir string) *ToFileErrorHook {
-	initFile(rootDir)
-	return &ToFileErrorHook{}
+	return &ToFileErrorHook{file: file}, nil
 }
 
 func (hook *ToFileErrorHook) Fire(entry *log.Entry) error {
-
-	msg := fmt.Sprintf("[%s](%s) %s:%d %s\n",
-		strings.ToUpper(entry.Level.String()),
-		entry.Time.Format("2006-01-02 15:04:05"),
-		entry.Caller.File,
-		entry.Caller.Line,
-		entry.Message)
-
-	_, err := errors_logs.WriteString(msg)
+	_, err := hook.file.Write([]byte(formatLine(entry)))
 	if err != nil {
 		fmt.Printf("Cannot write error to log file. %s", err.Error())
 		return err
@@ -53,3 +35,8 @@ func (hook *ToFileErrorHook) Levels() []log.Level {
 		log.ErrorLevel,
 	}
 }
+
+// File is used for rotation and reopen
+func (hook *ToFileErrorHook) File() *RotatingFile {
+	return hook.file
+}
@@ -0,0 +1,56 @@
+package app
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+	"microservice/app/core"
+	"microservice/app/logs_hooks"
+	"strings"
+	"testing"
+)
+
+func testLogger(t *testing.T, levels map[string]logrus.Level) (*DefaultLogger, *bytes.Buffer) {
+	out := &bytes.Buffer{}
+	logger := logrus.New()
+	logger.SetOutput(out)
+	logger.SetFormatter(&logrus.JSONFormatter{})
+	logger.SetLevel(logrus.TraceLevel)
+
+	hook, err := logs_hooks.NewRedactHook([]string{"token"}, []string{`eyJ[\w-]+\.[\w-]+\.[\w-]*`})
+	require.NoError(t, err)
+	logger.AddHook(hook)
+
+	return &DefaultLogger{logger: logrus.NewEntry(logger), level: logrus.InfoLevel, levels: levels}, out
+}
+
+func TestDefaultLoggerRedact(t *testing.T) {
+	log, out := testLogger(t, nil)
+	log.With(core.Fields{"token": "secret", "user": 1}).Info("verify eyJhbGciOiJIUzI1NiJ9.eyJpZCI6MX0.abc")
+
+	var line map[string]interface{}
+	require.NoError(t, json.Unmarshal(out.Bytes(), &line))
+	require.Equal(t, "[REDACTED]", line["token"])
+	require.Equal(t, 1.0, line["user"])
+	require.Equal(t, "verify [REDACTED]", line["msg"])
+	caller, _ := line["caller"].(string)
+	require.True(t, strings.HasPrefix(caller, "app/logs_test.go:"), "caller is not a call site: %v", line["caller"])
+}
+
+func TestDefaultLoggerPackageLevels(t *testing.T) {
+	log, out := testLogger(t, nil)
+	log.Debug("hidden")
+	require.Zero(t, out.Len(), "debug is written with info level: %s", out)
+
+	log, out = testLogger(t, map[string]logrus.Level{"app": logrus.DebugLevel})
+	log.Debug("shown")
+	log.With(core.Fields{"a": 1}).DebugWrap(errors.New("error"), "shown too")
+	require.Contains(t, out.String(), `"msg":"shown"`)
+	require.Contains(t, out.String(), `"msg":"shown too: error"`)
+
+	log, out = testLogger(t, map[string]logrus.Level{"app": logrus.ErrorLevel})
+	log.WarnWrap(errors.New("error"), "hidden")
+	require.Zero(t, out.Len(), "warning is written with error level of package: %s", out)
+}
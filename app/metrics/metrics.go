@@ -0,0 +1,101 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"net/http"
+	"time"
+)
+
+const namespace = "gateway"
+
+var (
+	// HttpRequests are client`s requests to routes (route is a template, e.g. /challenges/{id})
+	HttpRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "Client requests by route, HTTP method and status",
+	}, []string{"route", "method", "status"})
+
+	HttpDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "Client request latency by route, HTTP method and status",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"route", "method", "status"})
+
+	// UpstreamCalls are gRPC calls to instances, code is gRPC status code
+	UpstreamCalls = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "upstream_calls_total",
+		Help:      "Instance calls by instance, service, method and gRPC code",
+	}, []string{"instance", "service", "method", "code"})
+
+	UpstreamDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "upstream_call_duration_seconds",
+		Help:      "Instance call latency by instance, service and method",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"instance", "service", "method"})
+
+	// AuthVerifyDuration is latency of auth_service Verify (result: ok, denied, error)
+	AuthVerifyDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "auth_verify_duration_seconds",
+		Help:      "Access token verification latency by result",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"result"})
+
+	// CacheRequests are lookups of response cache (result: hit, miss, stale),
+	// hit ratio is rate(hit) / rate(all)
+	CacheRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "cache_requests_total",
+		Help:      "Response cache lookups by route and result",
+	}, []string{"route", "result"})
+
+	// InstanceUp is result of StatusService ping or active health check (1 - available)
+	InstanceUp = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "instance_up",
+		Help:      "Instance availability by StatusService",
+	}, []string{"instance"})
+
+	JobDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "job_duration_seconds",
+		Help:      "Job run duration by job and result",
+		Buckets:   []float64{.01, .05, .1, .5, 1, 5, 10, 30, 60, 300},
+	}, []string{"job", "result"})
+
+	KafkaProduced = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "kafka_produced_total",
+		Help:      "Produced Kafka messages by topic and result",
+	}, []string{"topic", "result"})
+
+	KafkaConsumed = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "kafka_consumed_total",
+		Help:      "Consumed Kafka messages by topic and result",
+	}, []string{"topic", "result"})
+)
+
+// Handler serves metrics in Prometheus format
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
+
+// Since is duration in seconds for histograms
+func Since(start time.Time) float64 {
+	return time.Since(start).Seconds()
+}
+
+// Result is label of operation result
+func Result(err error) string {
+	if err != nil {
+		return "error"
+	}
+	return "ok"
+}
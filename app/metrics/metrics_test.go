@@ -0,0 +1,72 @@
+package metrics
+
+import (
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/require"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCollectorsLabels(t *testing.T) {
+	HttpRequests.WithLabelValues("/users/{id}", "GET", "200").Inc()
+	HttpDuration.WithLabelValues("/users/{id}", "GET", "200").Observe(0.1)
+	UpstreamCalls.WithLabelValues("users", "UserService", "Get", "OK").Inc()
+	UpstreamDuration.WithLabelValues("users", "UserService", "Get").Observe(0.1)
+	AuthVerifyDuration.WithLabelValues("ok").Observe(0.1)
+	CacheRequests.WithLabelValues("/users/{id}", "hit").Inc()
+	InstanceUp.WithLabelValues("users").Set(1)
+	JobDuration.WithLabelValues("jobs.NewJob", Result(nil)).Observe(1)
+	KafkaProduced.WithLabelValues("topic", Result(errors.New("failed"))).Inc()
+	KafkaConsumed.WithLabelValues("topic", "ok").Inc()
+
+	families, err := prometheus.DefaultGatherer.Gather()
+	require.NoError(t, err)
+	labels := map[string]string{}
+	for _, family := range families {
+		if len(family.Metric) == 0 {
+			continue
+		}
+		var names []string
+		for _, pair := range family.Metric[0].Label {
+			names = append(names, pair.GetName())
+		}
+		sort.Strings(names)
+		labels[family.GetName()] = strings.Join(names, ",")
+	}
+
+	expected := map[string]string{
+		"gateway_http_requests_total":            "method,route,status",
+		"gateway_http_request_duration_seconds":  "method,route,status",
+		"gateway_upstream_calls_total":           "code,instance,method,service",
+		"gateway_upstream_call_duration_seconds": "instance,method,service",
+		"gateway_auth_verify_duration_seconds":   "result",
+		"gateway_cache_requests_total":           "result,route",
+		"gateway_instance_up":                    "instance",
+		"gateway_job_duration_seconds":           "job,result",
+		"gateway_kafka_produced_total":           "result,topic",
+		"gateway_kafka_consumed_total":           "result,topic",
+	}
+	for name, expectedLabels := range expected {
+		require.Contains(t, labels, name)
+		require.Equal(t, expectedLabels, labels[name], name)
+	}
+}
+
+func TestHandler(t *testing.T) {
+	InstanceUp.WithLabelValues("handler_test").Set(0)
+
+	w := httptest.NewRecorder()
+	Handler().ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+	require.Equal(t, 200, w.Code)
+	require.Contains(t, w.Body.String(), `gateway_instance_up{instance="handler_test"} 0`)
+}
+
+func TestHelpers(t *testing.T) {
+	require.Equal(t, "ok", Result(nil))
+	require.Equal(t, "error", Result(errors.New("x")))
+	require.InDelta(t, 1.5, Since(time.Now().Add(-time.Second)), 0.5)
+}
@@ -0,0 +1,187 @@
+package openapi
+
+import (
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// SchemaOptions are the same as protojson options which change JSON shape
+// and namespace of schemas (instances may have same message names)
+type SchemaOptions struct {
+	Namespace      string
+	UseProtoNames  bool
+	UseEnumNumbers bool
+}
+
+// Schemas converts proto descriptors to JSON schemas (as protojson marshals them)
+// and puts messages to document`s components
+type Schemas struct {
+	doc *Document
+}
+
+func NewSchemas(doc *Document) *Schemas {
+	return &Schemas{doc: doc}
+}
+
+// Message returns reference to message schema (or inline schema for well known types)
+func (s *Schemas) Message(md protoreflect.MessageDescriptor, opts SchemaOptions) *Schema {
+	if wkt := wellKnownSchema(md); wkt != nil {
+		return wkt
+	}
+
+	name := schemaName(md, opts)
+	if _, ok := s.doc.Components.Schemas[name]; ok {
+		return Ref(name)
+	}
+
+	// Placeholder first: messages may be recursive
+	schema := &Schema{
+		Type:       "object",
+		Properties: make(map[string]*Schema),
+	}
+	s.doc.Components.Schemas[name] = schema
+
+	fields := md.Fields()
+	for i := 0; i < fields.Len(); i++ {
+		fd := fields.Get(i)
+		fieldName := fd.JSONName()
+		if opts.UseProtoNames {
+			fieldName = string(fd.Name())
+		}
+		schema.Properties[fieldName] = s.Field(fd, opts)
+	}
+	return Ref(name)
+}
+
+// Field returns schema of field including repeated and map fields
+func (s *Schemas) Field(fd protoreflect.FieldDescriptor, opts SchemaOptions) *Schema {
+	switch {
+	case fd.IsMap():
+		return &Schema{
+			Type:                 "object",
+			AdditionalProperties: s.singular(fd.MapValue(), opts),
+		}
+	case fd.IsList():
+		return &Schema{
+			Type:  "array",
+			Items: s.singular(fd, opts),
+		}
+	}
+	return s.singular(fd, opts)
+}
+
+func (s *Schemas) singular(fd protoreflect.FieldDescriptor, opts SchemaOptions) *Schema {
+	switch fd.Kind() {
+	case protoreflect.BoolKind:
+		return &Schema{Type: "boolean"}
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind:
+		return &Schema{Type: "integer", Format: "int32"}
+	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind:
+		return &Schema{Type: "integer", Format: "uint32"}
+	case protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind:
+		// protojson writes 64 bit integers as strings
+		return &Schema{Type: "string", Format: "int64"}
+	case protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		return &Schema{Type: "string", Format: "uint64"}
+	case protoreflect.FloatKind:
+		return &Schema{Type: "number", Format: "float"}
+	case protoreflect.DoubleKind:
+		return &Schema{Type: "number", Format: "double"}
+	case protoreflect.StringKind:
+		return &Schema{Type: "string"}
+	case protoreflect.BytesKind:
+		return &Schema{Type: "string", Format: "byte"}
+	case protoreflect.EnumKind:
+		return enumSchema(fd.Enum(), opts)
+	case protoreflect.MessageKind, protoreflect.GroupKind:
+		return s.Message(fd.Message(), opts)
+	}
+	return &Schema{}
+}
+
+func enumSchema(ed protoreflect.EnumDescriptor, opts SchemaOptions) *Schema {
+	if ed.FullName() == "google.protobuf.NullValue" {
+		return &Schema{Nullable: true}
+	}
+
+	values := ed.Values()
+	schema := &Schema{Type: "string"}
+	if opts.UseEnumNumbers {
+		schema.Type = "integer"
+		schema.Format = "int32"
+	}
+	for i := 0; i < values.Len(); i++ {
+		if opts.UseEnumNumbers {
+			schema.Enum = append(schema.Enum, values.Get(i).Number())
+		} else {
+			schema.Enum = append(schema.Enum, string(values.Get(i).Name()))
+		}
+	}
+	return schema
+}
+
+// schemaName is message full name, JSON shape options make other schema
+func schemaName(md protoreflect.MessageDescriptor, opts SchemaOptions) string {
+	name := string(md.FullName())
+	if opts.Namespace != "" {
+		name = opts.Namespace + "." + name
+	}
+	if opts.UseProtoNames {
+		name += ".ProtoNames"
+	}
+	if opts.UseEnumNumbers {
+		name += ".EnumNumbers"
+	}
+	return name
+}
+
+// IsWellKnown reports whether message has special JSON mapping (scalar for most of them)
+func IsWellKnown(md protoreflect.MessageDescriptor) bool {
+	return wellKnownSchema(md) != nil
+}
+
+// wellKnownSchema is JSON mapping of google.protobuf types
+func wellKnownSchema(md protoreflect.MessageDescriptor) *Schema {
+	switch md.FullName() {
+	case "google.protobuf.Timestamp":
+		return &Schema{Type: "string", Format: "date-time"}
+	case "google.protobuf.Duration":
+		return &Schema{Type: "string", Format: "duration", Description: "Seconds with suffix s, e.g. 1.5s"}
+	case "google.protobuf.FieldMask":
+		return &Schema{Type: "string", Format: "field-mask", Description: "Comma separated field paths"}
+	case "google.protobuf.Struct":
+		return &Schema{Type: "object", AdditionalProperties: true}
+	case "google.protobuf.Value":
+		return &Schema{}
+	case "google.protobuf.ListValue":
+		return &Schema{Type: "array", Items: &Schema{}}
+	case "google.protobuf.Empty":
+		return &Schema{Type: "object"}
+	case "google.protobuf.Any":
+		return &Schema{
+			Type: "object",
+			Properties: map[string]*Schema{
+				"@type": {Type: "string"},
+			},
+			AdditionalProperties: true,
+		}
+	case "google.protobuf.DoubleValue":
+		return &Schema{Type: "number", Format: "double", Nullable: true}
+	case "google.protobuf.FloatValue":
+		return &Schema{Type: "number", Format: "float", Nullable: true}
+	case "google.protobuf.Int64Value":
+		return &Schema{Type: "string", Format: "int64", Nullable: true}
+	case "google.protobuf.UInt64Value":
+		return &Schema{Type: "string", Format: "uint64", Nullable: true}
+	case "google.protobuf.Int32Value":
+		return &Schema{Type: "integer", Format: "int32", Nullable: true}
+	case "google.protobuf.UInt32Value":
+		return &Schema{Type: "integer", Format: "uint32", Nullable: true}
+	case "google.protobuf.BoolValue":
+		return &Schema{Type: "boolean", Nullable: true}
+	case "google.protobuf.StringValue":
+		return &Schema{Type: "string", Nullable: true}
+	case "google.protobuf.BytesValue":
+		return &Schema{Type: "string", Format: "byte", Nullable: true}
+	}
+	return nil
+}
@@ -0,0 +1,38 @@
+package openapi
+
+import (
+	"github.com/stretchr/testify/require"
+	pb "microservice/pkg/gateway/api"
+	"testing"
+)
+
+func TestSchemasMessage(t *testing.T) {
+	doc := NewDocument("test", "v1")
+	schemas := NewSchemas(doc)
+
+	md := (&pb.CreateRouteRequest{}).ProtoReflect().Descriptor()
+	ref := schemas.Message(md, SchemaOptions{})
+	require.Equal(t, "#/components/schemas/gateway.admin.v1.CreateRouteRequest", ref.Ref)
+
+	route := doc.Components.Schemas["gateway.admin.v1.Route"]
+	require.NotNil(t, route)
+	require.Equal(t, &Schema{Type: "string", Format: "int64"}, route.Properties["id"])
+	require.Equal(t, &Schema{Type: "integer", Format: "int32"}, route.Properties["accessRole"])
+	require.Equal(t, "#/components/schemas/gateway.admin.v1.JsonOptions", route.Properties["json"].Ref)
+
+	// Proto names make other schema
+	schemas.Message(md, SchemaOptions{UseProtoNames: true})
+	route = doc.Components.Schemas["gateway.admin.v1.Route.ProtoNames"]
+	require.NotNil(t, route)
+	require.Contains(t, route.Properties, "access_role")
+}
+
+func TestSchemasRepeated(t *testing.T) {
+	doc := NewDocument("test", "v1")
+	schemas := NewSchemas(doc)
+
+	schemas.Message((&pb.GetStatusResponse{}).ProtoReflect().Descriptor(), SchemaOptions{})
+	status := doc.Components.Schemas["gateway.admin.v1.InstanceStatus"]
+	require.NotNil(t, status)
+	require.Equal(t, &Schema{Type: "array", Items: &Schema{Type: "string"}}, status.Properties["services"])
+}
@@ -5,23 +5,28 @@ import (
 	"encoding/json"
 	"fmt"
 	"github.com/pkg/errors"
+	semconv "go.opentelemetry.io/otel/semconv/v1.17.0"
+	"go.opentelemetry.io/otel/trace"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/metadata"
-	"google.golang.org/protobuf/encoding/protojson"
 	"google.golang.org/protobuf/proto"
 	"google.golang.org/protobuf/reflect/protodesc"
 	"google.golang.org/protobuf/reflect/protoreflect"
 	"google.golang.org/protobuf/types/descriptorpb"
 	"google.golang.org/protobuf/types/dynamicpb"
+	"io"
+	"microservice/app/tracing"
 	"os"
 	"os/exec"
 	"path"
 	"path/filepath"
 	"strings"
+	"sync"
 )
 
 // ProtoRegistry is a list of all instances with info and calling
 type ProtoRegistry struct {
+	mu        sync.RWMutex
 	instances map[string]*ProtoInstance
 }
 
@@ -32,23 +37,35 @@ func NewProtoRegistry() *ProtoRegistry {
 }
 
 func (pr *ProtoRegistry) Init() error {
+	return pr.Reload()
+}
+
+// Reload parses proto files again, registry is replaced only if all instances are loaded
+func (pr *ProtoRegistry) Reload() error {
 	// FOLDER auth_service
 	instances, err := pr.loadInstances("./proto")
 	if err != nil {
 		return errors.Wrapf(err, "cannot loat services for instances")
 	}
 
+	loaded := make(map[string]*ProtoInstance)
 	for _, instance := range instances {
-		pr.instances[instance.Name] = instance
 		err := instance.loadServices()
 		if err != nil {
 			return errors.Wrapf(err, "cannot loat services for instance %s", instance.Name)
 		}
+		loaded[instance.Name] = instance
 	}
+
+	pr.mu.Lock()
+	pr.instances = loaded
+	pr.mu.Unlock()
 	return nil
 }
 
 func (pr *ProtoRegistry) Instance(name string) *ProtoInstance {
+	pr.mu.RLock()
+	defer pr.mu.RUnlock()
 	return pr.instances[name]
 }
 
@@ -57,6 +74,8 @@ func (pr *ProtoRegistry) InstanceExists(name string) bool {
 }
 
 func (pr *ProtoRegistry) Instances() []string {
+	pr.mu.RLock()
+	defer pr.mu.RUnlock()
 	var keys []string
 	for k := range pr.instances {
 		keys = append(keys, k)
@@ -86,7 +105,7 @@ func (pr *ProtoRegistry) Ping(conn *grpc.ClientConn, instanceName string) ([]byt
 // in - instance name
 // sn - service name
 // mn - method name
-func (pr *ProtoRegistry) CallJsonWithContext(conn *grpc.ClientConn, ctx context.Context, in, sn, mn string, jsonIn []byte, headers map[string]string) ([]byte, error) {
+func (pr *ProtoRegistry) CallJsonWithContext(conn *grpc.ClientConn, ctx context.Context, in, sn, mn string, jsonIn []byte, headers map[string]string, opts ...grpc.CallOption) ([]byte, error) {
 	if !pr.InstanceExists(in) {
 		return nil, errors.Errorf("instance %s not found", in)
 	}
@@ -97,7 +116,7 @@ func (pr *ProtoRegistry) CallJsonWithContext(conn *grpc.ClientConn, ctx context.
 	}
 	service := instance.Service(sn)
 
-	return service.CallJsonWithContext(conn, ctx, mn, jsonIn, headers)
+	return service.CallJsonWithContext(conn, ctx, mn, jsonIn, headers, opts...)
 }
 
 func (pr *ProtoRegistry) loadInstances(protoPath string) (items []*ProtoInstance, err error) {
@@ -253,12 +272,12 @@ func (r *ProtoInstance) CallWithContext(conn *grpc.ClientConn, ctx context.Conte
 	return serviceObj.CallWithContext(conn, ctx, method, in, out, headers)
 }
 
-func (r *ProtoInstance) CallJsonWithContext(conn *grpc.ClientConn, ctx context.Context, service, method string, jsonIn []byte, headers map[string]string) ([]byte, error) {
+func (r *ProtoInstance) CallJsonWithContext(conn *grpc.ClientConn, ctx context.Context, service, method string, jsonIn []byte, headers map[string]string, opts ...grpc.CallOption) ([]byte, error) {
 	serviceObj := r.services[service]
 	if serviceObj == nil {
 		return nil, errors.New("service does not exist")
 	}
-	return serviceObj.CallJsonWithContext(conn, ctx, method, jsonIn, headers)
+	return serviceObj.CallJsonWithContext(conn, ctx, method, jsonIn, headers, opts...)
 }
 
 type ProtoService struct {
@@ -289,6 +308,10 @@ func (s *ProtoService) Name() string {
 	return string(s.service.Name())
 }
 
+func (s *ProtoService) Method(name string) *ProtoMethod {
+	return s.methods[name]
+}
+
 func (s *ProtoService) Methods() []string {
 	var list []string
 
@@ -322,12 +345,20 @@ func (s *ProtoService) CallWithContext(conn *grpc.ClientConn, ctx context.Contex
 	return methodObj.CallWithContext(ctx, conn, in, out, headers)
 }
 
-func (s *ProtoService) CallJsonWithContext(conn *grpc.ClientConn, ctx context.Context, method string, jsonIn []byte, headers map[string]string) ([]byte, error) {
+func (s *ProtoService) CallJsonWithContext(conn *grpc.ClientConn, ctx context.Context, method string, jsonIn []byte, headers map[string]string, opts ...grpc.CallOption) ([]byte, error) {
+	methodObj := s.methods[method]
+	if methodObj == nil {
+		return nil, errors.New(fmt.Sprintf("method %s does not exist", method))
+	}
+	return methodObj.CallJsonWithContext(ctx, conn, jsonIn, headers, opts...)
+}
+
+func (s *ProtoService) CallRawWithContext(conn *grpc.ClientConn, ctx context.Context, method string, in []byte, inCodec, outCodec ProtoCodec, headers map[string]string, opts ...grpc.CallOption) ([]byte, error) {
 	methodObj := s.methods[method]
 	if methodObj == nil {
 		return nil, errors.New(fmt.Sprintf("method %s does not exist", method))
 	}
-	return methodObj.CallJsonWithContext(ctx, conn, jsonIn, headers)
+	return methodObj.CallRawWithContext(ctx, conn, in, inCodec, outCodec, headers, opts...)
 }
 
 type ProtoMethod struct {
@@ -339,6 +370,11 @@ type ProtoMethod struct {
 
 func MakeProtoMethod(parent *ProtoService, descriptor protoreflect.MethodDescriptor) (*ProtoMethod, error) {
 
+	// Broken validation rules (invalid pattern) are descriptor errors
+	if err := CheckValidationRules(descriptor.Input()); err != nil {
+		return nil, errors.Wrapf(err, "method %s", descriptor.FullName())
+	}
+
 	methodObj := &ProtoMethod{
 		parent:   parent,
 		method:   descriptor,
@@ -389,30 +425,188 @@ func (m *ProtoMethod) CallWithContext(ctx context.Context, conn *grpc.ClientConn
 	return nil
 }
 
-func (m *ProtoMethod) CallJsonWithContext(ctx context.Context, conn *grpc.ClientConn, jsonInput []byte, headers map[string]string) ([]byte, error) {
+func (m *ProtoMethod) CallJsonWithContext(ctx context.Context, conn *grpc.ClientConn, jsonInput []byte, headers map[string]string, opts ...grpc.CallOption) ([]byte, error) {
+	return m.CallRawWithContext(ctx, conn, jsonInput, JsonCodec(), JsonCodec(), headers, opts...)
+}
+
+// CallRawWithContext decodes input with inCodec and encodes response with outCodec
+func (m *ProtoMethod) CallRawWithContext(ctx context.Context, conn *grpc.ClientConn, input []byte, inCodec, outCodec ProtoCodec, headers map[string]string, opts ...grpc.CallOption) ([]byte, error) {
+
+	requestObj, err := m.NewRequest(input, inCodec)
+	if err != nil {
+		return nil, err
+	}
+
+	responseObj, err := m.InvokeWithContext(ctx, conn, requestObj, headers, opts...)
+	if err != nil {
+		return nil, err
+	}
 
+	output, err := outCodec.Marshal(responseObj)
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot marshal proto response to %s", outCodec.ContentType())
+	}
+	return output, nil
+}
+
+// NewRequest decodes input to request message
+func (m *ProtoMethod) NewRequest(input []byte, codec ProtoCodec) (*dynamicpb.Message, error) {
 	requestObj := dynamicpb.NewMessage(m.request)
+	err := codec.Unmarshal(input, requestObj)
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot unmarshal %s to proto descriptor", codec.ContentType())
+	}
+	return requestObj, nil
+}
+
+// InvokeWithContext calls method with ready request message
+func (m *ProtoMethod) InvokeWithContext(ctx context.Context, conn *grpc.ClientConn, requestObj *dynamicpb.Message, headers map[string]string, opts ...grpc.CallOption) (_ *dynamicpb.Message, err error) {
+
+	ctx, span := m.startSpan(ctx)
+	defer func() { tracing.End(span, err) }()
+
 	responseObj := dynamicpb.NewMessage(m.response)
 
-	err := protojson.Unmarshal(jsonInput, requestObj)
+	if headers != nil && len(headers) != 0 {
+		headersMeta := metadata.New(headers)
+		ctx = metadata.NewOutgoingContext(ctx, headersMeta)
+	}
+
+	err = conn.Invoke(ctx, m.FullName(), requestObj, responseObj, opts...)
+	if err != nil {
+		return nil, errors.Wrap(err, "error while invoke proto method")
+	}
+	return responseObj, nil
+}
+
+// StreamWithContext calls server-streaming method, recv is called for each response message
+func (m *ProtoMethod) StreamWithContext(ctx context.Context, conn *grpc.ClientConn, requestObj *dynamicpb.Message, headers map[string]string, recv func(*dynamicpb.Message) error, opts ...grpc.CallOption) (err error) {
+
+	ctx, span := m.startSpan(ctx)
+	defer func() { tracing.End(span, err) }()
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	if headers != nil && len(headers) != 0 {
+		headersMeta := metadata.New(headers)
+		ctx = metadata.NewOutgoingContext(ctx, headersMeta)
+	}
+
+	desc := &grpc.StreamDesc{
+		StreamName:    m.Name(),
+		ServerStreams: true,
+	}
+	stream, err := conn.NewStream(ctx, desc, m.FullName(), opts...)
 	if err != nil {
-		return nil, errors.Wrap(err, "cannot unmarshal json to proto descriptor")
+		return errors.Wrap(err, "cannot open proto stream")
+	}
+	if err = stream.SendMsg(requestObj); err != nil {
+		return errors.Wrap(err, "cannot send request to proto stream")
+	}
+	if err = stream.CloseSend(); err != nil {
+		return errors.Wrap(err, "cannot close send of proto stream")
 	}
 
+	for {
+		responseObj := dynamicpb.NewMessage(m.response)
+		err = stream.RecvMsg(responseObj)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return errors.Wrap(err, "error while receive from proto stream")
+		}
+		if err = recv(responseObj); err != nil {
+			return err
+		}
+	}
+}
+
+// OpenStream opens client, server or bidirectional stream of method,
+// its span is ended when Recv returns error or io.EOF
+func (m *ProtoMethod) OpenStream(ctx context.Context, conn *grpc.ClientConn, headers map[string]string, opts ...grpc.CallOption) (*ProtoStream, error) {
+
+	ctx, span := m.startSpan(ctx)
+
 	if headers != nil && len(headers) != 0 {
 		headersMeta := metadata.New(headers)
 		ctx = metadata.NewOutgoingContext(ctx, headersMeta)
 	}
 
-	caller := "/" + string(m.parent.service.FullName()) + "/" + string(m.method.Name())
-	err = conn.Invoke(ctx, caller, requestObj, responseObj)
+	desc := &grpc.StreamDesc{
+		StreamName:    m.Name(),
+		ServerStreams: m.IsServerStreaming(),
+		ClientStreams: m.IsClientStreaming(),
+	}
+	stream, err := conn.NewStream(ctx, desc, m.FullName(), opts...)
 	if err != nil {
-		return nil, errors.Wrap(err, "error while invoke proto method")
+		tracing.End(span, err)
+		return nil, errors.Wrap(err, "cannot open proto stream")
 	}
+	return &ProtoStream{
+		method: m,
+		stream: stream,
+		span:   span,
+	}, nil
+}
+
+// startSpan starts client span of gRPC call
+func (m *ProtoMethod) startSpan(ctx context.Context) (context.Context, trace.Span) {
+	return tracing.Start(ctx, m.FullName(),
+		semconv.RPCSystemGRPC,
+		semconv.RPCService(string(m.parent.service.FullName())),
+		semconv.RPCMethod(m.Name()),
+	)
+}
+
+func (m *ProtoMethod) IsServerStreaming() bool {
+	return m.method.IsStreamingServer()
+}
+
+func (m *ProtoMethod) IsClientStreaming() bool {
+	return m.method.IsStreamingClient()
+}
+
+// FullName is a gRPC method name (/package.Service/Method)
+func (m *ProtoMethod) FullName() string {
+	return "/" + string(m.parent.service.FullName()) + "/" + string(m.method.Name())
+}
+
+func (m *ProtoMethod) Request() protoreflect.MessageDescriptor {
+	return m.request
+}
 
-	jsonOutput, err := protojson.Marshal(responseObj)
+func (m *ProtoMethod) Response() protoreflect.MessageDescriptor {
+	return m.response
+}
+
+// ProtoStream is an opened stream of dynamic messages
+type ProtoStream struct {
+	method *ProtoMethod
+	stream grpc.ClientStream
+	span   trace.Span
+}
+
+func (s *ProtoStream) Send(requestObj *dynamicpb.Message) error {
+	return s.stream.SendMsg(requestObj)
+}
+
+// Recv returns io.EOF when stream is finished
+func (s *ProtoStream) Recv() (*dynamicpb.Message, error) {
+	responseObj := dynamicpb.NewMessage(s.method.response)
+	err := s.stream.RecvMsg(responseObj)
+	if err == io.EOF {
+		s.span.End()
+		return nil, err
+	}
 	if err != nil {
-		return nil, errors.Wrap(err, "cannot marshal proto response to json")
+		tracing.End(s.span, err)
+		return nil, err
 	}
-	return jsonOutput, nil
+	return responseObj, nil
+}
+
+func (s *ProtoStream) CloseSend() error {
+	return s.stream.CloseSend()
 }
@@ -0,0 +1,99 @@
+package app
+
+import (
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	ContentTypeJson     = "application/json"
+	ContentTypeProtobuf = "application/x-protobuf"
+)
+
+// ProtoCodec converts client`s body to proto message and back
+type ProtoCodec interface {
+	ContentType() string
+	Marshal(proto.Message) ([]byte, error)
+	Unmarshal([]byte, proto.Message) error
+}
+
+// JsonOptions of protojson used in both directions
+type JsonOptions struct {
+	UseProtoNames   bool
+	EmitUnpopulated bool
+	UseEnumNumbers  bool
+	DiscardUnknown  bool
+}
+
+// CodecByContentType returns codec for media type (JSON by default)
+func CodecByContentType(contentType string, opts ...JsonOptions) ProtoCodec {
+	switch contentType {
+	case ContentTypeProtobuf, "application/protobuf":
+		return BinaryCodec()
+	default:
+		return JsonCodec(opts...)
+	}
+}
+
+type jsonCodec struct {
+	marshal   protojson.MarshalOptions
+	unmarshal protojson.UnmarshalOptions
+}
+
+func JsonCodec(opts ...JsonOptions) ProtoCodec {
+	c := &jsonCodec{}
+	if len(opts) > 0 {
+		c.marshal = protojson.MarshalOptions{
+			UseProtoNames:   opts[0].UseProtoNames,
+			EmitUnpopulated: opts[0].EmitUnpopulated,
+			UseEnumNumbers:  opts[0].UseEnumNumbers,
+		}
+		c.unmarshal = protojson.UnmarshalOptions{
+			DiscardUnknown: opts[0].DiscardUnknown,
+		}
+	}
+	return c
+}
+
+func (*jsonCodec) ContentType() string {
+	return ContentTypeJson
+}
+
+func (c *jsonCodec) Marshal(m proto.Message) ([]byte, error) {
+	return c.marshal.Marshal(m)
+}
+
+// Unmarshal returns ValidationErrors with each incorrect field of client`s JSON
+func (c *jsonCodec) Unmarshal(in []byte, m proto.Message) error {
+	if len(in) == 0 {
+		in = []byte("{}")
+	}
+	if err := c.unmarshal.Unmarshal(in, m); err != nil {
+		if errs := ValidateJson(m.ProtoReflect().Descriptor(), in, c.unmarshal.DiscardUnknown); len(errs) > 0 {
+			return errs
+		}
+		return ValidationError{Message: err.Error()}
+	}
+	return nil
+}
+
+type binaryCodec struct{}
+
+func BinaryCodec() ProtoCodec {
+	return &binaryCodec{}
+}
+
+func (*binaryCodec) ContentType() string {
+	return ContentTypeProtobuf
+}
+
+func (*binaryCodec) Marshal(m proto.Message) ([]byte, error) {
+	return proto.Marshal(m)
+}
+
+func (*binaryCodec) Unmarshal(in []byte, m proto.Message) error {
+	if err := proto.Unmarshal(in, m); err != nil {
+		return ValidationError{Message: err.Error()}
+	}
+	return nil
+}
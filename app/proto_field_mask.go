@@ -0,0 +1,138 @@
+package app
+
+import (
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"strings"
+)
+
+const fieldMaskName = "google.protobuf.FieldMask"
+
+// maskNode is field of mask, nil children keep field whole
+type maskNode struct {
+	children map[protoreflect.FieldNumber]*maskNode
+}
+
+// ApplyFieldMask clears fields of msg which are not in paths.
+// Paths are a.b.c by proto or json names, repeated messages are masked item by item
+func ApplyFieldMask(msg protoreflect.Message, paths []string) error {
+	root, err := parseFieldMask(msg.Descriptor(), paths)
+	if err != nil {
+		return err
+	}
+	root.apply(msg)
+	return nil
+}
+
+// FieldMaskProtoPaths converts paths to proto names (as google.protobuf.FieldMask expects)
+func FieldMaskProtoPaths(desc protoreflect.MessageDescriptor, paths []string) ([]string, error) {
+	res := make([]string, 0, len(paths))
+	for _, path := range paths {
+		var names []string
+		md := desc
+		for _, name := range strings.Split(path, ".") {
+			if md == nil {
+				return nil, ValidationError{Path: path, Message: "field is not a message"}
+			}
+			fd := findField(md, name)
+			if fd == nil {
+				return nil, ValidationError{Path: path, Message: "unknown field"}
+			}
+			names = append(names, string(fd.Name()))
+			md = maskMessage(fd)
+		}
+		res = append(res, strings.Join(names, "."))
+	}
+	return res, nil
+}
+
+// readMaskFields are names of request fields which select response fields,
+// other masks (update_mask) have own meaning and are not set from client`s response mask
+var readMaskFields = []protoreflect.Name{"read_mask", "field_mask"}
+
+// SetRequestFieldMask sets paths into read mask field of request (read_mask or field_mask),
+// if there is no such field or client has set it then nothing is changed
+func SetRequestFieldMask(request protoreflect.Message, paths []string) bool {
+	fields := request.Descriptor().Fields()
+	for _, name := range readMaskFields {
+		fd := fields.ByName(name)
+		if fd == nil || fd.IsList() || fd.Message() == nil || fd.Message().FullName() != fieldMaskName {
+			continue
+		}
+		if request.Has(fd) {
+			return false
+		}
+		mask := request.Mutable(fd).Message()
+		list := mask.Mutable(mask.Descriptor().Fields().ByName("paths")).List()
+		for _, path := range paths {
+			list.Append(protoreflect.ValueOfString(path))
+		}
+		return true
+	}
+	return false
+}
+
+func parseFieldMask(desc protoreflect.MessageDescriptor, paths []string) (*maskNode, error) {
+	root := &maskNode{children: map[protoreflect.FieldNumber]*maskNode{}}
+	for _, path := range paths {
+		node := root
+		md := desc
+		parts := strings.Split(path, ".")
+		for i, name := range parts {
+			if md == nil {
+				return nil, ValidationError{Path: path, Message: "field is not a message"}
+			}
+			fd := findField(md, name)
+			if fd == nil {
+				return nil, ValidationError{Path: path, Message: "unknown field"}
+			}
+
+			next, ok := node.children[fd.Number()]
+			if ok && next == nil {
+				break // parent is already kept whole
+			}
+			if i == len(parts)-1 {
+				node.children[fd.Number()] = nil
+				break
+			}
+			if next == nil {
+				next = &maskNode{children: map[protoreflect.FieldNumber]*maskNode{}}
+				node.children[fd.Number()] = next
+			}
+			node = next
+			md = maskMessage(fd)
+		}
+	}
+	return root, nil
+}
+
+func (n *maskNode) apply(msg protoreflect.Message) {
+	var clear []protoreflect.FieldDescriptor
+	msg.Range(func(fd protoreflect.FieldDescriptor, v protoreflect.Value) bool {
+		child, ok := n.children[fd.Number()]
+		switch {
+		case !ok:
+			clear = append(clear, fd)
+		case child == nil:
+			// whole field
+		case fd.IsList():
+			list := v.List()
+			for i := 0; i < list.Len(); i++ {
+				child.apply(list.Get(i).Message())
+			}
+		default:
+			child.apply(v.Message())
+		}
+		return true
+	})
+	for _, fd := range clear {
+		msg.Clear(fd)
+	}
+}
+
+// maskMessage is message of field which mask can go into (maps are masked whole)
+func maskMessage(fd protoreflect.FieldDescriptor) protoreflect.MessageDescriptor {
+	if fd.IsMap() {
+		return nil
+	}
+	return fd.Message()
+}
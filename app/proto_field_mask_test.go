@@ -0,0 +1,59 @@
+package app
+
+import (
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/types/dynamicpb"
+	"net/url"
+	"testing"
+)
+
+func TestApplyFieldMask(t *testing.T) {
+	desc := testMessageDescriptor(t)
+	msg := dynamicpb.NewMessage(desc)
+	require.NoError(t, SetParams(msg, url.Values{
+		"id":          {"5"},
+		"tags":        {"a", "b"},
+		"filter.from": {"2023-02-10T17:49:29Z"},
+	}))
+	filters := msg.Mutable(desc.Fields().ByName("filters")).List()
+	filters.Append(filters.NewElement())
+
+	require.NoError(t, ApplyFieldMask(msg, []string{"id", "filter", "filters.from"}))
+
+	fields := desc.Fields()
+	require.True(t, msg.Has(fields.ByName("id")))
+	require.False(t, msg.Has(fields.ByName("tags")))
+	require.True(t, msg.Has(fields.ByName("filter")))
+	require.Equal(t, 1, msg.Get(fields.ByName("filters")).List().Len())
+
+	err := ApplyFieldMask(msg, []string{"id.value"})
+	require.True(t, IsValidationError(err))
+	err = ApplyFieldMask(msg, []string{"unknown"})
+	require.True(t, IsValidationError(err))
+}
+
+func TestSetRequestFieldMask(t *testing.T) {
+	desc := testMessageDescriptor(t)
+
+	paths, err := FieldMaskProtoPaths(desc, []string{"id", "filter.from"})
+	require.NoError(t, err)
+	require.Equal(t, []string{"id", "filter.from"}, paths)
+	_, err = FieldMaskProtoPaths(desc, []string{"tags.value"})
+	require.True(t, IsValidationError(err))
+
+	msg := dynamicpb.NewMessage(desc)
+	require.True(t, SetRequestFieldMask(msg, paths))
+	mask := msg.Get(desc.Fields().ByName("read_mask")).Message()
+	require.Equal(t, 2, mask.Get(mask.Descriptor().Fields().ByName("paths")).List().Len())
+
+	// Client`s mask is not replaced
+	require.False(t, SetRequestFieldMask(msg, []string{"id"}))
+}
+
+func TestSetRequestFieldMaskKeepsUpdateMask(t *testing.T) {
+	desc := testMessageDescriptor(t).ParentFile().Messages().ByName("UpdateRequest")
+
+	msg := dynamicpb.NewMessage(desc)
+	require.False(t, SetRequestFieldMask(msg, []string{"id"}))
+	require.False(t, msg.Has(desc.Fields().ByName("update_mask")))
+}
@@ -0,0 +1,162 @@
+package app
+
+import (
+	"encoding/base64"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/dynamicpb"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// SetParams sets query and path parameters into message.
+// Keys are field paths (a.b.c) by proto or json names,
+// repeated fields take all values of key.
+// Unknown keys are skipped: clients add their own params (_=123, utm_*, access_token)
+func SetParams(msg protoreflect.Message, params url.Values) error {
+	for key, values := range params {
+		if err := setParam(msg, key, values); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func setParam(msg protoreflect.Message, key string, values []string) error {
+	parts := strings.Split(key, ".")
+
+	// Path is checked before nested messages are made
+	fields := make([]protoreflect.FieldDescriptor, len(parts))
+	desc := msg.Descriptor()
+	for i, name := range parts {
+		fd := findField(desc, name)
+		if fd == nil {
+			return nil
+		}
+		if i < len(parts)-1 {
+			if fd.Message() == nil || fd.IsList() || fd.IsMap() {
+				return ValidationError{Path: key, Message: "field " + name + " is not a message"}
+			}
+			desc = fd.Message()
+		}
+		fields[i] = fd
+	}
+
+	for _, fd := range fields[:len(fields)-1] {
+		msg = msg.Mutable(fd).Message()
+	}
+	fd := fields[len(fields)-1]
+	switch {
+	case fd.IsMap():
+		return ValidationError{Path: key, Message: "map fields are not supported in parameters"}
+	case fd.IsList():
+		list := msg.Mutable(fd).List()
+		for _, v := range values {
+			value, err := parseParam(fd, v)
+			if err != nil {
+				return ValidationError{Path: key, Message: err.Error()}
+			}
+			list.Append(value)
+		}
+	default:
+		value, err := parseParam(fd, values[len(values)-1])
+		if err != nil {
+			return ValidationError{Path: key, Message: err.Error()}
+		}
+		msg.Set(fd, value)
+	}
+	return nil
+}
+
+func findField(desc protoreflect.MessageDescriptor, name string) protoreflect.FieldDescriptor {
+	if fd := desc.Fields().ByName(protoreflect.Name(name)); fd != nil {
+		return fd
+	}
+	return desc.Fields().ByJSONName(name)
+}
+
+func parseParam(fd protoreflect.FieldDescriptor, v string) (protoreflect.Value, error) {
+	switch fd.Kind() {
+	case protoreflect.BoolKind:
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return protoreflect.Value{}, paramTypeError("bool")
+		}
+		return protoreflect.ValueOfBool(b), nil
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind:
+		n, err := strconv.ParseInt(v, 10, 32)
+		if err != nil {
+			return protoreflect.Value{}, paramTypeError("int32")
+		}
+		return protoreflect.ValueOfInt32(int32(n)), nil
+	case protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind:
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return protoreflect.Value{}, paramTypeError("int64")
+		}
+		return protoreflect.ValueOfInt64(n), nil
+	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind:
+		n, err := strconv.ParseUint(v, 10, 32)
+		if err != nil {
+			return protoreflect.Value{}, paramTypeError("uint32")
+		}
+		return protoreflect.ValueOfUint32(uint32(n)), nil
+	case protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		n, err := strconv.ParseUint(v, 10, 64)
+		if err != nil {
+			return protoreflect.Value{}, paramTypeError("uint64")
+		}
+		return protoreflect.ValueOfUint64(n), nil
+	case protoreflect.FloatKind:
+		f, err := strconv.ParseFloat(v, 32)
+		if err != nil {
+			return protoreflect.Value{}, paramTypeError("float")
+		}
+		return protoreflect.ValueOfFloat32(float32(f)), nil
+	case protoreflect.DoubleKind:
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return protoreflect.Value{}, paramTypeError("double")
+		}
+		return protoreflect.ValueOfFloat64(f), nil
+	case protoreflect.StringKind:
+		return protoreflect.ValueOfString(v), nil
+	case protoreflect.BytesKind:
+		b, err := base64.StdEncoding.DecodeString(v)
+		if err != nil {
+			b, err = base64.URLEncoding.DecodeString(v)
+		}
+		if err != nil {
+			return protoreflect.Value{}, paramTypeError("base64 bytes")
+		}
+		return protoreflect.ValueOfBytes(b), nil
+	case protoreflect.EnumKind:
+		if ev := fd.Enum().Values().ByName(protoreflect.Name(v)); ev != nil {
+			return protoreflect.ValueOfEnum(ev.Number()), nil
+		}
+		n, err := strconv.ParseInt(v, 10, 32)
+		if err != nil || fd.Enum().Values().ByNumber(protoreflect.EnumNumber(n)) == nil {
+			return protoreflect.Value{}, paramTypeError("enum " + string(fd.Enum().Name()))
+		}
+		return protoreflect.ValueOfEnum(protoreflect.EnumNumber(n)), nil
+	case protoreflect.MessageKind, protoreflect.GroupKind:
+		// Well-known types (Timestamp, Duration, FieldMask, wrappers...) have JSON form
+		msg := dynamicpb.NewMessage(fd.Message())
+		if err := protojson.Unmarshal([]byte(v), msg); err == nil {
+			return protoreflect.ValueOfMessage(msg), nil
+		}
+		msg = dynamicpb.NewMessage(fd.Message())
+		if err := protojson.Unmarshal([]byte(strconv.Quote(v)), msg); err == nil {
+			return protoreflect.ValueOfMessage(msg), nil
+		}
+		return protoreflect.Value{}, paramTypeError(string(fd.Message().FullName()))
+	}
+	return protoreflect.Value{}, paramTypeError(fd.Kind().String())
+}
+
+type paramTypeError string
+
+func (e paramTypeError) Error() string {
+	return "should be " + string(e)
+}
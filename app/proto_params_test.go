@@ -0,0 +1,132 @@
+package app
+
+import (
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+	_ "google.golang.org/protobuf/types/known/fieldmaskpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func testMessageDescriptor(t *testing.T) protoreflect.MessageDescriptor {
+	field := func(name string, number int32, typ descriptorpb.FieldDescriptorProto_Type, label descriptorpb.FieldDescriptorProto_Label, typeName string) *descriptorpb.FieldDescriptorProto {
+		f := &descriptorpb.FieldDescriptorProto{
+			Name:     proto.String(name),
+			JsonName: proto.String(name),
+			Number:   proto.Int32(number),
+			Type:     typ.Enum(),
+			Label:    label.Enum(),
+		}
+		if typeName != "" {
+			f.TypeName = proto.String(typeName)
+		}
+		return f
+	}
+	optional := descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL
+	repeated := descriptorpb.FieldDescriptorProto_LABEL_REPEATED
+
+	file := &descriptorpb.FileDescriptorProto{
+		Name:       proto.String("test.proto"),
+		Package:    proto.String("test"),
+		Syntax:     proto.String("proto3"),
+		Dependency: []string{"google/protobuf/timestamp.proto", "google/protobuf/field_mask.proto"},
+		EnumType: []*descriptorpb.EnumDescriptorProto{{
+			Name: proto.String("Kind"),
+			Value: []*descriptorpb.EnumValueDescriptorProto{
+				{Name: proto.String("UNKNOWN"), Number: proto.Int32(0)},
+				{Name: proto.String("DAILY"), Number: proto.Int32(1)},
+			},
+		}},
+		MessageType: []*descriptorpb.DescriptorProto{
+			{
+				Name: proto.String("Filter"),
+				Field: []*descriptorpb.FieldDescriptorProto{
+					field("from", 1, descriptorpb.FieldDescriptorProto_TYPE_MESSAGE, optional, ".google.protobuf.Timestamp"),
+				},
+			},
+			{
+				Name: proto.String("Request"),
+				Field: []*descriptorpb.FieldDescriptorProto{
+					field("id", 1, descriptorpb.FieldDescriptorProto_TYPE_INT32, optional, ""),
+					field("tags", 2, descriptorpb.FieldDescriptorProto_TYPE_STRING, repeated, ""),
+					field("kind", 3, descriptorpb.FieldDescriptorProto_TYPE_ENUM, optional, ".test.Kind"),
+					field("filter", 4, descriptorpb.FieldDescriptorProto_TYPE_MESSAGE, optional, ".test.Filter"),
+					field("filters", 5, descriptorpb.FieldDescriptorProto_TYPE_MESSAGE, repeated, ".test.Filter"),
+					field("read_mask", 6, descriptorpb.FieldDescriptorProto_TYPE_MESSAGE, optional, ".google.protobuf.FieldMask"),
+				},
+			},
+			{
+				Name: proto.String("UpdateRequest"),
+				Field: []*descriptorpb.FieldDescriptorProto{
+					field("id", 1, descriptorpb.FieldDescriptorProto_TYPE_INT32, optional, ""),
+					field("update_mask", 2, descriptorpb.FieldDescriptorProto_TYPE_MESSAGE, optional, ".google.protobuf.FieldMask"),
+				},
+			},
+		},
+	}
+	fd, err := protodesc.NewFile(file, protoregistry.GlobalFiles)
+	require.NoError(t, err)
+	return fd.Messages().ByName("Request")
+}
+
+func TestSetParams(t *testing.T) {
+	desc := testMessageDescriptor(t)
+	msg := dynamicpb.NewMessage(desc)
+
+	err := SetParams(msg, url.Values{
+		"id":          {"5"},
+		"tags":        {"a", "b"},
+		"kind":        {"DAILY"},
+		"filter.from": {"2023-02-10T17:49:29Z"},
+	})
+	require.NoError(t, err)
+
+	fields := desc.Fields()
+	require.Equal(t, int32(5), int32(msg.Get(fields.ByName("id")).Int()))
+	require.Equal(t, 2, msg.Get(fields.ByName("tags")).List().Len())
+	require.Equal(t, protoreflect.EnumNumber(1), msg.Get(fields.ByName("kind")).Enum())
+
+	filter := msg.Get(fields.ByName("filter")).Message()
+	from := filter.Get(filter.Descriptor().Fields().ByName("from")).Message()
+	seconds := from.Get(from.Descriptor().Fields().ByName("seconds")).Int()
+	require.Equal(t, timestamppb.New(mustTime(t, "2023-02-10T17:49:29Z")).Seconds, seconds)
+}
+
+func TestSetParamsValidation(t *testing.T) {
+	desc := testMessageDescriptor(t)
+
+	err := SetParams(dynamicpb.NewMessage(desc), url.Values{"id": {"abc"}})
+	require.True(t, IsValidationError(err))
+
+	err = SetParams(dynamicpb.NewMessage(desc), url.Values{"id.value": {"1"}})
+	require.True(t, IsValidationError(err))
+}
+
+func TestSetParamsSkipsUnknown(t *testing.T) {
+	desc := testMessageDescriptor(t)
+	msg := dynamicpb.NewMessage(desc)
+
+	err := SetParams(msg, url.Values{
+		"id":           {"5"},
+		"_":            {"1676051369"},
+		"utm_source":   {"mail"},
+		"access_token": {"secret"},
+		"filter.to":    {"2023-02-10T17:49:29Z"},
+	})
+	require.NoError(t, err)
+	require.Equal(t, int32(5), int32(msg.Get(desc.Fields().ByName("id")).Int()))
+	require.False(t, msg.Has(desc.Fields().ByName("filter")))
+}
+
+func mustTime(t *testing.T, v string) time.Time {
+	r, err := time.Parse(time.RFC3339, v)
+	require.NoError(t, err)
+	return r
+}
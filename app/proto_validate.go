@@ -0,0 +1,284 @@
+package app
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// ValidateJson checks JSON body against request descriptor and returns each incorrect field,
+// so client sees all mistakes at once instead of first protojson error
+func ValidateJson(desc protoreflect.MessageDescriptor, data []byte, discardUnknown bool) ValidationErrors {
+	if len(bytes.TrimSpace(data)) == 0 {
+		return nil
+	}
+
+	var value interface{}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err := decoder.Decode(&value); err != nil {
+		return ValidationErrors{{Message: "invalid JSON: " + err.Error()}}
+	}
+	obj, ok := value.(map[string]interface{})
+	if !ok {
+		return ValidationErrors{{Message: "request should be JSON object"}}
+	}
+
+	v := &jsonValidator{discardUnknown: discardUnknown}
+	v.message(desc, obj, "")
+	return v.errs
+}
+
+type jsonValidator struct {
+	discardUnknown bool
+	errs           ValidationErrors
+}
+
+func (v *jsonValidator) fail(path, msg string) {
+	v.errs = append(v.errs, ValidationError{Path: path, Message: msg})
+}
+
+func (v *jsonValidator) message(desc protoreflect.MessageDescriptor, obj map[string]interface{}, path string) {
+	for key, value := range obj {
+		fieldPath := joinPath(path, key)
+		fd := findField(desc, key)
+		if fd == nil {
+			if !v.discardUnknown {
+				v.fail(fieldPath, "unknown field")
+			}
+			continue
+		}
+		if value == nil {
+			continue // null is default value
+		}
+
+		switch {
+		case fd.IsMap():
+			items, ok := value.(map[string]interface{})
+			if !ok {
+				v.fail(fieldPath, "should be an object")
+				continue
+			}
+			for k, item := range items {
+				itemPath := fmt.Sprintf("%s[%s]", fieldPath, k)
+				if msg := jsonMapKeyError(fd.MapKey(), k); msg != "" {
+					v.fail(itemPath, msg)
+				}
+				if item != nil {
+					v.value(fd.MapValue(), item, itemPath)
+				}
+			}
+		case fd.IsList():
+			items, ok := value.([]interface{})
+			if !ok {
+				v.fail(fieldPath, "should be an array")
+				continue
+			}
+			for i, item := range items {
+				if item != nil {
+					v.value(fd, item, fmt.Sprintf("%s[%d]", fieldPath, i))
+				}
+			}
+		default:
+			v.value(fd, value, fieldPath)
+		}
+	}
+}
+
+func (v *jsonValidator) value(fd protoreflect.FieldDescriptor, value interface{}, path string) {
+	switch fd.Kind() {
+	case protoreflect.BoolKind:
+		if _, ok := value.(bool); !ok {
+			v.fail(path, "should be a boolean")
+		}
+	case protoreflect.StringKind:
+		if _, ok := value.(string); !ok {
+			v.fail(path, "should be a string")
+		}
+	case protoreflect.BytesKind:
+		s, ok := value.(string)
+		if !ok {
+			v.fail(path, "should be a base64 string")
+		} else if !isBase64(s) {
+			v.fail(path, "invalid base64")
+		}
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind:
+		if msg := jsonIntError(value, 32, true); msg != "" {
+			v.fail(path, msg)
+		}
+	case protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind:
+		if msg := jsonIntError(value, 64, true); msg != "" {
+			v.fail(path, msg)
+		}
+	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind:
+		if msg := jsonIntError(value, 32, false); msg != "" {
+			v.fail(path, msg)
+		}
+	case protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		if msg := jsonIntError(value, 64, false); msg != "" {
+			v.fail(path, msg)
+		}
+	case protoreflect.FloatKind, protoreflect.DoubleKind:
+		if msg := jsonFloatError(value); msg != "" {
+			v.fail(path, msg)
+		}
+	case protoreflect.EnumKind:
+		if msg := jsonEnumError(fd.Enum(), value); msg != "" {
+			v.fail(path, msg)
+		}
+	case protoreflect.MessageKind, protoreflect.GroupKind:
+		v.wellKnownOrMessage(fd.Message(), value, path)
+	}
+}
+
+func (v *jsonValidator) wellKnownOrMessage(desc protoreflect.MessageDescriptor, value interface{}, path string) {
+	switch desc.FullName() {
+	case "google.protobuf.Timestamp", "google.protobuf.Duration", "google.protobuf.FieldMask":
+		if _, ok := value.(string); !ok {
+			v.fail(path, "should be a string")
+		}
+		return
+	case "google.protobuf.Value":
+		return
+	case "google.protobuf.ListValue":
+		if _, ok := value.([]interface{}); !ok {
+			v.fail(path, "should be an array")
+		}
+		return
+	case "google.protobuf.Struct", "google.protobuf.Any", "google.protobuf.Empty":
+		if _, ok := value.(map[string]interface{}); !ok {
+			v.fail(path, "should be an object")
+		}
+		return
+	}
+
+	// Wrappers are values of their "value" field
+	if desc.ParentFile() != nil && desc.ParentFile().Package() == "google.protobuf" && strings.HasSuffix(string(desc.Name()), "Value") {
+		if fd := desc.Fields().ByName("value"); fd != nil {
+			v.value(fd, value, path)
+			return
+		}
+	}
+
+	obj, ok := value.(map[string]interface{})
+	if !ok {
+		v.fail(path, "should be an object")
+		return
+	}
+	v.message(desc, obj, path)
+}
+
+func jsonIntError(value interface{}, bits int, signed bool) string {
+	var s string
+	switch value := value.(type) {
+	case json.Number:
+		s = value.String()
+	case string:
+		s = value
+	default:
+		return "should be an integer"
+	}
+
+	var err error
+	if signed {
+		_, err = strconv.ParseInt(s, 10, bits)
+	} else {
+		_, err = strconv.ParseUint(s, 10, bits)
+	}
+	if err == nil {
+		return ""
+	}
+	if numErr, ok := err.(*strconv.NumError); ok && numErr.Err == strconv.ErrRange {
+		return "out of range"
+	}
+
+	// Exponent form of integer (1e3)
+	f, ferr := strconv.ParseFloat(s, 64)
+	if ferr != nil || f != math.Trunc(f) {
+		return "should be an integer"
+	}
+	if !signed && f < 0 {
+		return "out of range"
+	}
+	limit := math.Ldexp(1, bits)
+	if signed {
+		limit /= 2
+	}
+	if f >= limit || f < -limit {
+		return "out of range"
+	}
+	return ""
+}
+
+func jsonFloatError(value interface{}) string {
+	switch value := value.(type) {
+	case json.Number:
+		return ""
+	case string:
+		switch value {
+		case "NaN", "Infinity", "-Infinity":
+			return ""
+		}
+		if _, err := strconv.ParseFloat(value, 64); err != nil {
+			return "should be a number"
+		}
+		return ""
+	default:
+		return "should be a number"
+	}
+}
+
+func jsonEnumError(desc protoreflect.EnumDescriptor, value interface{}) string {
+	switch value := value.(type) {
+	case string:
+		if desc.Values().ByName(protoreflect.Name(value)) == nil {
+			return "unknown value " + value + " of " + string(desc.FullName())
+		}
+	case json.Number:
+		if _, err := strconv.ParseInt(value.String(), 10, 32); err != nil {
+			return "should be an enum number"
+		}
+	default:
+		return "should be a string or number of " + string(desc.FullName())
+	}
+	return ""
+}
+
+func jsonMapKeyError(fd protoreflect.FieldDescriptor, key string) string {
+	switch fd.Kind() {
+	case protoreflect.BoolKind:
+		if key != "true" && key != "false" {
+			return "key should be a boolean"
+		}
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind:
+		return jsonIntError(key, 32, true)
+	case protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind:
+		return jsonIntError(key, 64, true)
+	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind:
+		return jsonIntError(key, 32, false)
+	case protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		return jsonIntError(key, 64, false)
+	}
+	return ""
+}
+
+func isBase64(s string) bool {
+	for _, enc := range []*base64.Encoding{base64.StdEncoding, base64.URLEncoding, base64.RawStdEncoding, base64.RawURLEncoding} {
+		if _, err := enc.DecodeString(s); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+func joinPath(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
@@ -0,0 +1,659 @@
+package app
+
+import (
+	"fmt"
+	"github.com/pkg/errors"
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"math"
+	"net/mail"
+	"regexp"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// Field options of protoc-gen-validate (validate.rules) and buf-validate (buf.validate.field).
+// Their packages are not linked, so rules are read from unknown bytes of options.
+const (
+	pgvRulesField = 1071
+	bufRulesField = 1159
+)
+
+var uuidPattern = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+// ValidateMessage checks field constraints of message and its nested messages
+func ValidateMessage(msg protoreflect.Message) ValidationErrors {
+	var errs ValidationErrors
+	validateMessage(msg, "", &errs)
+	return errs
+}
+
+func validateMessage(msg protoreflect.Message, path string, errs *ValidationErrors) {
+	fields := msg.Descriptor().Fields()
+	for i := 0; i < fields.Len(); i++ {
+		fd := fields.Get(i)
+		fieldPath := joinPath(path, string(fd.Name()))
+		rules, err := fieldRulesOf(fd)
+		if err != nil {
+			// Broken rules don`t pass any value (registry checks them on load)
+			*errs = append(*errs, ValidationError{Path: fieldPath, Message: err.Error()})
+			continue
+		}
+		if rules != nil && rules.skip {
+			continue
+		}
+
+		if rules != nil && rules.required && !msg.Has(fd) {
+			*errs = append(*errs, ValidationError{Path: fieldPath, Message: "value is required"})
+			continue
+		}
+		// Unset field with presence is not checked (proto3 scalars are checked with zero value)
+		if fd.HasPresence() && !msg.Has(fd) {
+			continue
+		}
+		value := msg.Get(fd)
+
+		switch {
+		case fd.IsList():
+			list := value.List()
+			if rules != nil {
+				rules.checkList(fd, list, fieldPath, errs)
+			}
+			for j := 0; j < list.Len(); j++ {
+				itemPath := fmt.Sprintf("%s[%d]", fieldPath, j)
+				if rules != nil && rules.items != nil {
+					rules.items.checkValue(fd, list.Get(j), itemPath, errs)
+				}
+				if fd.Message() != nil {
+					validateMessage(list.Get(j).Message(), itemPath, errs)
+				}
+			}
+		case fd.IsMap():
+			m := value.Map()
+			if rules != nil {
+				rules.checkMap(m, fieldPath, errs)
+			}
+			if fd.MapValue().Message() != nil {
+				m.Range(func(k protoreflect.MapKey, v protoreflect.Value) bool {
+					validateMessage(v.Message(), fmt.Sprintf("%s[%s]", fieldPath, k.String()), errs)
+					return true
+				})
+			}
+		default:
+			if rules != nil {
+				rules.checkValue(fd, value, fieldPath, errs)
+			}
+			if fd.Message() != nil {
+				validateMessage(value.Message(), fieldPath, errs)
+			}
+		}
+	}
+}
+
+// fieldRules is supported subset of validate.FieldRules and buf.validate.FieldConstraints
+type fieldRules struct {
+	required bool
+	skip     bool
+
+	number *numberRules
+	str    *stringRules
+	bytes  *lenRules
+	enum   *enumRules
+
+	// repeated and map
+	minItems, maxItems *uint64
+	unique             bool
+	items              *fieldRules
+}
+
+type numberRules struct {
+	constant, lt, lte, gt, gte *float64
+	in, notIn                  []float64
+}
+
+type stringRules struct {
+	lenRules
+	constant                 *string
+	pattern                  *regexp.Regexp
+	prefix, suffix, contains *string
+	in, notIn                []string
+	email, uuid              bool
+}
+
+type lenRules struct {
+	length, minLen, maxLen *uint64
+}
+
+type enumRules struct {
+	constant    *float64
+	definedOnly bool
+	in, notIn   []float64
+}
+
+// cachedFieldRules are parsed rules of field descriptor (nil - field has no rules)
+type cachedFieldRules struct {
+	rules *fieldRules
+	err   error
+}
+
+// fieldRulesCache is protoreflect.FieldDescriptor -> *cachedFieldRules
+var fieldRulesCache sync.Map
+
+// fieldRulesOf returns rules of field, they are parsed once per descriptor
+func fieldRulesOf(fd protoreflect.FieldDescriptor) (*fieldRules, error) {
+	if cached, ok := fieldRulesCache.Load(fd); ok {
+		return cached.(*cachedFieldRules).rules, cached.(*cachedFieldRules).err
+	}
+	rules, err := parseFieldOptions(fd)
+	if err != nil {
+		err = errors.Wrapf(err, "invalid validation rules of %s", fd.FullName())
+	}
+	fieldRulesCache.Store(fd, &cachedFieldRules{rules: rules, err: err})
+	return rules, err
+}
+
+// CheckValidationRules parses rules of message`s fields (and of nested messages) and returns the first broken one
+func CheckValidationRules(md protoreflect.MessageDescriptor) error {
+	return checkValidationRules(md, map[protoreflect.FullName]bool{})
+}
+
+func checkValidationRules(md protoreflect.MessageDescriptor, seen map[protoreflect.FullName]bool) error {
+	if seen[md.FullName()] {
+		return nil
+	}
+	seen[md.FullName()] = true
+
+	fields := md.Fields()
+	for i := 0; i < fields.Len(); i++ {
+		fd := fields.Get(i)
+		if _, err := fieldRulesOf(fd); err != nil {
+			return err
+		}
+		if fd.IsMap() {
+			fd = fd.MapValue()
+		}
+		if fd.Message() != nil {
+			if err := checkValidationRules(fd.Message(), seen); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func parseFieldOptions(fd protoreflect.FieldDescriptor) (*fieldRules, error) {
+	opts, ok := fd.Options().(*descriptorpb.FieldOptions)
+	if !ok || opts == nil {
+		return nil, nil
+	}
+	unknown := opts.ProtoReflect().GetUnknown()
+	var rules *fieldRules
+	for len(unknown) > 0 {
+		num, typ, n := protowire.ConsumeTag(unknown)
+		if n < 0 {
+			return rules, nil
+		}
+		unknown = unknown[n:]
+		if (num == pgvRulesField || num == bufRulesField) && typ == protowire.BytesType {
+			b, n := protowire.ConsumeBytes(unknown)
+			if n < 0 {
+				return rules, nil
+			}
+			var err error
+			if rules, err = parseFieldRules(b, num == bufRulesField); err != nil {
+				return nil, err
+			}
+			unknown = unknown[n:]
+			continue
+		}
+		n = protowire.ConsumeFieldValue(num, typ, unknown)
+		if n < 0 {
+			return rules, nil
+		}
+		unknown = unknown[n:]
+	}
+	return rules, nil
+}
+
+func parseFieldRules(b []byte, buf bool) (*fieldRules, error) {
+	rules := &fieldRules{}
+	var err error
+	rangeFields(b, func(num protowire.Number, typ protowire.Type, v uint64, data []byte) {
+		if err != nil {
+			return
+		}
+		switch {
+		case num >= 1 && num <= 12:
+			rules.number = parseNumberRules(data, num)
+		case num == 14:
+			rules.str, err = parseStringRules(data)
+		case num == 15:
+			rules.bytes = parseBytesRules(data)
+		case num == 16:
+			rules.enum = parseEnumRules(data)
+		case num == 17 && !buf:
+			// validate.MessageRules
+			rangeFields(data, func(num protowire.Number, _ protowire.Type, v uint64, _ []byte) {
+				switch num {
+				case 1:
+					rules.skip = v != 0
+				case 2:
+					rules.required = v != 0
+				}
+			})
+		case num == 18:
+			// RepeatedRules
+			rangeFields(data, func(num protowire.Number, _ protowire.Type, v uint64, data []byte) {
+				switch num {
+				case 1:
+					rules.minItems = &v
+				case 2:
+					rules.maxItems = &v
+				case 3:
+					rules.unique = v != 0
+				case 4:
+					if rules.items, err = parseFieldRules(data, buf); err != nil {
+						err = errors.Wrap(err, "items")
+					}
+				}
+			})
+		case num == 19:
+			// MapRules
+			rangeFields(data, func(num protowire.Number, _ protowire.Type, v uint64, _ []byte) {
+				switch num {
+				case 1:
+					rules.minItems = &v
+				case 2:
+					rules.maxItems = &v
+				}
+			})
+		case num == 25 && buf:
+			rules.required = v != 0
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	return rules, nil
+}
+
+// rangeFields calls f with each field of encoded message, packed values are unpacked
+func rangeFields(b []byte, f func(num protowire.Number, typ protowire.Type, v uint64, data []byte)) {
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return
+		}
+		b = b[n:]
+		switch typ {
+		case protowire.VarintType:
+			v, n := protowire.ConsumeVarint(b)
+			if n < 0 {
+				return
+			}
+			f(num, typ, v, nil)
+			b = b[n:]
+		case protowire.Fixed32Type:
+			v, n := protowire.ConsumeFixed32(b)
+			if n < 0 {
+				return
+			}
+			f(num, typ, uint64(v), nil)
+			b = b[n:]
+		case protowire.Fixed64Type:
+			v, n := protowire.ConsumeFixed64(b)
+			if n < 0 {
+				return
+			}
+			f(num, typ, v, nil)
+			b = b[n:]
+		case protowire.BytesType:
+			v, n := protowire.ConsumeBytes(b)
+			if n < 0 {
+				return
+			}
+			f(num, typ, 0, v)
+			b = b[n:]
+		default:
+			n := protowire.ConsumeFieldValue(num, typ, b)
+			if n < 0 {
+				return
+			}
+			b = b[n:]
+		}
+	}
+}
+
+// ruleNumber decodes scalar of rules type (1 - FloatRules ... 12 - SFixed64Rules)
+func ruleNumber(rulesType protowire.Number, v uint64) float64 {
+	switch rulesType {
+	case 1:
+		return float64(math.Float32frombits(uint32(v)))
+	case 2:
+		return math.Float64frombits(v)
+	case 3, 11:
+		return float64(int32(v))
+	case 4, 12:
+		return float64(int64(v))
+	case 7:
+		return float64(int32(protowire.DecodeZigZag(v & math.MaxUint32)))
+	case 8:
+		return float64(protowire.DecodeZigZag(v))
+	default:
+		return float64(v)
+	}
+}
+
+// unpack returns scalars of field, packed repeated fields come as bytes
+func unpack(typ protowire.Type, v uint64, data []byte) []uint64 {
+	if data == nil {
+		return []uint64{v}
+	}
+	var res []uint64
+	for len(data) > 0 {
+		var n int
+		switch typ {
+		case protowire.Fixed32Type:
+			var x uint32
+			x, n = protowire.ConsumeFixed32(data)
+			v = uint64(x)
+		case protowire.Fixed64Type:
+			v, n = protowire.ConsumeFixed64(data)
+		default:
+			v, n = protowire.ConsumeVarint(data)
+		}
+		if n < 0 {
+			return res
+		}
+		res = append(res, v)
+		data = data[n:]
+	}
+	return res
+}
+
+func parseNumberRules(b []byte, rulesType protowire.Number) *numberRules {
+	// Wire type of packed values
+	typ := protowire.VarintType
+	switch rulesType {
+	case 1, 9, 11:
+		typ = protowire.Fixed32Type
+	case 2, 10, 12:
+		typ = protowire.Fixed64Type
+	}
+
+	rules := &numberRules{}
+	rangeFields(b, func(num protowire.Number, _ protowire.Type, v uint64, data []byte) {
+		values := unpack(typ, v, data)
+		if len(values) == 0 {
+			return
+		}
+		x := ruleNumber(rulesType, values[0])
+		switch num {
+		case 1:
+			rules.constant = &x
+		case 2:
+			rules.lt = &x
+		case 3:
+			rules.lte = &x
+		case 4:
+			rules.gt = &x
+		case 5:
+			rules.gte = &x
+		case 6, 7:
+			for _, v := range values {
+				if num == 6 {
+					rules.in = append(rules.in, ruleNumber(rulesType, v))
+				} else {
+					rules.notIn = append(rules.notIn, ruleNumber(rulesType, v))
+				}
+			}
+		}
+	})
+	return rules
+}
+
+func parseStringRules(b []byte) (*stringRules, error) {
+	rules := &stringRules{}
+	var err error
+	rangeFields(b, func(num protowire.Number, _ protowire.Type, v uint64, data []byte) {
+		s := string(data)
+		switch num {
+		case 1:
+			rules.constant = &s
+		case 19:
+			rules.length = &v
+		case 2:
+			rules.minLen = &v
+		case 3:
+			rules.maxLen = &v
+		case 6:
+			var patternErr error
+			if rules.pattern, patternErr = regexp.Compile(s); patternErr != nil {
+				err = errors.Wrapf(patternErr, "pattern %q", s)
+			}
+		case 7:
+			rules.prefix = &s
+		case 8:
+			rules.suffix = &s
+		case 9:
+			rules.contains = &s
+		case 10:
+			rules.in = append(rules.in, s)
+		case 11:
+			rules.notIn = append(rules.notIn, s)
+		case 12:
+			rules.email = v != 0
+		case 22:
+			rules.uuid = v != 0
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	return rules, nil
+}
+
+func parseBytesRules(b []byte) *lenRules {
+	rules := &lenRules{}
+	rangeFields(b, func(num protowire.Number, _ protowire.Type, v uint64, _ []byte) {
+		switch num {
+		case 13:
+			rules.length = &v
+		case 2:
+			rules.minLen = &v
+		case 3:
+			rules.maxLen = &v
+		}
+	})
+	return rules
+}
+
+func parseEnumRules(b []byte) *enumRules {
+	rules := &enumRules{}
+	rangeFields(b, func(num protowire.Number, typ protowire.Type, v uint64, data []byte) {
+		values := unpack(protowire.VarintType, v, data)
+		if len(values) == 0 {
+			return
+		}
+		x := float64(int32(values[0]))
+		switch num {
+		case 1:
+			rules.constant = &x
+		case 2:
+			rules.definedOnly = v != 0
+		case 3, 4:
+			for _, v := range values {
+				if num == 3 {
+					rules.in = append(rules.in, float64(int32(v)))
+				} else {
+					rules.notIn = append(rules.notIn, float64(int32(v)))
+				}
+			}
+		}
+	})
+	return rules
+}
+
+func (r *fieldRules) checkValue(fd protoreflect.FieldDescriptor, value protoreflect.Value, path string, errs *ValidationErrors) {
+	fail := func(format string, args ...interface{}) {
+		*errs = append(*errs, ValidationError{Path: path, Message: fmt.Sprintf(format, args...)})
+	}
+
+	switch fd.Kind() {
+	case protoreflect.StringKind:
+		if r.str != nil {
+			r.str.check(value.String(), fail)
+		}
+	case protoreflect.BytesKind:
+		if r.bytes != nil {
+			r.bytes.check(uint64(len(value.Bytes())), "bytes", fail)
+		}
+	case protoreflect.EnumKind:
+		if r.enum != nil {
+			r.enum.check(fd.Enum(), value.Enum(), fail)
+		}
+	case protoreflect.MessageKind, protoreflect.GroupKind, protoreflect.BoolKind:
+	default:
+		if r.number != nil {
+			r.number.check(numberValue(fd, value), fail)
+		}
+	}
+}
+
+func (r *fieldRules) checkList(fd protoreflect.FieldDescriptor, list protoreflect.List, path string, errs *ValidationErrors) {
+	n := uint64(list.Len())
+	if r.minItems != nil && n < *r.minItems {
+		*errs = append(*errs, ValidationError{Path: path, Message: fmt.Sprintf("value must contain at least %d item(s)", *r.minItems)})
+	}
+	if r.maxItems != nil && n > *r.maxItems {
+		*errs = append(*errs, ValidationError{Path: path, Message: fmt.Sprintf("value must contain no more than %d item(s)", *r.maxItems)})
+	}
+	if r.unique && fd.Message() == nil {
+		seen := map[interface{}]bool{}
+		for i := 0; i < list.Len(); i++ {
+			key := list.Get(i).Interface()
+			if b, ok := key.([]byte); ok {
+				key = string(b)
+			}
+			if seen[key] {
+				*errs = append(*errs, ValidationError{Path: path, Message: "repeated value must contain unique items"})
+				return
+			}
+			seen[key] = true
+		}
+	}
+}
+
+func (r *fieldRules) checkMap(m protoreflect.Map, path string, errs *ValidationErrors) {
+	n := uint64(m.Len())
+	if r.minItems != nil && n < *r.minItems {
+		*errs = append(*errs, ValidationError{Path: path, Message: fmt.Sprintf("value must contain at least %d pair(s)", *r.minItems)})
+	}
+	if r.maxItems != nil && n > *r.maxItems {
+		*errs = append(*errs, ValidationError{Path: path, Message: fmt.Sprintf("value must contain no more than %d pair(s)", *r.maxItems)})
+	}
+}
+
+func numberValue(fd protoreflect.FieldDescriptor, value protoreflect.Value) float64 {
+	switch fd.Kind() {
+	case protoreflect.FloatKind, protoreflect.DoubleKind:
+		return value.Float()
+	case protoreflect.Uint32Kind, protoreflect.Uint64Kind, protoreflect.Fixed32Kind, protoreflect.Fixed64Kind:
+		return float64(value.Uint())
+	default:
+		return float64(value.Int())
+	}
+}
+
+func (r *numberRules) check(x float64, fail func(string, ...interface{})) {
+	switch {
+	case r.constant != nil && x != *r.constant:
+		fail("value must equal %v", *r.constant)
+	case r.lt != nil && !(x < *r.lt):
+		fail("value must be less than %v", *r.lt)
+	case r.lte != nil && !(x <= *r.lte):
+		fail("value must be less than or equal to %v", *r.lte)
+	case r.gt != nil && !(x > *r.gt):
+		fail("value must be greater than %v", *r.gt)
+	case r.gte != nil && !(x >= *r.gte):
+		fail("value must be greater than or equal to %v", *r.gte)
+	case len(r.in) > 0 && !containsNumber(r.in, x):
+		fail("value must be in list %v", r.in)
+	case containsNumber(r.notIn, x):
+		fail("value must not be in list %v", r.notIn)
+	}
+}
+
+func (r *lenRules) check(n uint64, unit string, fail func(string, ...interface{})) {
+	switch {
+	case r.length != nil && n != *r.length:
+		fail("value length must be %d %s", *r.length, unit)
+	case r.minLen != nil && n < *r.minLen:
+		fail("value length must be at least %d %s", *r.minLen, unit)
+	case r.maxLen != nil && n > *r.maxLen:
+		fail("value length must be at most %d %s", *r.maxLen, unit)
+	}
+}
+
+func (r *stringRules) check(s string, fail func(string, ...interface{})) {
+	r.lenRules.check(uint64(utf8.RuneCountInString(s)), "characters", fail)
+	switch {
+	case r.constant != nil && s != *r.constant:
+		fail("value must equal %s", *r.constant)
+	case r.pattern != nil && !r.pattern.MatchString(s):
+		fail("value does not match regex pattern %q", r.pattern.String())
+	case r.prefix != nil && !strings.HasPrefix(s, *r.prefix):
+		fail("value does not have prefix %q", *r.prefix)
+	case r.suffix != nil && !strings.HasSuffix(s, *r.suffix):
+		fail("value does not have suffix %q", *r.suffix)
+	case r.contains != nil && !strings.Contains(s, *r.contains):
+		fail("value does not contain substring %q", *r.contains)
+	case len(r.in) > 0 && !containsString(r.in, s):
+		fail("value must be in list %v", r.in)
+	case containsString(r.notIn, s):
+		fail("value must not be in list %v", r.notIn)
+	case r.email && !isEmail(s):
+		fail("value must be a valid email address")
+	case r.uuid && !uuidPattern.MatchString(s):
+		fail("value must be a valid UUID")
+	}
+}
+
+func (r *enumRules) check(desc protoreflect.EnumDescriptor, v protoreflect.EnumNumber, fail func(string, ...interface{})) {
+	x := float64(v)
+	switch {
+	case r.constant != nil && x != *r.constant:
+		fail("value must equal %v", *r.constant)
+	case r.definedOnly && desc.Values().ByNumber(v) == nil:
+		fail("value must be one of the defined enum values")
+	case len(r.in) > 0 && !containsNumber(r.in, x):
+		fail("value must be in list %v", r.in)
+	case containsNumber(r.notIn, x):
+		fail("value must not be in list %v", r.notIn)
+	}
+}
+
+func containsNumber(list []float64, x float64) bool {
+	for _, v := range list {
+		if v == x {
+			return true
+		}
+	}
+	return false
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+func isEmail(s string) bool {
+	addr, err := mail.ParseAddress(s)
+	return err == nil && addr.Address == s
+}
@@ -0,0 +1,196 @@
+package app
+
+import (
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+	"testing"
+)
+
+func TestValidateJson(t *testing.T) {
+	desc := testMessageDescriptor(t)
+
+	errs := ValidateJson(desc, []byte(`{"id": "abc", "tags": [1], "kind": "WEEKLY", "filter": {"from": 5}, "other": 1}`), false)
+	paths := map[string]string{}
+	for _, err := range errs {
+		paths[err.Path] = err.Message
+	}
+	require.Len(t, paths, 5)
+	require.Contains(t, paths, "id")
+	require.Contains(t, paths, "tags[0]")
+	require.Contains(t, paths, "kind")
+	require.Contains(t, paths, "filter.from")
+	require.Equal(t, "unknown field", paths["other"])
+
+	require.Empty(t, ValidateJson(desc, []byte(`{"id": "5", "tags": ["a"], "kind": 1, "other": 1}`), true))
+	require.Len(t, ValidateJson(desc, []byte(`{"id": 3000000000}`), false), 1)
+	require.Len(t, ValidateJson(desc, []byte(`[]`), false), 1)
+
+	// Codec returns the same errors
+	err := JsonCodec().Unmarshal([]byte(`{"id": "abc", "tags": [1]}`), dynamicpb.NewMessage(desc))
+	require.Len(t, ValidationFields(err), 2)
+}
+
+// rulesOption encodes validate.rules (or buf.validate.field) with one type of rules
+func rulesOption(extension, rulesType protowire.Number, rules []byte) *descriptorpb.FieldOptions {
+	var fieldRules []byte
+	fieldRules = protowire.AppendTag(fieldRules, rulesType, protowire.BytesType)
+	fieldRules = protowire.AppendBytes(fieldRules, rules)
+
+	var unknown []byte
+	unknown = protowire.AppendTag(unknown, extension, protowire.BytesType)
+	unknown = protowire.AppendBytes(unknown, fieldRules)
+
+	opts := &descriptorpb.FieldOptions{}
+	opts.ProtoReflect().SetUnknown(unknown)
+	return opts
+}
+
+func TestValidateMessage(t *testing.T) {
+	varint := func(num protowire.Number, v uint64) []byte {
+		return protowire.AppendVarint(protowire.AppendTag(nil, num, protowire.VarintType), v)
+	}
+	str := func(num protowire.Number, v string) []byte {
+		return protowire.AppendString(protowire.AppendTag(nil, num, protowire.BytesType), v)
+	}
+
+	optional := descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL
+	repeated := descriptorpb.FieldDescriptorProto_LABEL_REPEATED
+	file := &descriptorpb.FileDescriptorProto{
+		Name:    proto.String("validate_test.proto"),
+		Package: proto.String("test"),
+		Syntax:  proto.String("proto3"),
+		MessageType: []*descriptorpb.DescriptorProto{
+			{
+				Name: proto.String("Item"),
+			},
+			{
+				Name: proto.String("Request"),
+				Field: []*descriptorpb.FieldDescriptorProto{
+					{
+						// int32 gt: 0, lte: 10
+						Name: proto.String("id"), Number: proto.Int32(1), Label: optional.Enum(),
+						Type:    descriptorpb.FieldDescriptorProto_TYPE_INT32.Enum(),
+						Options: rulesOption(pgvRulesField, 3, append(varint(4, 0), varint(3, 10)...)),
+					},
+					{
+						// string min_len: 3, email
+						Name: proto.String("email"), Number: proto.Int32(2), Label: optional.Enum(),
+						Type:    descriptorpb.FieldDescriptorProto_TYPE_STRING.Enum(),
+						Options: rulesOption(bufRulesField, 14, append(varint(2, 3), varint(12, 1)...)),
+					},
+					{
+						// repeated min_items: 1, unique
+						Name: proto.String("tags"), Number: proto.Int32(3), Label: repeated.Enum(),
+						Type:    descriptorpb.FieldDescriptorProto_TYPE_STRING.Enum(),
+						Options: rulesOption(pgvRulesField, 18, append(varint(1, 1), varint(3, 1)...)),
+					},
+					{
+						// message required
+						Name: proto.String("item"), Number: proto.Int32(4), Label: optional.Enum(),
+						Type: descriptorpb.FieldDescriptorProto_TYPE_MESSAGE.Enum(), TypeName: proto.String(".test.Item"),
+						Options: rulesOption(pgvRulesField, 17, varint(2, 1)),
+					},
+					{
+						// string prefix
+						Name: proto.String("code"), Number: proto.Int32(5), Label: optional.Enum(),
+						Type:    descriptorpb.FieldDescriptorProto_TYPE_STRING.Enum(),
+						Options: rulesOption(pgvRulesField, 14, str(7, "c-")),
+					},
+				},
+			},
+		},
+	}
+	fd, err := protodesc.NewFile(file, protoregistry.GlobalFiles)
+	require.NoError(t, err)
+	desc := fd.Messages().ByName("Request")
+
+	msg := dynamicpb.NewMessage(desc)
+	errs := ValidateMessage(msg)
+	paths := map[string]bool{}
+	for _, err := range errs {
+		paths[err.Path] = true
+	}
+	require.Equal(t, map[string]bool{"id": true, "email": true, "tags": true, "item": true, "code": true}, paths)
+
+	fields := desc.Fields()
+	msg.Set(fields.ByName("id"), protoreflect.ValueOfInt32(10))
+	msg.Set(fields.ByName("email"), protoreflect.ValueOfString("user@example.com"))
+	msg.Mutable(fields.ByName("tags")).List().Append(protoreflect.ValueOfString("a"))
+	msg.Mutable(fields.ByName("item"))
+	msg.Set(fields.ByName("code"), protoreflect.ValueOfString("c-1"))
+	require.Empty(t, ValidateMessage(msg))
+
+	msg.Set(fields.ByName("id"), protoreflect.ValueOfInt32(11))
+	msg.Mutable(fields.ByName("tags")).List().Append(protoreflect.ValueOfString("a"))
+	require.Len(t, ValidateMessage(msg), 2)
+}
+
+func TestValidationRulesPattern(t *testing.T) {
+	str := func(num protowire.Number, v string) []byte {
+		return protowire.AppendString(protowire.AppendTag(nil, num, protowire.BytesType), v)
+	}
+	optional := descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL
+	file := &descriptorpb.FileDescriptorProto{
+		Name:    proto.String("validate_pattern_test.proto"),
+		Package: proto.String("test_pattern"),
+		Syntax:  proto.String("proto3"),
+		MessageType: []*descriptorpb.DescriptorProto{
+			{
+				Name: proto.String("Nested"),
+				Field: []*descriptorpb.FieldDescriptorProto{
+					{
+						// string pattern: "(" (invalid)
+						Name: proto.String("broken"), Number: proto.Int32(1), Label: optional.Enum(),
+						Type:    descriptorpb.FieldDescriptorProto_TYPE_STRING.Enum(),
+						Options: rulesOption(pgvRulesField, 14, str(6, "(")),
+					},
+				},
+			},
+			{
+				Name: proto.String("Request"),
+				Field: []*descriptorpb.FieldDescriptorProto{
+					{
+						// string pattern: "^[a-z]+$"
+						Name: proto.String("name"), Number: proto.Int32(1), Label: optional.Enum(),
+						Type:    descriptorpb.FieldDescriptorProto_TYPE_STRING.Enum(),
+						Options: rulesOption(bufRulesField, 14, str(6, "^[a-z]+$")),
+					},
+					{
+						Name: proto.String("nested"), Number: proto.Int32(2), Label: optional.Enum(),
+						Type: descriptorpb.FieldDescriptorProto_TYPE_MESSAGE.Enum(), TypeName: proto.String(".test_pattern.Nested"),
+					},
+				},
+			},
+		},
+	}
+	fd, err := protodesc.NewFile(file, protoregistry.GlobalFiles)
+	require.NoError(t, err)
+	request := fd.Messages().ByName("Request")
+	nested := fd.Messages().ByName("Nested")
+
+	// Rules are parsed once per field
+	name := request.Fields().ByName("name")
+	first, err := fieldRulesOf(name)
+	require.NoError(t, err)
+	second, _ := fieldRulesOf(name)
+	require.Same(t, first, second)
+
+	msg := dynamicpb.NewMessage(request)
+	msg.Set(name, protoreflect.ValueOfString("abc"))
+	require.Empty(t, ValidateMessage(msg))
+	msg.Set(name, protoreflect.ValueOfString("ABC"))
+	require.Len(t, ValidateMessage(msg), 1)
+
+	// Invalid pattern is a descriptor error and doesn`t pass values
+	err = CheckValidationRules(request)
+	require.ErrorContains(t, err, "test_pattern.Nested.broken")
+	errs := ValidateMessage(dynamicpb.NewMessage(nested))
+	require.Len(t, errs, 1)
+	require.Equal(t, "broken", errs[0].Path)
+}
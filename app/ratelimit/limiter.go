@@ -0,0 +1,94 @@
+package ratelimit
+
+import (
+	"context"
+	"github.com/pkg/errors"
+	"github.com/spf13/viper"
+	"time"
+)
+
+// Limit describes a token bucket: Rate tokens per second, Burst - bucket size
+type Limit struct {
+	Rate  float64
+	Burst int
+}
+
+// PerMinute makes limit for n requests per minute with burst
+func PerMinute(n int32, burst int32) Limit {
+	b := int(burst)
+	if b <= 0 {
+		b = int(n)
+	}
+	return Limit{
+		Rate:  float64(n) / 60,
+		Burst: b,
+	}
+}
+
+func (l Limit) IsZero() bool {
+	return l.Rate <= 0 || l.Burst <= 0
+}
+
+// Result of taking one token from bucket
+type Result struct {
+	Allowed    bool
+	Limit      int
+	Remaining  int
+	Reset      time.Duration // time until bucket is full again
+	RetryAfter time.Duration // time until next token (only if not allowed)
+}
+
+// Store keeps buckets state. Memory store is used by default,
+// shared stores (redis, etc.) should implement this interface
+type Store interface {
+	Take(ctx context.Context, key string, limit Limit) (*Result, error)
+}
+
+func NewStore() (Store, error) {
+	driver := viper.GetString("ratelimit.store")
+	switch driver {
+	case "", "memory":
+		return NewMemoryStore(), nil
+	default:
+		return nil, errors.Errorf("unknown rate limit store %s", driver)
+	}
+}
+
+// take is a token bucket step shared by all stores
+func take(b *Bucket, limit Limit, now time.Time) *Result {
+	burst := float64(limit.Burst)
+
+	if b.Last.IsZero() {
+		b.Tokens = burst
+	} else {
+		elapsed := now.Sub(b.Last).Seconds()
+		b.Tokens += elapsed * limit.Rate
+		if b.Tokens > burst {
+			b.Tokens = burst
+		}
+	}
+	b.Last = now
+
+	res := &Result{
+		Limit: limit.Burst,
+	}
+	if b.Tokens >= 1 {
+		b.Tokens--
+		res.Allowed = true
+	} else {
+		res.RetryAfter = seconds((1 - b.Tokens) / limit.Rate)
+	}
+	res.Remaining = int(b.Tokens)
+	res.Reset = seconds((burst - b.Tokens) / limit.Rate)
+	return res
+}
+
+func seconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
+
+// Bucket state
+type Bucket struct {
+	Tokens float64
+	Last   time.Time
+}
@@ -0,0 +1,49 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// MemoryStore keeps buckets in process memory
+type MemoryStore struct {
+	mu      sync.Mutex
+	buckets map[string]*Bucket
+	cleaned time.Time
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		buckets: make(map[string]*Bucket),
+		cleaned: time.Now(),
+	}
+}
+
+func (s *MemoryStore) Take(_ context.Context, key string, limit Limit) (*Result, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	s.cleanup(now)
+
+	b, ok := s.buckets[key]
+	if !ok {
+		b = &Bucket{}
+		s.buckets[key] = b
+	}
+	return take(b, limit, now), nil
+}
+
+// cleanup removes buckets which were not used for a long time (most likely they are full)
+func (s *MemoryStore) cleanup(now time.Time) {
+	if now.Sub(s.cleaned) < time.Minute {
+		return
+	}
+	for k, b := range s.buckets {
+		if now.Sub(b.Last) > 10*time.Minute {
+			delete(s.buckets, k)
+		}
+	}
+	s.cleaned = now
+}
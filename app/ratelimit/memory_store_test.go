@@ -0,0 +1,30 @@
+package ratelimit
+
+import (
+	"context"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestMemoryStoreTake(t *testing.T) {
+	store := NewMemoryStore()
+	limit := PerMinute(60, 2)
+
+	for i := 0; i < 2; i++ {
+		res, err := store.Take(context.Background(), "key", limit)
+		require.NoError(t, err)
+		require.True(t, res.Allowed)
+		require.Equal(t, 1-i, res.Remaining)
+	}
+
+	res, err := store.Take(context.Background(), "key", limit)
+	require.NoError(t, err)
+	require.False(t, res.Allowed)
+	require.True(t, res.RetryAfter > 0 && res.RetryAfter <= time.Second)
+
+	// Other key has its own bucket
+	res, err = store.Take(context.Background(), "other", limit)
+	require.NoError(t, err)
+	require.True(t, res.Allowed)
+}
@@ -0,0 +1,32 @@
+package app
+
+import (
+	"context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"microservice/app/core"
+	"strings"
+)
+
+// withRequestIdMeta adds request id of ctx to outgoing gRPC metadata
+func withRequestIdMeta(ctx context.Context) context.Context {
+	id := core.RequestId(ctx)
+	if id == "" {
+		return ctx
+	}
+	return metadata.AppendToOutgoingContext(ctx, strings.ToLower(core.RequestIdHeader), id)
+}
+
+// RequestIdUnaryInterceptor passes request id to instances
+func RequestIdUnaryInterceptor() grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		return invoker(withRequestIdMeta(ctx), method, req, reply, cc, opts...)
+	}
+}
+
+// RequestIdStreamInterceptor passes request id to instances
+func RequestIdStreamInterceptor() grpc.StreamClientInterceptor {
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		return streamer(withRequestIdMeta(ctx), desc, cc, method, opts...)
+	}
+}
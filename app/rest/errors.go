@@ -41,3 +41,28 @@ func UnauthorizedError() core.StatusResponse {
 		},
 	}
 }
+
+func TooManyRequestsError() core.StatusResponse {
+	return core.StatusResponse{
+		Status: core.Status{
+			Code: core.TooManyRequests,
+		},
+	}
+}
+
+func PayloadTooLargeError() core.StatusResponse {
+	return core.StatusResponse{
+		Status: core.Status{
+			Code: core.PayloadTooLarge,
+		},
+	}
+}
+
+func UnsupportedMediaTypeError(contentType string) core.StatusResponse {
+	return core.StatusResponse{
+		Status: core.Status{
+			Code:    core.UnsupportedMediaType,
+			Message: contentType,
+		},
+	}
+}
@@ -0,0 +1,73 @@
+package rest
+
+import (
+	"github.com/improbable-eng/grpc-web/go/grpcweb"
+	"github.com/pkg/errors"
+	"go.uber.org/dig"
+	"google.golang.org/grpc"
+	"microservice/app"
+	"microservice/app/accesslog"
+	"microservice/app/core"
+	"net/http"
+	"reflect"
+	"runtime"
+	"strings"
+)
+
+var (
+	grpcProxy    *grpc.Server
+	grpcWebProxy *grpcweb.WrappedGrpcServer
+)
+
+// DeliveryGrpcProxy handles any gRPC method which is unknown for gateway
+type DeliveryGrpcProxy interface {
+	Handle(srv interface{}, stream grpc.ServerStream) error
+}
+
+// InitGrpcProxy serves native gRPC and gRPC-Web on rest port
+func InitGrpcProxy(f interface{}) error {
+
+	name := runtime.FuncForPC(reflect.ValueOf(f).Pointer()).Name()
+
+	di := core.GetDI()
+	scope := di.Scope(name)
+
+	err := scope.Provide(f, dig.As(new(DeliveryGrpcProxy)))
+	if err != nil {
+		return errors.Wrap(err, "cannot init grpc proxy delivery")
+	}
+
+	return scope.Invoke(func(d DeliveryGrpcProxy) error {
+		grpcProxy = grpc.NewServer(
+			grpc.ForceServerCodec(app.RawCodec()),
+			grpc.UnknownServiceHandler(d.Handle),
+		)
+		grpcWebProxy = grpcweb.WrapServer(grpcProxy,
+			grpcweb.WithOriginFunc(func(origin string) bool { return true }),
+			grpcweb.WithAllowNonRootResource(true),
+		)
+		return nil
+	})
+}
+
+// handler dispatches gRPC and gRPC-Web requests to proxy, others to gin
+func handler() http.Handler {
+	if grpcProxy == nil {
+		return restServer
+	}
+
+	// gin writes access log of REST requests itself
+	grpcHandler := accesslog.HTTPMiddleware(grpcProxy)
+	grpcWebHandler := accesslog.HTTPMiddleware(grpcWebProxy)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		contentType := r.Header.Get("Content-Type")
+		switch {
+		case grpcWebProxy.IsGrpcWebRequest(r) || grpcWebProxy.IsAcceptableGrpcCorsRequest(r):
+			grpcWebHandler.ServeHTTP(w, r)
+		case r.ProtoMajor == 2 && strings.HasPrefix(contentType, "application/grpc"):
+			grpcHandler.ServeHTTP(w, r)
+		default:
+			restServer.ServeHTTP(w, r)
+		}
+	})
+}
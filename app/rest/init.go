@@ -7,6 +7,7 @@ import (
 	"github.com/spf13/viper"
 	"go.uber.org/dig"
 	"microservice/app/core"
+	"microservice/app/metrics"
 	"net/http"
 	"os"
 	"reflect"
@@ -22,6 +23,12 @@ type DeliveryDynamicService interface {
 	Route(ctx *gin.Context)
 }
 
+// DeliveryMiddlewares is implemented by dynamic deliveries which need
+// middlewares before Route (they are called only for delivery`s path)
+type DeliveryMiddlewares interface {
+	Middlewares() []gin.HandlerFunc
+}
+
 func InitDelivery(path string, f interface{}) error {
 
 	name := runtime.FuncForPC(reflect.ValueOf(f).Pointer()).Name()
@@ -57,20 +64,63 @@ func InitDeliveryDynamic(path string, f interface{}) error {
 	}
 
 	return scope.Invoke(func(d DeliveryDynamicService) error {
-		restServer.NoRoute(func(ctx *gin.Context) {
+		handlers := []gin.HandlerFunc{func(ctx *gin.Context) {
 			url := ctx.Request.URL.Path
-			if strings.HasPrefix(url, path) {
-				url = strings.TrimPrefix(url, path)
-				url = strings.TrimSuffix(url, "/")
-				ctx.Request.URL.Path = url
-				ctx.Status(200) // replacing 404 with 200
-				d.Route(ctx)
+			if !strings.HasPrefix(url, path) {
+				ctx.Abort()
+				return
 			}
-		})
+			url = strings.TrimPrefix(url, path)
+			url = strings.TrimSuffix(url, "/")
+			ctx.Request.URL.Path = url
+			ctx.Status(200) // replacing 404 with 200
+		}}
+		if mw, ok := d.(DeliveryMiddlewares); ok {
+			handlers = append(handlers, mw.Middlewares()...)
+		}
+		handlers = append(handlers, d.Route)
+
+		restServer.NoRoute(handlers...)
 		return nil
 	})
 }
 
+// InitHandler serves path with http handler (e.g. grpc-gateway mux)
+func InitHandler(path string, h http.Handler) {
+	restServer.Any(path+"/*any", gin.WrapH(h))
+}
+
+// RunMetricsServer serves Prometheus metrics on own listener (metrics.host:metrics.port),
+// they are not exposed on client`s REST port
+func RunMetricsServer(log core.Logger) {
+	if !viper.GetBool("metrics.enabled") {
+		return
+	}
+	host := viper.GetString("metrics.host")
+	port := viper.GetString("metrics.port")
+	if port == "" {
+		port = "9090"
+	}
+
+	server := &http.Server{
+		Addr:    host + ":" + port,
+		Handler: metricsHandler(viper.GetString("metrics.path")),
+	}
+	log.Info("Metrics server listening on " + server.Addr)
+	if err := server.ListenAndServe(); err != nil {
+		log.ErrorWrap(err, "metrics server is stopped")
+	}
+}
+
+func metricsHandler(path string) http.Handler {
+	if path == "" {
+		path = "/metrics"
+	}
+	mux := http.NewServeMux()
+	mux.Handle(path, metrics.Handler())
+	return mux
+}
+
 func InitImageServer(log core.Logger) {
 	port := viper.GetString("img.port")
 	imgPath := viper.GetString("img.path")
@@ -5,12 +5,61 @@ import (
 	"fmt"
 	"github.com/gin-gonic/gin"
 	"github.com/go-playground/validator/v10"
+	"microservice/app/core"
+	"net/url"
+	"strings"
+	"time"
 )
 
+// redactedParams are replaced in gin`s request log (docs page takes admin token in query)
+var redactedParams = []string{"access_token"}
+
+// logFormatter is gin`s default request line without secrets of query
+func logFormatter(param gin.LogFormatterParams) string {
+	if param.Latency > time.Minute {
+		param.Latency = param.Latency.Truncate(time.Second)
+	}
+	return fmt.Sprintf("[GIN] %v | %3d | %13v | %15s | %-7s %#v\n%s",
+		param.TimeStamp.Format("2006/01/02 - 15:04:05"),
+		param.StatusCode,
+		param.Latency,
+		param.ClientIP,
+		param.Method,
+		redactQuery(param.Path),
+		param.ErrorMessage,
+	)
+}
+
+func redactQuery(path string) string {
+	i := strings.IndexByte(path, '?')
+	if i < 0 {
+		return path
+	}
+	query, err := url.ParseQuery(path[i+1:])
+	if err != nil {
+		return path[:i] // query is not logged at all
+	}
+	for _, name := range redactedParams {
+		if query.Has(name) {
+			query.Set(name, "[REDACTED]")
+		}
+	}
+	return path[:i+1] + query.Encode()
+}
+
 func GeneralMW(ctx *gin.Context) {
 	ctx.Header("content-type", "application/json")
 }
 
+// RequestIdMW accepts client`s X-Request-ID or generates new one,
+// it is stored in request context and echoed in response
+func RequestIdMW(ctx *gin.Context) {
+	id := core.AcceptRequestId(ctx.GetHeader(core.RequestIdHeader))
+	ctx.Request = ctx.Request.WithContext(core.WithRequestId(ctx.Request.Context(), id))
+	ctx.Header(core.RequestIdHeader, id)
+	ctx.Next()
+}
+
 func ErrorMW(ctx *gin.Context) {
 	ctx.Next()
 	if len(ctx.Errors) > 0 {
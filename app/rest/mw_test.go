@@ -0,0 +1,13 @@
+package rest
+
+import (
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestRedactQuery(t *testing.T) {
+	require.Equal(t, "/admin/v1/docs", redactQuery("/admin/v1/docs"))
+	require.Equal(t, "/admin/v1/docs?access_token=%5BREDACTED%5D&x=1",
+		redactQuery("/admin/v1/docs?x=1&access_token=secret"))
+	require.Equal(t, "/a", redactQuery("/a?access_token=secret;%zz"))
+}
@@ -4,7 +4,14 @@ import (
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
 	"github.com/gin-gonic/gin/binding"
+	"github.com/pkg/errors"
 	"github.com/spf13/viper"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+	"microservice/app/accesslog"
+	"microservice/app/core"
+	"microservice/app/tracing"
+	"net/http"
 	"time"
 )
 
@@ -14,24 +21,43 @@ var (
 
 func Init() error {
 	binding.Validator = new(defaultValidator)
-	restServer = gin.Default()
+	restServer = gin.New()
+	restServer.Use(gin.LoggerWithConfig(gin.LoggerConfig{Formatter: logFormatter}), gin.Recovery())
+
+	// Client IP is taken from X-Forwarded-For only behind these proxies
+	if err := restServer.SetTrustedProxies(viper.GetStringSlice("rest.trusted_proxies")); err != nil {
+		return errors.Wrap(err, "invalid rest.trusted_proxies")
+	}
+
+	// gin.Context is passed as context.Context to use cases:
+	// client`s disconnect should cancel upstream calls
+	restServer.ContextWithFallback = true
 
 	// CORS
 	restServer.Use(cors.New(cors.Config{
 		AllowOrigins:     []string{"*"},
-		AllowMethods:     []string{"POST"},
+		AllowMethods:     []string{"GET", "POST", "PATCH", "DELETE"},
 		AllowHeaders:     []string{"*"},
-		ExposeHeaders:    []string{},
+		ExposeHeaders:    []string{core.RequestIdHeader},
 		AllowCredentials: true,
 		AllowOriginFunc:  nil,
 		MaxAge:           12 * time.Hour,
 	}))
 
+	// Server span, id and access log of each request
+	restServer.Use(tracing.GinMW, RequestIdMW, accesslog.GinMW)
+
 	return nil
 }
 
 func RunServer() {
 	host := viper.GetString("rest.host")
 	port := viper.GetString("rest.port")
-	restServer.Run(host + ":" + port)
+
+	// h2c is required for native gRPC clients without TLS
+	server := &http.Server{
+		Addr:    host + ":" + port,
+		Handler: h2c.NewHandler(handler(), &http2.Server{}),
+	}
+	_ = server.ListenAndServe()
 }
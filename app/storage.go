@@ -7,6 +7,7 @@ import (
 	"os"
 	"path"
 	"strconv"
+	"time"
 )
 
 func InitStorage() error {
@@ -27,10 +28,10 @@ type Storage struct {
 	db   *bitcask.Bitcask
 }
 
-func NewStorage(name string) (*Storage, error) {
+func NewStorage(name string, opts ...bitcask.Option) (*Storage, error) {
 	cachePath := viper.GetString("storage.path")
 	file := path.Join(cachePath, name)
-	db, err := bitcask.Open(file)
+	db, err := bitcask.Open(file, opts...)
 	if err != nil {
 		return nil, errors.Wrap(err, "cannot create storage")
 	}
@@ -64,6 +65,42 @@ func (s *Storage) GetString(key string) (*string, error) {
 	return &r, nil
 }
 
+// PutBytesWithTTL stores value which is removed after ttl
+func (s *Storage) PutBytesWithTTL(key string, value []byte, ttl time.Duration) error {
+	err := s.db.PutWithTTL([]byte(key), value, ttl)
+	if err != nil {
+		return errors.Wrapf(err, "cannot put to storage %s", s.name)
+	}
+	return nil
+}
+
+func (s *Storage) GetBytes(key string) ([]byte, error) {
+	value, err := s.db.Get([]byte(key))
+	if errors.Is(err, bitcask.ErrKeyNotFound) || errors.Is(err, bitcask.ErrKeyExpired) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot get value (key=%s) from storage %s", key, s.name)
+	}
+	return value, nil
+}
+
+// DeleteIf removes keys with prefix for which f returns true
+func (s *Storage) DeleteIf(prefix string, f func(key string) bool) (int, error) {
+	count := 0
+	err := s.db.SiftScan([]byte(prefix), func(key []byte) (bool, error) {
+		if f(string(key)) {
+			count++
+			return true, nil
+		}
+		return false, nil
+	})
+	if err != nil {
+		return count, errors.Wrapf(err, "cannot delete from storage %s", s.name)
+	}
+	return count, nil
+}
+
 func (s *Storage) PutInt64(key string, value int64) error {
 	return s.PutString(key, strconv.FormatInt(value, 10))
 }
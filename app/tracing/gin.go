@@ -0,0 +1,45 @@
+package tracing
+
+import (
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.17.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// GinMW starts server span of request (parent is client`s traceparent)
+func GinMW(ctx *gin.Context) {
+	parent := otel.GetTextMapPropagator().Extract(ctx.Request.Context(), propagation.HeaderCarrier(ctx.Request.Header))
+
+	name := ctx.FullPath()
+	if name == "" {
+		name = ctx.Request.URL.Path
+	}
+	spanCtx, span := otel.Tracer(tracerName).Start(parent, "HTTP "+ctx.Request.Method+" "+name,
+		trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(
+			semconv.HTTPMethod(ctx.Request.Method),
+			semconv.HTTPTarget(ctx.Request.URL.Path),
+		),
+	)
+	defer span.End()
+
+	// gin.Context falls back to request context, so use cases get the span
+	ctx.Request = ctx.Request.WithContext(spanCtx)
+	ctx.Next()
+
+	status := ctx.Writer.Status()
+	span.SetAttributes(semconv.HTTPStatusCode(status))
+	if status >= 500 {
+		span.SetStatus(codes.Error, "")
+	}
+}
+
+// SetRoute names request span by route template
+func SetRoute(ctx *gin.Context, method, route string) {
+	span := trace.SpanFromContext(ctx.Request.Context())
+	span.SetName("HTTP " + method + " " + route)
+	span.SetAttributes(semconv.HTTPRoute(route))
+}
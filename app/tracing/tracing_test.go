@@ -0,0 +1,61 @@
+package tracing
+
+import (
+	"context"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"google.golang.org/grpc/metadata"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func initTestTracing(t *testing.T) *tracetest.SpanRecorder {
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	return recorder
+}
+
+func TestInject(t *testing.T) {
+	initTestTracing(t)
+
+	ctx := metadata.NewOutgoingContext(context.Background(), metadata.Pairs("x-user", "1"))
+	ctx, span := Start(ctx, "test")
+	defer span.End()
+
+	md, _ := metadata.FromOutgoingContext(Inject(ctx))
+	parent := md.Get("traceparent")
+	require.Len(t, parent, 1, "traceparent is not injected")
+	require.Contains(t, parent[0], span.SpanContext().TraceID().String())
+	require.Equal(t, []string{"1"}, md.Get("x-user"), "headers are lost")
+}
+
+func TestGinMW(t *testing.T) {
+	recorder := initTestTracing(t)
+	gin.SetMode(gin.TestMode)
+
+	const traceId = "4bf92f3577b34da6a3ce929d0e0e4736"
+	var injected string
+	engine := gin.New()
+	engine.Use(GinMW)
+	engine.GET("/test", func(ctx *gin.Context) {
+		md, _ := metadata.FromOutgoingContext(Inject(ctx.Request.Context()))
+		injected = md.Get("traceparent")[0]
+		ctx.Status(204)
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/test", nil)
+	req.Header.Set("traceparent", "00-"+traceId+"-00f067aa0ba902b7-01")
+	engine.ServeHTTP(httptest.NewRecorder(), req)
+
+	require.Contains(t, injected, traceId, "client`s trace is not continued")
+	spans := recorder.Ended()
+	require.Len(t, spans, 1)
+	require.Equal(t, "HTTP GET /test", spans[0].Name())
+	require.Equal(t, "00f067aa0ba902b7", spans[0].Parent().SpanID().String())
+}
@@ -1,13 +1,21 @@
 package bootstrap
 
 import (
+	"context"
 	"database/sql"
 	"github.com/pkg/errors"
+	"github.com/spf13/viper"
 	"microservice/app"
+	"microservice/app/accesslog"
+	"microservice/app/cache"
 	"microservice/app/core"
 	"microservice/app/job"
 	"microservice/app/kafka"
+	"microservice/app/ratelimit"
 	"microservice/app/rest"
+	"microservice/app/tracing"
+	"microservice/services"
+	"time"
 )
 
 func Run(rootPath ...string) error {
@@ -24,6 +32,12 @@ func Run(rootPath ...string) error {
 		return errors.Wrap(err, "error while init logs")
 	}
 
+	// Tracing
+	shutdownTracing, err := tracing.Init(ctx)
+	if err != nil {
+		return errors.Wrap(err, "error while init tracing")
+	}
+
 	// Storage
 	err = app.InitStorage()
 	if err != nil {
@@ -42,12 +56,17 @@ func Run(rootPath ...string) error {
 		return errors.Wrap(err, "error while making migrations")
 	}
 
-	// gRPC
+	// REST
 	err = rest.Init()
 	if err != nil {
 		return errors.Wrap(err, "cannot init gRPC")
 	}
 
+	// gRPC (admin API), its REST mux is served even without gRPC port
+	if _, _, err = app.InitGRPCServer(); err != nil {
+		return errors.Wrap(err, "cannot init gRPC server")
+	}
+
 	// DI
 	di := core.GetDI()
 
@@ -75,6 +94,16 @@ func Run(rootPath ...string) error {
 		return errors.Wrap(err, "cannot init kafka")
 	}
 
+	// ERRORS SHIPPING
+	if err = app.InitLogShipping(kafka.NewLogShipper); err != nil {
+		return errors.Wrap(err, "cannot init errors shipping")
+	}
+
+	// ACCESS LOG
+	if err = accesslog.Init(logger); err != nil {
+		return errors.Wrap(err, "cannot init access log")
+	}
+
 	// PROTO REGISTRY
 	registry := app.NewProtoRegistry()
 	err = registry.Init()
@@ -87,11 +116,47 @@ func Run(rootPath ...string) error {
 		return errors.Wrap(err, "cannot provide proto registry")
 	}
 
+	// RATE LIMIT
+	limiterStore, err := ratelimit.NewStore()
+	if err != nil {
+		return errors.Wrap(err, "cannot init rate limit store")
+	}
+	if err = di.Provide(func() ratelimit.Store {
+		return limiterStore
+	}); err != nil {
+		return errors.Wrap(err, "cannot provide rate limit store")
+	}
+
+	// RESPONSE CACHE
+	cacheStore, err := cache.NewStore()
+	if err != nil {
+		return errors.Wrap(err, "cannot init response cache store")
+	}
+	if err = di.Provide(func() cache.Store {
+		return cacheStore
+	}); err != nil {
+		return errors.Wrap(err, "cannot provide response cache store")
+	}
+
 	// CORE
 	if err := initDependencies(di); err != nil {
 		return errors.Wrap(err, "error while init dependencies")
 	}
 
+	// HEALTH CHECKS
+	if err := di.Invoke(func(statusService *services.StatusService) {
+		go statusService.Watch(ctx)
+	}); err != nil {
+		return errors.Wrap(err, "error while start health checks")
+	}
+
+	// ROUTE TEMPLATES
+	if err := di.Invoke(func(templates *services.RouteTemplates) {
+		go templates.Watch(ctx)
+	}); err != nil {
+		return errors.Wrap(err, "error while start route templates reload")
+	}
+
 	//
 	//
 	// HERE CORE READY FOR WORK...
@@ -108,9 +173,21 @@ func Run(rootPath ...string) error {
 	// Run gRPC and block
 	go rest.RunServer()
 	go rest.InitImageServer(logger)
+	go rest.RunMetricsServer(logger)
+	if viper.GetBool("app.grpc.enabled") {
+		go app.RunGRPCServer()
+	}
 
 	// End context
 	<-ctx.Done()
 
+	// Flush spans
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err = shutdownTracing(shutdownCtx); err != nil {
+		logger.WarnWrap(err, "cannot flush tracing spans")
+	}
+	app.CloseLogs()
+
 	return nil
 }
@@ -2,7 +2,9 @@ package bootstrap
 
 import (
 	"github.com/pkg/errors"
+	"github.com/spf13/viper"
 	"go.uber.org/dig"
+	"microservice/app"
 	"microservice/app/rest"
 	"microservice/delivery"
 	"microservice/domain"
@@ -26,9 +28,12 @@ func initDependencies(di *dig.Container) error {
 
 	// Services
 	_ = di.Provide(services.NewEndpointConnectionService)
+	_ = di.Provide(services.NewInstancesHealth)
 	_ = di.Provide(services.NewAuthService)
 	_ = di.Provide(services.NewStatusService)
 	_ = di.Provide(services.NewProtoCallerService)
+	_ = di.Provide(services.NewProtoProxyService)
+	_ = di.Provide(services.NewRouteTemplates)
 
 	// Use cases
 	_ = di.Provide(
@@ -36,20 +41,57 @@ func initDependencies(di *dig.Container) error {
 		dig.As(new(domain.InstancesUCase)),
 	)
 
+	_ = di.Provide(
+		interactors.NewRoutesInteractor,
+		dig.As(new(domain.RoutesUCase)),
+	)
+
+	_ = di.Provide(
+		interactors.NewDocsInteractor,
+		dig.As(new(domain.DocsUCase)),
+	)
+
 	_ = di.Provide(
 		interactors.NewRedirectUCase,
 		dig.As(new(domain.RedirectUCase)),
 	)
 
 	// Delivery init
-	err := rest.InitDelivery("/admin", delivery.NewAdminDelivery)
-	if err != nil {
-		return err
+	if err := initAdminDelivery(di); err != nil {
+		return errors.Wrap(err, "error while init admin API")
 	}
 
 	if err := rest.InitDeliveryDynamic("/api/v1", delivery.NewRouterDelivery); err != nil {
 		return errors.Wrap(err, "error while route gateway router")
 	}
 
+	if err := rest.InitDelivery("/ws/v1", delivery.NewWsDelivery); err != nil {
+		return errors.Wrap(err, "error while route websocket bridge")
+	}
+
+	if viper.GetBool("rest.grpc_proxy") {
+		if err := rest.InitGrpcProxy(delivery.NewGrpcProxyDelivery); err != nil {
+			return errors.Wrap(err, "error while init gRPC proxy")
+		}
+	}
+
+	return nil
+}
+
+// initAdminDelivery serves admin API (and routes docs) on gRPC and on REST through grpc-gateway mux
+func initAdminDelivery(di *dig.Container) error {
+	if err := di.Provide(delivery.NewAdminGrpcDelivery); err != nil {
+		return errors.Wrap(err, "cannot provide admin gRPC delivery")
+	}
+	if err := di.Provide(delivery.NewDocsDelivery); err != nil {
+		return errors.Wrap(err, "cannot provide docs delivery")
+	}
+	err := di.Invoke(func(admin *delivery.AdminGrpcDelivery, docs *delivery.DocsDelivery) error {
+		return app.InitDelivery(admin, docs)
+	})
+	if err != nil {
+		return err
+	}
+	rest.InitHandler("/admin/v1", app.GRPCMux())
 	return nil
 }
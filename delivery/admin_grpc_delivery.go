@@ -0,0 +1,427 @@
+package delivery
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/pkg/errors"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"microservice/app"
+	"microservice/app/core"
+	"microservice/domain"
+	pb "microservice/pkg/gateway/api"
+	"microservice/services"
+	"microservice/tools"
+)
+
+// AdminGrpcDelivery is gateway`s own management API, served on gRPC and REST (grpc-gateway)
+type AdminGrpcDelivery struct {
+	pb.UnimplementedAdminServiceServer
+	log            core.Logger
+	instancesUCase domain.InstancesUCase
+	routesUCase    domain.RoutesUCase
+	authService    *services.AuthService
+}
+
+func NewAdminGrpcDelivery(log core.Logger,
+	instancesUCase domain.InstancesUCase,
+	routesUCase domain.RoutesUCase,
+	authService *services.AuthService) *AdminGrpcDelivery {
+	return &AdminGrpcDelivery{
+		log:            log,
+		instancesUCase: instancesUCase,
+		routesUCase:    routesUCase,
+		authService:    authService,
+	}
+}
+
+func (d *AdminGrpcDelivery) Init() error {
+	app.InitGRPCService(pb.RegisterAdminServiceServer, pb.AdminServiceServer(d))
+	return app.InitGRPCGateway(pb.RegisterAdminServiceHandlerServer, pb.AdminServiceServer(d))
+}
+
+// authorize allows only super admins
+func (d *AdminGrpcDelivery) authorize(ctx context.Context) error {
+	md, _ := metadata.FromIncomingContext(ctx)
+	tokens := md.Get("authorization")
+	if len(tokens) == 0 {
+		return status.Error(codes.Unauthenticated, "authorization token is required")
+	}
+	d.log.WithContext(ctx).With(core.Fields{"token": tokens[0]}).Debug("Authorization access with token")
+
+	user, err := d.authService.Verify(ctx, tokens[0], core.RoleSuperAdmin)
+	if err != nil {
+		return errors.Wrap(err, "error while verifying admin request")
+	}
+	if user == nil {
+		return status.Error(codes.PermissionDenied, "access denied")
+	}
+	return nil
+}
+
+func (d *AdminGrpcDelivery) ListInstances(ctx context.Context, r *pb.ListInstancesRequest) (*pb.ListInstancesResponse, error) {
+	if err := d.authorize(ctx); err != nil {
+		return nil, err
+	}
+
+	items, err := d.instancesUCase.All(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "error while services_all ucase")
+	}
+
+	var instances []*pb.Instance
+	for _, item := range items {
+		instances = append(instances, &pb.Instance{
+			Id:          item.Id,
+			Name:        item.Name,
+			Folder:      item.Folder,
+			Endpoint:    item.Endpoint,
+			IsActive:    item.IsActive,
+			Status:      item.Status,
+			HealthCheck: toPbHealthCheck(item.HealthCheck),
+		})
+	}
+	return &pb.ListInstancesResponse{
+		Status: &pb.Status{
+			Code: core.Success,
+		},
+		Instances: instances,
+	}, nil
+}
+
+func (d *AdminGrpcDelivery) UpdateInstance(ctx context.Context, r *pb.UpdateInstanceRequest) (*pb.StatusResponse, error) {
+	if err := d.authorize(ctx); err != nil {
+		return nil, err
+	}
+
+	// Only passed fields are updated
+	fields := map[string]interface{}{}
+	if r.Folder != nil {
+		fields["folder"] = r.GetFolder()
+	}
+	if r.Endpoint != nil {
+		fields["endpoint"] = r.GetEndpoint()
+	}
+	if r.IsActive != nil {
+		fields["is_active"] = r.GetIsActive()
+	}
+	if r.RemoveHealthCheck {
+		fields["health_check"] = nil
+	} else if r.HealthCheck != nil {
+		healthCheck := fromPbHealthCheck(r.HealthCheck)
+		if err := services.ValidateHealthCheck(healthCheck); err != nil {
+			return &pb.StatusResponse{
+				Status: &pb.Status{
+					Code:    core.ValidationError,
+					Message: err.Error(),
+				},
+			}, nil
+		}
+		raw, err := json.Marshal(healthCheck)
+		if err != nil {
+			return nil, errors.Wrap(err, "error while marshal health check")
+		}
+		fields["health_check"] = string(raw)
+	}
+	if len(fields) == 0 {
+		return &pb.StatusResponse{
+			Status: &pb.Status{
+				Code:    core.ValidationError,
+				Message: "nothing to update",
+			},
+		}, nil
+	}
+
+	res, err := d.instancesUCase.Update(ctx, tools.NewUpdateReq(r.Id, fields))
+	if err != nil {
+		return nil, errors.Wrap(err, "error while instance update ucase")
+	}
+	return &pb.StatusResponse{
+		Status: toPbStatus(res.Status),
+	}, nil
+}
+
+func (d *AdminGrpcDelivery) ListRoutes(ctx context.Context, r *pb.ListRoutesRequest) (*pb.ListRoutesResponse, error) {
+	if err := d.authorize(ctx); err != nil {
+		return nil, err
+	}
+
+	res, err := d.routesUCase.All(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "error while routes_all ucase")
+	}
+
+	var routes []*pb.Route
+	for _, item := range res.Routes {
+		routes = append(routes, toPbRoute(item))
+	}
+	return &pb.ListRoutesResponse{
+		Status: toPbStatus(res.Status),
+		Routes: routes,
+	}, nil
+}
+
+func (d *AdminGrpcDelivery) CreateRoute(ctx context.Context, r *pb.CreateRouteRequest) (*pb.IdResponse, error) {
+	if err := d.authorize(ctx); err != nil {
+		return nil, err
+	}
+	if r.Route == nil {
+		return &pb.IdResponse{
+			Status: &pb.Status{
+				Code:    core.ValidationError,
+				Message: "route is required",
+			},
+		}, nil
+	}
+
+	res, err := d.routesUCase.Create(ctx, fromPbRoute(r.Route))
+	if err != nil {
+		return nil, errors.Wrap(err, "error while route create ucase")
+	}
+	return &pb.IdResponse{
+		Status: toPbStatus(res.Status),
+		Id:     res.Id,
+	}, nil
+}
+
+func (d *AdminGrpcDelivery) DeleteRoute(ctx context.Context, r *pb.IdRequest) (*pb.StatusResponse, error) {
+	if err := d.authorize(ctx); err != nil {
+		return nil, err
+	}
+
+	res, err := d.routesUCase.Delete(ctx, r.Id)
+	if err != nil {
+		return nil, errors.Wrap(err, "error while route delete ucase")
+	}
+	return &pb.StatusResponse{
+		Status: toPbStatus(res.Status),
+	}, nil
+}
+
+func (d *AdminGrpcDelivery) PurgeCache(ctx context.Context, r *pb.PurgeCacheRequest) (*pb.PurgeCacheResponse, error) {
+	if err := d.authorize(ctx); err != nil {
+		return nil, err
+	}
+
+	res, err := d.routesUCase.PurgeCache(ctx, &domain.RoutesPurgeCacheRequest{
+		RouteId: r.RouteId,
+		Prefix:  r.Prefix,
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "error while cache purge ucase")
+	}
+	return &pb.PurgeCacheResponse{
+		Status: toPbStatus(res.Status),
+		Purged: int64(res.Purged),
+	}, nil
+}
+
+func (d *AdminGrpcDelivery) ReloadRegistry(ctx context.Context, r *pb.ReloadRegistryRequest) (*pb.StatusResponse, error) {
+	if err := d.authorize(ctx); err != nil {
+		return nil, err
+	}
+
+	res, err := d.instancesUCase.Reload(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "error while registry reload ucase")
+	}
+	return &pb.StatusResponse{
+		Status: toPbStatus(res.Status),
+	}, nil
+}
+
+func (d *AdminGrpcDelivery) GetStatus(ctx context.Context, r *pb.GetStatusRequest) (*pb.GetStatusResponse, error) {
+	if err := d.authorize(ctx); err != nil {
+		return nil, err
+	}
+
+	res, err := d.instancesUCase.Status(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "error while status ucase")
+	}
+
+	var instances []*pb.InstanceStatus
+	for _, item := range res.Instances {
+		instances = append(instances, &pb.InstanceStatus{
+			Name:        item.Name,
+			Status:      item.Status,
+			Error:       item.Error,
+			Services:    item.Services,
+			HealthCheck: item.HealthCheck,
+		})
+	}
+	return &pb.GetStatusResponse{
+		Status:    toPbStatus(res.Status),
+		Instances: instances,
+	}, nil
+}
+
+func toPbStatus(s core.Status) *pb.Status {
+	return &pb.Status{
+		Code:    s.Code,
+		Message: s.Message,
+	}
+}
+
+func toPbRoute(item *domain.Route) *pb.Route {
+	return &pb.Route{
+		Id:              item.Id,
+		HttpMethod:      item.HttpMethod,
+		HttpAddress:     item.HttpAddress,
+		Instance:        item.Instance,
+		ProtoService:    item.ProtoService,
+		ProtoMethod:     item.ProtoMethod,
+		AccessRole:      int32(item.AccessRole),
+		IsActive:        item.IsActive,
+		RateLimit:       item.RateLimit,
+		RateBurst:       item.RateBurst,
+		RateLimitBy:     item.RateLimitBy,
+		MaxRequestSize:  item.MaxRequestSize,
+		MaxResponseSize: item.MaxResponseSize,
+		CacheTtl:        item.CacheTtl,
+		CacheStale:      item.CacheStale,
+		CachePerUser:    item.CachePerUser,
+		Coalesce:        item.Coalesce,
+		Calls:           toPbRouteCalls(item.Calls),
+		Transform:       toPbRouteTransform(item.Transform),
+		Json: &pb.JsonOptions{
+			UseProtoNames:   item.Json.UseProtoNames,
+			EmitUnpopulated: item.Json.EmitUnpopulated,
+			UseEnumNumbers:  item.Json.UseEnumNumbers,
+			DiscardUnknown:  item.Json.DiscardUnknown,
+		},
+	}
+}
+
+func fromPbRoute(item *pb.Route) *domain.Route {
+	route := &domain.Route{
+		HttpMethod:      item.HttpMethod,
+		HttpAddress:     item.HttpAddress,
+		Instance:        item.Instance,
+		ProtoService:    item.ProtoService,
+		ProtoMethod:     item.ProtoMethod,
+		AccessRole:      core.AccessRole(item.AccessRole),
+		IsActive:        item.IsActive,
+		RateLimit:       item.RateLimit,
+		RateBurst:       item.RateBurst,
+		RateLimitBy:     item.RateLimitBy,
+		MaxRequestSize:  item.MaxRequestSize,
+		MaxResponseSize: item.MaxResponseSize,
+		CacheTtl:        item.CacheTtl,
+		CacheStale:      item.CacheStale,
+		CachePerUser:    item.CachePerUser,
+		Coalesce:        item.Coalesce,
+		Calls:           fromPbRouteCalls(item.Calls),
+		Transform:       fromPbRouteTransform(item.Transform),
+	}
+	if item.Json != nil {
+		route.Json = domain.JsonOptions{
+			UseProtoNames:   item.Json.UseProtoNames,
+			EmitUnpopulated: item.Json.EmitUnpopulated,
+			UseEnumNumbers:  item.Json.UseEnumNumbers,
+			DiscardUnknown:  item.Json.DiscardUnknown,
+		}
+	}
+	return route
+}
+
+func toPbRouteCalls(items []*domain.RouteCall) []*pb.RouteCall {
+	var calls []*pb.RouteCall
+	for _, item := range items {
+		calls = append(calls, &pb.RouteCall{
+			Name:      item.Name,
+			Instance:  item.Instance,
+			Service:   item.Service,
+			Method:    item.Method,
+			DependsOn: item.DependsOn,
+			Params:    item.Params,
+			Optional:  item.Optional,
+		})
+	}
+	return calls
+}
+
+func fromPbRouteCalls(items []*pb.RouteCall) []*domain.RouteCall {
+	var calls []*domain.RouteCall
+	for _, item := range items {
+		calls = append(calls, &domain.RouteCall{
+			Name:      item.Name,
+			Instance:  item.Instance,
+			Service:   item.Service,
+			Method:    item.Method,
+			DependsOn: item.DependsOn,
+			Params:    item.Params,
+			Optional:  item.Optional,
+		})
+	}
+	return calls
+}
+
+func toPbRouteTransform(item *domain.RouteTransform) *pb.RouteTransform {
+	if item == nil {
+		return nil
+	}
+	transform := &pb.RouteTransform{
+		Rename:   item.Rename,
+		Drop:     item.Drop,
+		Fields:   item.Fields,
+		Envelope: item.Envelope,
+	}
+	if len(item.Defaults) > 0 {
+		transform.Defaults = make(map[string]string, len(item.Defaults))
+		for k, v := range item.Defaults {
+			transform.Defaults[k] = string(v)
+		}
+	}
+	return transform
+}
+
+func fromPbRouteTransform(item *pb.RouteTransform) *domain.RouteTransform {
+	if item == nil {
+		return nil
+	}
+	transform := &domain.RouteTransform{
+		Rename:   item.Rename,
+		Drop:     item.Drop,
+		Fields:   item.Fields,
+		Envelope: item.Envelope,
+	}
+	if len(item.Defaults) > 0 {
+		transform.Defaults = make(map[string]json.RawMessage, len(item.Defaults))
+		for k, v := range item.Defaults {
+			transform.Defaults[k] = json.RawMessage(v)
+		}
+	}
+	return transform
+}
+
+func toPbHealthCheck(item *domain.InstanceHealthCheck) *pb.InstanceHealthCheck {
+	if item == nil {
+		return nil
+	}
+	return &pb.InstanceHealthCheck{
+		Protocol:           item.Protocol,
+		IntervalMs:         item.IntervalMs,
+		TimeoutMs:          item.TimeoutMs,
+		HealthyThreshold:   item.HealthyThreshold,
+		UnhealthyThreshold: item.UnhealthyThreshold,
+		Services:           item.Services,
+		Watch:              item.Watch,
+	}
+}
+
+func fromPbHealthCheck(item *pb.InstanceHealthCheck) *domain.InstanceHealthCheck {
+	if item == nil {
+		return nil
+	}
+	return &domain.InstanceHealthCheck{
+		Protocol:           item.Protocol,
+		IntervalMs:         item.IntervalMs,
+		TimeoutMs:          item.TimeoutMs,
+		HealthyThreshold:   item.HealthyThreshold,
+		UnhealthyThreshold: item.UnhealthyThreshold,
+		Services:           item.Services,
+		Watch:              item.Watch,
+	}
+}
@@ -0,0 +1,107 @@
+package delivery
+
+import (
+	"encoding/json"
+	"microservice/app"
+	"microservice/app/core"
+	"microservice/domain"
+	"microservice/services"
+	"net/http"
+)
+
+// swaggerPage loads Swagger UI from CDN and shows gateway`s document,
+// access_token of page is passed to document request
+const swaggerPage = `<!DOCTYPE html>
+<html lang="en">
+<head>
+  <meta charset="utf-8"/>
+  <title>API Gateway</title>
+  <link rel="stylesheet" href="https://unpkg.com/swagger-ui-dist@4.18.2/swagger-ui.css"/>
+</head>
+<body>
+<div id="swagger-ui"></div>
+<script src="https://unpkg.com/swagger-ui-dist@4.18.2/swagger-ui-bundle.js"></script>
+<script>
+  window.ui = SwaggerUIBundle({
+    url: "openapi.json" + window.location.search,
+    dom_id: "#swagger-ui",
+  });
+</script>
+</body>
+</html>
+`
+
+// DocsDelivery serves OpenAPI document of routed endpoints on admin mux (super admins only)
+type DocsDelivery struct {
+	log         core.Logger
+	docsUCase   domain.DocsUCase
+	authService *services.AuthService
+}
+
+func NewDocsDelivery(log core.Logger,
+	docsUCase domain.DocsUCase,
+	authService *services.AuthService) *DocsDelivery {
+	return &DocsDelivery{
+		log:         log,
+		docsUCase:   docsUCase,
+		authService: authService,
+	}
+}
+
+func (d *DocsDelivery) Init() error {
+	if err := app.InitGRPCGatewayPath("GET", "/admin/v1/openapi.json", d.OpenApi); err != nil {
+		return err
+	}
+	return app.InitGRPCGatewayPath("GET", "/admin/v1/docs", d.SwaggerUI)
+}
+
+func (d *DocsDelivery) OpenApi(w http.ResponseWriter, r *http.Request, _ map[string]string) {
+	if !d.authorize(w, r) {
+		return
+	}
+
+	doc, err := d.docsUCase.OpenApi(r.Context())
+	if err != nil {
+		d.log.ErrorWrap(err, "error while openapi ucase")
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(doc); err != nil {
+		d.log.ErrorWrap(err, "cannot write openapi document")
+	}
+}
+
+func (d *DocsDelivery) SwaggerUI(w http.ResponseWriter, r *http.Request, _ map[string]string) {
+	if !d.authorize(w, r) {
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	_, _ = w.Write([]byte(swaggerPage))
+}
+
+// authorize checks super admin`s token like admin API, browsers pass it in access_token query parameter
+func (d *DocsDelivery) authorize(w http.ResponseWriter, r *http.Request) bool {
+	token := r.Header.Get("Authorization")
+	if token == "" {
+		token = r.URL.Query().Get("access_token")
+	}
+	if token == "" {
+		http.Error(w, "authorization token is required", http.StatusUnauthorized)
+		return false
+	}
+
+	user, err := d.authService.Verify(r.Context(), token, core.RoleSuperAdmin)
+	if err != nil {
+		d.log.WithContext(r.Context()).ErrorWrap(err, "error while verifying docs request")
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return false
+	}
+	if user == nil {
+		http.Error(w, "access denied", http.StatusForbidden)
+		return false
+	}
+	return true
+}
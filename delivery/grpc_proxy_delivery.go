@@ -0,0 +1,141 @@
+package delivery
+
+import (
+	"context"
+	"github.com/pkg/errors"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"microservice/app/accesslog"
+	"microservice/app/core"
+	"microservice/app/ratelimit"
+	"microservice/domain"
+	"microservice/services"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// GrpcProxyDelivery forwards native gRPC and gRPC-Web calls to instances.
+// Route is found by /package.Service/Method name, frames are not decoded
+type GrpcProxyDelivery struct {
+	log          core.Logger
+	routerUCase  domain.RedirectUCase
+	limiter      ratelimit.Store
+	proxyService *services.ProtoProxyService
+}
+
+func NewGrpcProxyDelivery(log core.Logger,
+	routerUCase domain.RedirectUCase,
+	limiter ratelimit.Store,
+	proxyService *services.ProtoProxyService,
+) *GrpcProxyDelivery {
+	return &GrpcProxyDelivery{
+		log:          log,
+		routerUCase:  routerUCase,
+		limiter:      limiter,
+		proxyService: proxyService,
+	}
+}
+
+func (d *GrpcProxyDelivery) Handle(srv interface{}, stream grpc.ServerStream) (err error) {
+	ctx := stream.Context()
+	start := time.Now()
+	md, _ := metadata.FromIncomingContext(ctx)
+
+	// Request id goes to logs, access log and instance`s metadata (native gRPC calls don`t pass gin)
+	ctx = withGrpcRequestId(ctx, md)
+	_ = stream.SetHeader(metadata.Pairs(strings.ToLower(core.RequestIdHeader), core.RequestId(ctx)))
+	accesslog.SetRequestId(ctx, core.RequestId(ctx))
+	defer func() {
+		accesslog.SetGrpcCode(ctx, status.Code(errors.Cause(err)).String())
+	}()
+
+	fullMethod, ok := grpc.MethodFromServerStream(stream)
+	if !ok {
+		return status.Error(codes.Internal, "cannot get method from stream")
+	}
+
+	// Auth
+	var authToken *string
+	if tokens := md.Get("authorization"); len(tokens) > 0 {
+		authToken = &tokens[0]
+	}
+	resolved, err := d.routerUCase.ResolveMethod(ctx, &domain.RedirectResolveMethodRequest{
+		AuthToken:  authToken,
+		FullMethod: fullMethod,
+	})
+	if err != nil {
+		d.log.WithContext(ctx).ErrorWrap(err, "cannot resolve gRPC call %s", fullMethod)
+		return status.Error(codes.Internal, "cannot resolve method")
+	}
+	if resolved.Status.Code != core.Success {
+		return status.Error(statusToCode(resolved.Status.Code), resolved.Status.Message)
+	}
+
+	// Rate limit
+	var apiKey string
+	if keys := md.Get("x-api-key"); len(keys) > 0 {
+		apiKey = keys[0]
+	}
+	if res := takeRouteToken(ctx, d.log, d.limiter, resolved, peerIp(stream), apiKey); res != nil {
+		_ = stream.SetHeader(metadata.Pairs(
+			"ratelimit-limit", strconv.Itoa(res.Limit),
+			"ratelimit-remaining", strconv.Itoa(res.Remaining),
+			"ratelimit-reset", ceilSeconds(res.Reset),
+		))
+		if !res.Allowed {
+			return status.Error(codes.ResourceExhausted, "too many requests")
+		}
+	}
+
+	headers := map[string]string{}
+	if resolved.UserId != nil {
+		headers["user_id"] = strconv.FormatInt(int64(*resolved.UserId), 10)
+	}
+
+	err = d.proxyService.Proxy(ctx, resolved.Route.Instance, fullMethod, stream, headers)
+	d.log.WithContext(ctx).Info("gRPC %s -> %s (%s, %v)", fullMethod, resolved.Route.Instance, status.Code(errors.Cause(err)), time.Since(start))
+	return err
+}
+
+// withGrpcRequestId stores client`s x-request-id or new one in ctx
+func withGrpcRequestId(ctx context.Context, md metadata.MD) context.Context {
+	var id string
+	if ids := md.Get(core.RequestIdHeader); len(ids) > 0 {
+		id = ids[0]
+	}
+	return core.WithRequestId(ctx, core.AcceptRequestId(id))
+}
+
+// statusToCode converts use case status to gRPC code
+func statusToCode(code string) codes.Code {
+	switch code {
+	case core.NotFound:
+		return codes.Unimplemented
+	case core.Unauthorised:
+		return codes.Unauthenticated
+	case core.ValidationError:
+		return codes.InvalidArgument
+	case core.TooManyRequests:
+		return codes.ResourceExhausted
+	case core.Unavailable:
+		return codes.Unavailable
+	}
+	return codes.Internal
+}
+
+func peerIp(stream grpc.ServerStream) string {
+	p, ok := peer.FromContext(stream.Context())
+	if !ok {
+		return ""
+	}
+	host, _, err := net.SplitHostPort(p.Addr.String())
+	if err != nil {
+		return p.Addr.String()
+	}
+	return host
+}
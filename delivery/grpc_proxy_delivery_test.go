@@ -0,0 +1,26 @@
+package delivery
+
+import (
+	"context"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/metadata"
+	"microservice/app/core"
+	"strings"
+	"testing"
+)
+
+func TestGrpcRequestId(t *testing.T) {
+	ctx := withGrpcRequestId(context.Background(), metadata.Pairs("x-request-id", "client-id"))
+	require.Equal(t, "client-id", core.RequestId(ctx))
+
+	ctx = withGrpcRequestId(context.Background(), metadata.Pairs("x-request-id", "bad id\n"))
+	require.NotEqual(t, "bad id\n", core.RequestId(ctx))
+	require.Len(t, core.RequestId(ctx), 36)
+
+	ctx = withGrpcRequestId(context.Background(), nil)
+	require.Len(t, core.RequestId(ctx), 36)
+
+	long := strings.Repeat("a", 129)
+	ctx = withGrpcRequestId(context.Background(), metadata.Pairs("x-request-id", long))
+	require.NotEqual(t, long, core.RequestId(ctx))
+}
@@ -0,0 +1,69 @@
+package delivery
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"github.com/spf13/viper"
+	"microservice/app/core"
+	"microservice/app/ratelimit"
+	"microservice/domain"
+	"strconv"
+)
+
+// takeRouteToken takes token from route`s bucket,
+// nil result means that route has no limit or limiter is unavailable
+func takeRouteToken(ctx context.Context, log core.Logger, limiter ratelimit.Store,
+	resolved *domain.RedirectResolveResponse, ip, apiKey string) *ratelimit.Result {
+
+	route := resolved.Route
+	if route.RateLimit <= 0 {
+		return nil
+	}
+
+	limit := ratelimit.PerMinute(route.RateLimit, route.RateBurst)
+	key := fmt.Sprintf("route:%d:%s", route.Id, rateLimitKey(resolved, ip, apiKey))
+	res, err := limiter.Take(ctx, key, limit)
+	if err != nil {
+		// Limiter is unavailable - let request go
+		log.ErrorWrap(err, "cannot take rate limit token (route=%d)", route.Id)
+		return nil
+	}
+	return res
+}
+
+// rateLimitKey identifies client by route`s policy
+func rateLimitKey(resolved *domain.RedirectResolveResponse, ip, apiKey string) string {
+	switch resolved.Route.RateLimitBy {
+	case domain.RateLimitByRoute:
+		return "all"
+	case domain.RateLimitByUser:
+		if resolved.UserId != nil {
+			return "user:" + strconv.FormatInt(int64(*resolved.UserId), 10)
+		}
+		fallthrough
+	case domain.RateLimitByApiKey:
+		if verifiedApiKey(apiKey) {
+			// Keys are not kept in store as is
+			sum := sha256.Sum256([]byte(apiKey))
+			return "api_key:" + hex.EncodeToString(sum[:16])
+		}
+	}
+	return "ip:" + ip
+}
+
+// verifiedApiKey checks key by ratelimit.api_keys, client with unknown key is limited by IP
+// (otherwise random key gives new bucket on each request)
+func verifiedApiKey(apiKey string) bool {
+	if apiKey == "" {
+		return false
+	}
+	for _, key := range viper.GetStringSlice("ratelimit.api_keys") {
+		if subtle.ConstantTimeCompare([]byte(key), []byte(apiKey)) == 1 {
+			return true
+		}
+	}
+	return false
+}
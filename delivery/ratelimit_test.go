@@ -0,0 +1,32 @@
+package delivery
+
+import (
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/require"
+	"microservice/domain"
+	"testing"
+)
+
+func TestRateLimitKey(t *testing.T) {
+	viper.Set("ratelimit.api_keys", []string{"known"})
+	defer viper.Set("ratelimit.api_keys", nil)
+
+	userId := int32(7)
+	resolve := func(by string, userId *int32) *domain.RedirectResolveResponse {
+		return &domain.RedirectResolveResponse{Route: &domain.Route{RateLimitBy: by}, UserId: userId}
+	}
+
+	require.Equal(t, "all", rateLimitKey(resolve(domain.RateLimitByRoute, nil), "1.1.1.1", "known"))
+	require.Equal(t, "ip:1.1.1.1", rateLimitKey(resolve(domain.RateLimitByIp, nil), "1.1.1.1", "known"))
+	require.Equal(t, "user:7", rateLimitKey(resolve(domain.RateLimitByUser, &userId), "1.1.1.1", "known"))
+
+	// Known key gets own bucket, it is not kept as is
+	key := rateLimitKey(resolve(domain.RateLimitByApiKey, nil), "1.1.1.1", "known")
+	require.Regexp(t, "^api_key:[0-9a-f]{32}$", key)
+	require.Equal(t, key, rateLimitKey(resolve(domain.RateLimitByUser, nil), "1.1.1.1", "known"))
+
+	// Random keys don`t give new buckets
+	require.Equal(t, "ip:1.1.1.1", rateLimitKey(resolve(domain.RateLimitByApiKey, nil), "1.1.1.1", "random"))
+	require.Equal(t, "ip:1.1.1.1", rateLimitKey(resolve(domain.RateLimitByUser, nil), "1.1.1.1", "random"))
+	require.Equal(t, "ip:1.1.1.1", rateLimitKey(resolve(domain.RateLimitByApiKey, nil), "1.1.1.1", ""))
+}
@@ -1,69 +1,391 @@
 package delivery
 
 import (
+	"encoding/json"
+	"fmt"
 	"github.com/gin-gonic/gin"
 	"github.com/pkg/errors"
+	"github.com/spf13/viper"
 	"io"
+	"math"
+	"microservice/app"
 	"microservice/app/core"
+	"microservice/app/metrics"
+	"microservice/app/ratelimit"
+	"microservice/app/rest"
+	"microservice/app/tracing"
 	"microservice/domain"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
 )
 
+const resolvedRouteKey = "resolved_route"
+
+var supportedContentTypes = map[string]bool{
+	app.ContentTypeJson:     true,
+	app.ContentTypeProtobuf: true,
+	"application/protobuf":  true,
+}
+
 type RouterDelivery struct {
 	log         core.Logger
 	routerUCase domain.RedirectUCase
+	limiter     ratelimit.Store
 }
 
 func NewRouterDelivery(log core.Logger,
 	routerUCase domain.RedirectUCase,
+	limiter ratelimit.Store,
 ) *RouterDelivery {
 	return &RouterDelivery{
 		log:         log,
 		routerUCase: routerUCase,
+		limiter:     limiter,
 	}
 }
 
-func (d *RouterDelivery) Route(ctx *gin.Context) {
+func (d *RouterDelivery) Middlewares() []gin.HandlerFunc {
+	return []gin.HandlerFunc{
+		d.MetricsMW,
+		d.ResolveMW,
+		d.RateLimitMW,
+		d.BodyLimitsMW,
+	}
+}
+
+// MetricsMW counts requests and their latency by route template, also names request`s span
+func (d *RouterDelivery) MetricsMW(ctx *gin.Context) {
+	start := time.Now()
+	ctx.Next()
+
+	route := "unmatched"
+	if resolved, ok := ctx.Get(resolvedRouteKey); ok {
+		route = resolved.(*domain.RedirectResolveResponse).Route.HttpAddress
+		tracing.SetRoute(ctx, ctx.Request.Method, route)
+	}
+	status := strconv.Itoa(ctx.Writer.Status())
+	metrics.HttpRequests.WithLabelValues(route, ctx.Request.Method, status).Inc()
+	metrics.HttpDuration.WithLabelValues(route, ctx.Request.Method, status).Observe(metrics.Since(start))
+}
+
+// ResolveMW finds route and authorizes client
+func (d *RouterDelivery) ResolveMW(ctx *gin.Context) {
 
 	// Headers
 	ctx.Header("content-type", "application/json")
 
-	// Extract token
-	authTokens := ctx.Request.Header["Authorization"]
-	var authToken *string
-	if authTokens != nil && len(authTokens) > 0 {
-		authToken = &authTokens[0]
-		d.log.Debug("Authorization access with token: %v", *authToken)
-	} else {
-		d.log.Debug("Call without token:")
+	res, err := d.routerUCase.Resolve(ctx, &domain.RedirectResolveRequest{
+		AuthToken: d.authToken(ctx),
+		Address:   ctx.Request.URL.Path,
+		Method:    ctx.Request.Method,
+	})
+	if err != nil {
+		_ = ctx.Error(errors.Wrapf(err, "cannot resolve client`s request"))
+		ctx.AbortWithStatus(500)
+		return
+	}
+	if res.Status.Code != core.Success {
+		ctx.AbortWithStatusJSON(500, core.StatusResponse{Status: res.Status})
+		return
+	}
+
+	ctx.Set(resolvedRouteKey, res)
+	ctx.Next()
+}
+
+// RateLimitMW takes token from route`s bucket
+func (d *RouterDelivery) RateLimitMW(ctx *gin.Context) {
+	resolved := ctx.MustGet(resolvedRouteKey).(*domain.RedirectResolveResponse)
+	res := takeRouteToken(ctx, d.log, d.limiter, resolved, ctx.ClientIP(), ctx.GetHeader("X-API-Key"))
+	if res == nil {
+		ctx.Next()
+		return
+	}
+
+	ctx.Header("RateLimit-Limit", strconv.Itoa(res.Limit))
+	ctx.Header("RateLimit-Remaining", strconv.Itoa(res.Remaining))
+	ctx.Header("RateLimit-Reset", ceilSeconds(res.Reset))
+	if !res.Allowed {
+		ctx.Header("Retry-After", ceilSeconds(res.RetryAfter))
+		ctx.AbortWithStatusJSON(429, rest.TooManyRequestsError())
+		return
+	}
+	ctx.Next()
+}
+
+// BodyLimitsMW checks request`s content type and limits body size
+func (d *RouterDelivery) BodyLimitsMW(ctx *gin.Context) {
+	resolved := ctx.MustGet(resolvedRouteKey).(*domain.RedirectResolveResponse)
+	route := resolved.Route
+
+	// Content type is required for any body
+	contentType := ctx.GetHeader("Content-Type")
+	if contentType != "" || ctx.Request.ContentLength != 0 {
+		mediaType, _, err := mime.ParseMediaType(contentType)
+		if err != nil || !supportedContentTypes[mediaType] {
+			ctx.AbortWithStatusJSON(415, rest.UnsupportedMediaTypeError(contentType))
+			return
+		}
+	}
+
+	maxSize := route.MaxRequestSize
+	if maxSize <= 0 {
+		maxSize = viper.GetInt64("rest.max_request_size")
+	}
+	if maxSize > 0 {
+		if ctx.Request.ContentLength > maxSize {
+			ctx.AbortWithStatusJSON(413, rest.PayloadTooLargeError())
+			return
+		}
+		ctx.Request.Body = http.MaxBytesReader(ctx.Writer, ctx.Request.Body, maxSize)
 	}
+	ctx.Next()
+}
+
+func (d *RouterDelivery) Route(ctx *gin.Context) {
 
 	// Parse body
 	body, err := io.ReadAll(ctx.Request.Body)
 	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			ctx.AbortWithStatusJSON(413, rest.PayloadTooLargeError())
+			return
+		}
 		_ = ctx.Error(errors.Wrapf(err, "cannot parse request`s body"))
 		ctx.AbortWithStatus(500)
 		return
 	}
 
+	// Media types
+	contentType, _, _ := mime.ParseMediaType(ctx.GetHeader("Content-Type"))
+	accept := negotiateAccept(ctx.GetHeader("Accept"))
+
+	// Field mask is not a request parameter, header takes precedence over fields=
+	query := ctx.Request.URL.Query()
+	fieldMask := parseFieldMask(ctx.GetHeader("X-Field-Mask"))
+	if fields, ok := query["fields"]; ok {
+		if fieldMask == nil {
+			fieldMask = parseFieldMask(strings.Join(fields, ","))
+		}
+		query.Del("fields")
+	}
+
+	req := &domain.RedirectRouteRequest{
+		Address:     ctx.Request.URL.Path,
+		Data:        body,
+		Query:       query,
+		ContentType: contentType,
+		Accept:      accept,
+		FieldMask:   fieldMask,
+		Resolved:    ctx.MustGet(resolvedRouteKey).(*domain.RedirectResolveResponse),
+	}
+	if req.Resolved.Streaming {
+		d.stream(ctx, req)
+		return
+	}
+
 	// UCase
-	res, err := d.routerUCase.Route(ctx, &domain.RedirectRouteRequest{
-		AuthToken: authToken,
-		Address:   ctx.Request.URL.Path,
-		Data:      body,
-	})
+	res, err := d.routerUCase.Route(ctx, req)
 	if err != nil {
 		_ = ctx.Error(errors.Wrapf(err, "cannot route client`s request"))
 		ctx.AbortWithStatus(500)
 		return
 	}
 
+	// Upstream answered with too large response
+	if res != nil && res.Status.Code == core.PayloadTooLarge {
+		ctx.AbortWithStatusJSON(502, core.StatusResponse{Status: res.Status})
+		return
+	}
+
+	// Instance failed health checks
+	if res != nil && res.Status.Code == core.Unavailable {
+		ctx.AbortWithStatusJSON(503, core.StatusResponse{Status: res.Status})
+		return
+	}
+
+	// Client`s request is incorrect
+	if res != nil && res.Status.Code == core.ValidationError && res.Response == nil {
+		ctx.AbortWithStatusJSON(400, core.StatusResponse{Status: res.Status})
+		return
+	}
+
+	// Failed without response
+	if res != nil && res.Status.Code != core.Success && res.Response == nil {
+		ctx.AbortWithStatusJSON(500, core.StatusResponse{Status: res.Status})
+		return
+	}
+
+	// Cached routes
+	if res != nil && res.ETag != "" {
+		ctx.Header("ETag", res.ETag)
+		ctx.Header("Cache-Control", res.CacheControl)
+		ctx.Header("Vary", "Accept, Authorization, X-Field-Mask") // parts of cache key
+		ctx.Header("X-Cache", res.CacheStatus)
+		if etagMatches(ctx.GetHeader("If-None-Match"), res.ETag) {
+			ctx.Status(304)
+			return
+		}
+	}
+
 	// Status
 	if res == nil || res.Status.Code != core.Success || res.Response == nil {
 		ctx.Status(500)
 	} else {
+		ctx.Header("content-type", res.ContentType)
 		ctx.Status(200)
 	}
 
 	// To client
 	ctx.Writer.Write(res.Response)
 }
+
+// stream sends server-streaming responses as SSE (Accept: text/event-stream)
+// or as newline delimited JSON
+func (d *RouterDelivery) stream(ctx *gin.Context, req *domain.RedirectRouteRequest) {
+	sse := acceptsEventStream(ctx.GetHeader("Accept"))
+
+	// Headers are sent with first message, so errors before it are usual responses
+	started := false
+	write := func(event string, msg []byte) error {
+		if !started {
+			if sse {
+				ctx.Header("content-type", "text/event-stream")
+				ctx.Header("cache-control", "no-cache")
+				ctx.Header("x-accel-buffering", "no")
+			} else {
+				ctx.Header("content-type", "application/x-ndjson")
+			}
+			ctx.Status(200)
+			started = true
+		}
+
+		var err error
+		if sse {
+			if event != "" {
+				_, err = fmt.Fprintf(ctx.Writer, "event: %s\n", event)
+			}
+			if err == nil {
+				_, err = fmt.Fprintf(ctx.Writer, "data: %s\n\n", msg)
+			}
+		} else {
+			_, err = fmt.Fprintf(ctx.Writer, "%s\n", msg)
+		}
+		if err != nil {
+			return errors.Wrap(err, "cannot write stream message to client")
+		}
+		ctx.Writer.Flush()
+		return nil
+	}
+
+	res, err := d.routerUCase.Stream(ctx, req, func(msg []byte) error {
+		return write("", msg)
+	})
+	if err != nil {
+		// Client went away - upstream stream is canceled with request context
+		if ctx.Request.Context().Err() != nil {
+			return
+		}
+		_ = ctx.Error(errors.Wrapf(err, "cannot stream client`s request"))
+		res = &domain.RedirectRouteResponse{
+			Status: core.Status{
+				Code: core.ServerError,
+			},
+		}
+	}
+	if res.Status.Code == core.Success {
+		if !started {
+			ctx.Status(200)
+		}
+		return
+	}
+
+	if !started {
+		code := 500
+		switch res.Status.Code {
+		case core.ValidationError:
+			code = 400
+		case core.Unavailable:
+			code = 503
+		}
+		ctx.AbortWithStatusJSON(code, core.StatusResponse{Status: res.Status})
+		return
+	}
+	msg, _ := json.Marshal(core.StatusResponse{Status: res.Status})
+	_ = write("error", msg)
+}
+
+func (d *RouterDelivery) authToken(ctx *gin.Context) *string {
+	authTokens := ctx.Request.Header["Authorization"]
+	if authTokens != nil && len(authTokens) > 0 {
+		d.log.WithContext(ctx).With(core.Fields{"token_hash": core.TokenHash(authTokens[0])}).Debug("Authorization access with token")
+		return &authTokens[0]
+	}
+	d.log.WithContext(ctx).Debug("Call without token:")
+	return nil
+}
+
+// etagMatches checks If-None-Match header (list of tags or *)
+func etagMatches(ifNoneMatch, etag string) bool {
+	if ifNoneMatch == "" {
+		return false
+	}
+	for _, tag := range strings.Split(ifNoneMatch, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" || strings.TrimPrefix(tag, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// parseFieldMask splits comma separated paths ("a,b.c")
+func parseFieldMask(value string) []string {
+	var paths []string
+	for _, path := range strings.Split(value, ",") {
+		if path = strings.TrimSpace(path); path != "" {
+			paths = append(paths, path)
+		}
+	}
+	return paths
+}
+
+// negotiateAccept chooses response media type by Accept header (JSON by default)
+func negotiateAccept(accept string) string {
+	best, bestQ := app.ContentTypeJson, 0.0
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil || !supportedContentTypes[mediaType] {
+			continue
+		}
+		q := 1.0
+		if v, ok := params["q"]; ok {
+			q, err = strconv.ParseFloat(v, 64)
+			if err != nil {
+				continue
+			}
+		}
+		if q > bestQ {
+			best, bestQ = mediaType, q
+		}
+	}
+	return best
+}
+
+func acceptsEventStream(accept string) bool {
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, _, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err == nil && mediaType == "text/event-stream" {
+			return true
+		}
+	}
+	return false
+}
+
+func ceilSeconds(d time.Duration) string {
+	return strconv.FormatInt(int64(math.Ceil(d.Seconds())), 10)
+}
@@ -0,0 +1,75 @@
+package delivery
+
+import (
+	"context"
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+	"microservice/app"
+	"microservice/app/core"
+	"microservice/domain"
+	"net/http/httptest"
+	"testing"
+)
+
+type testRedirectUCase struct {
+	domain.RedirectUCase
+	response *domain.RedirectRouteResponse
+}
+
+func (u *testRedirectUCase) Route(context.Context, *domain.RedirectRouteRequest) (*domain.RedirectRouteResponse, error) {
+	return u.response, nil
+}
+
+func TestEtagMatches(t *testing.T) {
+	require.False(t, etagMatches("", `"a"`))
+	require.True(t, etagMatches(`"a"`, `"a"`))
+	require.True(t, etagMatches(`"b", W/"a"`, `"a"`))
+	require.True(t, etagMatches("*", `"a"`))
+	require.False(t, etagMatches(`"b"`, `"a"`))
+}
+
+func TestRouteCachedResponse(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	d := NewRouterDelivery(app.NewDefaultLogger(logrus.New()), &testRedirectUCase{
+		response: &domain.RedirectRouteResponse{
+			Status:       core.Status{Code: core.Success},
+			Response:     []byte(`{"id":1}`),
+			ContentType:  app.ContentTypeJson,
+			ETag:         `"a"`,
+			CacheControl: "public, max-age=60",
+			CacheStatus:  domain.CacheHit,
+		},
+	}, nil)
+	engine := gin.New()
+	engine.GET("/users", func(ctx *gin.Context) {
+		ctx.Set(resolvedRouteKey, &domain.RedirectResolveResponse{Route: &domain.Route{}})
+	}, d.Route)
+
+	serve := func(ifNoneMatch string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest("GET", "/users", nil)
+		if ifNoneMatch != "" {
+			r.Header.Set("If-None-Match", ifNoneMatch)
+		}
+		engine.ServeHTTP(w, r)
+		return w
+	}
+
+	w := serve("")
+	require.Equal(t, 200, w.Code)
+	require.Equal(t, `{"id":1}`, w.Body.String())
+	require.Equal(t, `"a"`, w.Header().Get("ETag"))
+	require.Equal(t, "public, max-age=60", w.Header().Get("Cache-Control"))
+	require.Equal(t, "Accept, Authorization, X-Field-Mask", w.Header().Get("Vary"))
+	require.Equal(t, domain.CacheHit, w.Header().Get("X-Cache"))
+
+	w = serve(`"a"`)
+	require.Equal(t, 304, w.Code)
+	require.Empty(t, w.Body.String())
+	require.Equal(t, `"a"`, w.Header().Get("ETag"))
+
+	w = serve(`"b"`)
+	require.Equal(t, 200, w.Code)
+	require.Equal(t, `{"id":1}`, w.Body.String())
+}
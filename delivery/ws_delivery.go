@@ -0,0 +1,227 @@
+package delivery
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"github.com/pkg/errors"
+	"github.com/spf13/viper"
+	"io"
+	"microservice/app"
+	"microservice/app/core"
+	"microservice/domain"
+	"microservice/services"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	wsWriteWait  = 10 * time.Second
+	wsPongWait   = 60 * time.Second
+	wsPingPeriod = wsPongWait * 9 / 10
+
+	// Messages from instance waiting for slow client
+	wsBufferSize = 16
+
+	// Close reason fits into control frame (125 bytes with 2 bytes of code)
+	wsMaxCloseReason = 123
+)
+
+// WsDelivery bridges WebSocket with client-streaming and bidirectional methods.
+// Each text frame is a JSON message, empty text frame ends client`s messages
+type WsDelivery struct {
+	log         core.Logger
+	routerUCase domain.RedirectUCase
+	upgrader    websocket.Upgrader
+}
+
+func NewWsDelivery(log core.Logger,
+	routerUCase domain.RedirectUCase) *WsDelivery {
+	return &WsDelivery{
+		log:         log,
+		routerUCase: routerUCase,
+		upgrader: websocket.Upgrader{
+			CheckOrigin: func(r *http.Request) bool {
+				return true
+			},
+		},
+	}
+}
+
+func (d *WsDelivery) Route(g *gin.RouterGroup) error {
+	g.GET("/*address", d.Connect)
+	return nil
+}
+
+// wsFrame is a message from instance or stream error
+type wsFrame struct {
+	data []byte
+	err  error
+}
+
+func (d *WsDelivery) Connect(ctx *gin.Context) {
+	ctx.Header("content-type", "application/json")
+
+	// Browsers cannot set headers for WebSocket
+	var authToken *string
+	if token := ctx.GetHeader("Authorization"); token != "" {
+		authToken = &token
+	} else if token, ok := ctx.GetQuery("access_token"); ok {
+		authToken = &token
+	}
+
+	// Authorize once for whole connection
+	address := strings.TrimSuffix(ctx.Param("address"), "/")
+	resolved, err := d.routerUCase.Resolve(ctx, &domain.RedirectResolveRequest{
+		AuthToken: authToken,
+		Address:   address,
+	})
+	if err != nil {
+		_ = ctx.Error(errors.Wrapf(err, "cannot resolve client`s stream"))
+		ctx.AbortWithStatus(500)
+		return
+	}
+	if resolved.Status.Code != core.Success {
+		ctx.AbortWithStatusJSON(500, core.StatusResponse{Status: resolved.Status})
+		return
+	}
+
+	streamCtx, cancel := context.WithCancel(ctx.Request.Context())
+	defer cancel()
+
+	res, err := d.routerUCase.OpenStream(streamCtx, &domain.RedirectRouteRequest{
+		Address:  address,
+		Resolved: resolved,
+	})
+	if err != nil {
+		_ = ctx.Error(errors.Wrapf(err, "cannot open client`s stream"))
+		ctx.AbortWithStatus(500)
+		return
+	}
+	if res.Status.Code == core.Unavailable {
+		ctx.AbortWithStatusJSON(503, core.StatusResponse{Status: res.Status})
+		return
+	}
+	if res.Status.Code != core.Success {
+		ctx.AbortWithStatusJSON(500, core.StatusResponse{Status: res.Status})
+		return
+	}
+
+	conn, err := d.upgrader.Upgrade(ctx.Writer, ctx.Request, nil)
+	if err != nil {
+		d.log.WithContext(ctx).DebugWrap(err, "cannot upgrade connection to websocket")
+		return
+	}
+	defer conn.Close()
+
+	d.relay(streamCtx, cancel, conn, res.Stream)
+}
+
+func (d *WsDelivery) relay(ctx context.Context, cancel context.CancelFunc, conn *websocket.Conn, stream domain.RedirectStream) {
+
+	// Client -> instance (gRPC flow control slows client down)
+	go func() {
+		conn.SetReadLimit(viper.GetInt64("rest.max_request_size"))
+		_ = conn.SetReadDeadline(time.Now().Add(wsPongWait))
+		conn.SetPongHandler(func(string) error {
+			return conn.SetReadDeadline(time.Now().Add(wsPongWait))
+		})
+
+		closed := false
+		for {
+			msgType, data, err := conn.ReadMessage()
+			if err != nil {
+				// Client went away - cancel instance stream
+				cancel()
+				return
+			}
+			if msgType != websocket.TextMessage {
+				d.closeWith(ctx, conn, websocket.CloseUnsupportedData, "only text frames are supported")
+				cancel()
+				return
+			}
+			if closed {
+				continue
+			}
+			if len(data) == 0 {
+				closed = true
+				_ = stream.CloseSend()
+				continue
+			}
+			if err = stream.Send(data); err != nil {
+				if errors.Is(err, services.ErrInvalidMessage) || app.IsValidationError(err) {
+					d.closeWith(ctx, conn, websocket.CloseInvalidFramePayloadData, err.Error())
+				} else {
+					d.log.WithContext(ctx).DebugWrap(err, "cannot send client`s message to stream")
+				}
+				cancel()
+				return
+			}
+		}
+	}()
+
+	// Instance -> client (slow client slows instance down)
+	frames := make(chan wsFrame, wsBufferSize)
+	go func() {
+		defer close(frames)
+		for {
+			data, err := stream.Recv()
+			if err == io.EOF {
+				return
+			}
+			select {
+			case frames <- wsFrame{data: data, err: err}:
+			case <-ctx.Done():
+				return
+			}
+			if err != nil {
+				return
+			}
+		}
+	}()
+
+	// Only this goroutine writes messages (reader sends only close control frame)
+	ticker := time.NewTicker(wsPingPeriod)
+	defer ticker.Stop()
+	for {
+		select {
+		case frame, ok := <-frames:
+			_ = conn.SetWriteDeadline(time.Now().Add(wsWriteWait))
+			if !ok {
+				_ = conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+				return
+			}
+			if frame.err != nil {
+				d.log.WithContext(ctx).ErrorWrap(frame.err, "error in websocket stream")
+				msg, _ := json.Marshal(core.StatusResponse{
+					Status: core.Status{
+						Code: core.ServerError,
+					},
+				})
+				_ = conn.WriteMessage(websocket.TextMessage, msg)
+				_ = conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseInternalServerErr, ""))
+				return
+			}
+			if err := conn.WriteMessage(websocket.TextMessage, frame.data); err != nil {
+				return
+			}
+		case <-ticker.C:
+			if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(wsWriteWait)); err != nil {
+				return
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// closeWith sends close frame with reason of rejected client`s message
+func (d *WsDelivery) closeWith(ctx context.Context, conn *websocket.Conn, code int, reason string) {
+	d.log.WithContext(ctx).Warn("websocket stream is closed (%d): %s", code, reason)
+	if len(reason) > wsMaxCloseReason {
+		reason = strings.ToValidUTF8(reason[:wsMaxCloseReason], "")
+	}
+	_ = conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(code, reason), time.Now().Add(wsWriteWait))
+}
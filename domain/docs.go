@@ -0,0 +1,10 @@
+package domain
+
+import (
+	"context"
+	"microservice/app/openapi"
+)
+
+type DocsUCase interface {
+	OpenApi(context.Context) (*openapi.Document, error)
+}
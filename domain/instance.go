@@ -7,12 +7,38 @@ import (
 )
 
 type Instance struct {
-	Id       int32  `json:"id"`
-	Name     string `json:"name"`
-	Folder   string `json:"folder"`
-	Endpoint string `json:"endpoint"`
-	IsActive bool   `json:"is_active"`
-	Status   bool   `json:"status"`
+	Id       int32
+	Name     string
+	Folder   string
+	Endpoint string
+	IsActive bool
+	Status   bool
+
+	// HealthCheck is active check of instance (nil - StatusService.Ping by status job)
+	HealthCheck *InstanceHealthCheck
+}
+
+// Protocols of InstanceHealthCheck
+const (
+	HealthCheckPing = "ping" // StatusService.Ping of instance
+	HealthCheckGrpc = "grpc" // grpc.health.v1.Health
+)
+
+// InstanceHealthCheck is configured per instance, zero values are taken from health.* config
+type InstanceHealthCheck struct {
+	Protocol   string `json:"protocol"`
+	IntervalMs int32  `json:"interval_ms,omitempty"`
+	TimeoutMs  int32  `json:"timeout_ms,omitempty"`
+
+	// Consecutive results which switch instance`s state
+	HealthyThreshold   int32 `json:"healthy_threshold,omitempty"`
+	UnhealthyThreshold int32 `json:"unhealthy_threshold,omitempty"`
+
+	// Services of grpc.health.v1 which should be SERVING (empty - whole server)
+	Services []string `json:"services,omitempty"`
+
+	// Watch uses Health/Watch streams instead of Check by interval
+	Watch bool `json:"watch,omitempty"`
 }
 
 type InstancesRepository interface {
@@ -24,12 +50,24 @@ type InstancesRepository interface {
 }
 
 type InstancesUCase interface {
-	All(context.Context) (*InstancesAllResponse, error)
+	All(context.Context) ([]*Instance, error)
 	Update(context.Context, *tools.UpdateReq) (*core.StatusResponse, error)
+	Reload(context.Context) (*core.StatusResponse, error)
+	Status(context.Context) (*InstancesStatusResponse, error)
 }
 
-// Delivery
-type InstancesAllResponse struct {
-	StatusCode string      `json:"status"`
-	Instances  []*Instance `json:"services"`
+type InstancesStatusResponse struct {
+	Status    core.Status
+	Instances []*InstanceStatus
+}
+
+// InstanceStatus is instance`s availability and services loaded from proto files
+type InstanceStatus struct {
+	Name     string
+	Status   bool
+	Error    string
+	Services []string
+
+	// Protocol of active health check ("" - status job ping)
+	HealthCheck string
 }
@@ -3,21 +3,89 @@ package domain
 import (
 	"context"
 	"microservice/app/core"
+	"net/url"
+)
+
+// Cache statuses of route response
+const (
+	CacheHit   = "HIT"
+	CacheMiss  = "MISS"
+	CacheStale = "STALE"
 )
 
 type RedirectUCase interface {
+	Resolve(context.Context, *RedirectResolveRequest) (*RedirectResolveResponse, error)
+	ResolveMethod(context.Context, *RedirectResolveMethodRequest) (*RedirectResolveResponse, error)
 	Route(context.Context, *RedirectRouteRequest) (*RedirectRouteResponse, error)
+	Stream(ctx context.Context, req *RedirectRouteRequest, send func([]byte) error) (*RedirectRouteResponse, error)
+	OpenStream(context.Context, *RedirectRouteRequest) (*RedirectStreamResponse, error)
 }
 
 //
 
+type RedirectResolveRequest struct {
+	AuthToken *string
+	Address   string
+
+	// HTTP method of request (empty - any route`s method)
+	Method string
+}
+
+// RedirectResolveMethodRequest is a native gRPC call (/package.Service/Method)
+type RedirectResolveMethodRequest struct {
+	AuthToken  *string
+	FullMethod string
+}
+
+// RedirectResolveResponse is a found and authorized route
+type RedirectResolveResponse struct {
+	Status     core.Status
+	Route      *Route
+	UserId     *int32
+	PathParams map[string]string
+
+	// Route`s method is server-streaming
+	Streaming bool
+}
+
 type RedirectRouteRequest struct {
 	AuthToken *string
 	Address   string
 	Data      []byte
+
+	// Query parameters mapped into request message
+	Query url.Values
+
+	// Media types of Data and of expected response (JSON if empty)
+	ContentType string
+	Accept      string
+
+	// Response fields selected by client (nil - all)
+	FieldMask []string
+
+	// Resolved route (if nil then ucase resolves it by itself)
+	Resolved *RedirectResolveResponse
 }
 
 type RedirectRouteResponse struct {
-	Status   core.Status
-	Response []byte
+	Status      core.Status
+	Response    []byte
+	ContentType string
+
+	// Only for routes with cache: validator, Cache-Control and HIT/MISS/STALE
+	ETag         string
+	CacheControl string
+	CacheStatus  string
+}
+
+// RedirectStream is an opened stream with instance (messages are JSON)
+type RedirectStream interface {
+	Send([]byte) error
+	Recv() ([]byte, error) // io.EOF if stream is finished
+	CloseSend() error
+}
+
+type RedirectStreamResponse struct {
+	Status core.Status
+	Stream RedirectStream
 }
@@ -2,9 +2,18 @@ package domain
 
 import (
 	"context"
+	"encoding/json"
 	"microservice/app/core"
 )
 
+// Rate limit keys (who shares one token bucket on a route)
+const (
+	RateLimitByIp     = "ip"
+	RateLimitByUser   = "user"
+	RateLimitByApiKey = "api_key"
+	RateLimitByRoute  = "route"
+)
+
 type Route struct {
 	Id           int64
 	HttpMethod   string
@@ -14,11 +23,118 @@ type Route struct {
 	ProtoMethod  string
 	AccessRole   core.AccessRole
 	IsActive     bool
+
+	// Rate limiting: RateLimit requests per minute, 0 - unlimited
+	RateLimit   int32
+	RateBurst   int32
+	RateLimitBy string
+
+	// Body limits in bytes, 0 - default from config
+	MaxRequestSize  int64
+	MaxResponseSize int64
+
+	// protojson options (nil - instance or config default)
+	Json JsonOptions
+
+	// Response cache in seconds (0 - disabled), stale response is
+	// returned during CacheStale while it is refreshed in background
+	CacheTtl     int32
+	CacheStale   int32
+	CachePerUser bool
+
+	// Identical concurrent calls share one instance call
+	Coalesce bool
+
+	// Composite route calls several methods (Instance, ProtoService, ProtoMethod are not used)
+	Calls []*RouteCall
+
+	// JSON transforms of request and response (nil - as is)
+	Transform *RouteTransform
+}
+
+// RouteCall is one call of composite route, its JSON result is put under Name key
+type RouteCall struct {
+	Name     string `json:"name"`
+	Instance string `json:"instance"`
+	Service  string `json:"service"`
+	Method   string `json:"method"`
+
+	// Calls which should finish before this one (besides calls used in Params)
+	DependsOn []string `json:"depends_on,omitempty"`
+
+	// Request field path -> source: "request.<path>" (client`s body, query and path params)
+	// or "<call name>.<path>" (result of other call)
+	Params map[string]string `json:"params,omitempty"`
+
+	// Failed optional call is null in response, failed required call fails whole route
+	Optional bool `json:"optional,omitempty"`
+}
+
+// Route envelope modes
+const (
+	EnvelopeWrap   = "wrap"   // {"status": ..., "data": response}
+	EnvelopeUnwrap = "unwrap" // response without status
+)
+
+// RouteTransform changes JSON between client and instance, paths are dotted ("user.name")
+type RouteTransform struct {
+	// Request: client`s path -> instance`s path
+	Rename map[string]string `json:"rename,omitempty"`
+	// Request: paths removed before call
+	Drop []string `json:"drop,omitempty"`
+	// Request: values of absent paths
+	Defaults map[string]json.RawMessage `json:"defaults,omitempty"`
+
+	// Response: only these paths are returned (status is always kept)
+	Fields []string `json:"fields,omitempty"`
+	// Response: status envelope, EnvelopeWrap or EnvelopeUnwrap ("" - as is)
+	Envelope string `json:"envelope,omitempty"`
+}
+
+func (r *Route) IsComposite() bool {
+	return len(r.Calls) > 0
+}
+
+type JsonOptions struct {
+	UseProtoNames   *bool
+	EmitUnpopulated *bool
+	UseEnumNumbers  *bool
+	DiscardUnknown  *bool
 }
 
 type RoutesRepository interface {
 	All(context.Context) ([]*Route, error)
-	GetByAddress(ctx context.Context, addr string) (*Route, error)
+	GetByAddress(ctx context.Context, method, addr string) (*Route, error)
+	GetByMethod(ctx context.Context, service, method string) ([]*Route, error)
 	Insert(context.Context, *Route) error
 	Delete(context.Context, int64) error
 }
+
+type RoutesUCase interface {
+	All(context.Context) (*RoutesAllResponse, error)
+	Create(context.Context, *Route) (*RoutesCreateResponse, error)
+	Delete(context.Context, int64) (*core.StatusResponse, error)
+	PurgeCache(context.Context, *RoutesPurgeCacheRequest) (*RoutesPurgeCacheResponse, error)
+}
+
+// Delivery
+type RoutesAllResponse struct {
+	Status core.Status
+	Routes []*Route
+}
+
+type RoutesCreateResponse struct {
+	Status core.Status
+	Id     int64
+}
+
+// RoutesPurgeCacheRequest removes cached responses of route and/or with path prefix
+type RoutesPurgeCacheRequest struct {
+	RouteId int64
+	Prefix  string
+}
+
+type RoutesPurgeCacheResponse struct {
+	Status core.Status
+	Purged int
+}
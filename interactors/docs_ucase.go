@@ -0,0 +1,355 @@
+package interactors
+
+import (
+	"context"
+	"fmt"
+	"github.com/pkg/errors"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"microservice/app"
+	"microservice/app/core"
+	"microservice/app/openapi"
+	"microservice/domain"
+	"microservice/services"
+	"strings"
+)
+
+const (
+	docsPathPrefix   = "/api/v1"
+	docsStatusSchema = "gateway.StatusResponse"
+	docsBearerAuth   = "bearerAuth"
+	docsCompositeTag = "composite"
+)
+
+// DocsInteractor describes routes table with instances` proto descriptors
+type DocsInteractor struct {
+	log           core.Logger
+	routesRepo    domain.RoutesRepository
+	callerService *services.ProtoCallerService
+}
+
+func NewDocsInteractor(log core.Logger,
+	routesRepo domain.RoutesRepository,
+	callerService *services.ProtoCallerService) *DocsInteractor {
+	return &DocsInteractor{
+		log:           log,
+		routesRepo:    routesRepo,
+		callerService: callerService,
+	}
+}
+
+// OpenApi генерирует OpenAPI 3 документ для всех маршрутов /api/v1
+func (s *DocsInteractor) OpenApi(ctx context.Context) (*openapi.Document, error) {
+	routes, err := s.routesRepo.All(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "error while getting routes list")
+	}
+
+	doc := openapi.NewDocument("API Gateway", "v1")
+	doc.Components.SecuritySchemes[docsBearerAuth] = &openapi.SecurityScheme{
+		Type:         "http",
+		Scheme:       "bearer",
+		BearerFormat: "JWT",
+		Description:  "Access token of auth_service",
+	}
+	doc.Components.Schemas[docsStatusSchema] = &openapi.Schema{
+		Type: "object",
+		Properties: map[string]*openapi.Schema{
+			"status": {
+				Type: "object",
+				Properties: map[string]*openapi.Schema{
+					"code":    {Type: "string"},
+					"message": {Type: "string"},
+					"errors": {
+						Type: "array",
+						Items: &openapi.Schema{
+							Type: "object",
+							Properties: map[string]*openapi.Schema{
+								"path":    {Type: "string"},
+								"message": {Type: "string"},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	schemas := openapi.NewSchemas(doc)
+	operationIds := map[string]int{}
+	for _, route := range routes {
+		var op *openapi.Operation
+		if route.IsComposite() {
+			op, err = s.compositeOperation(schemas, route)
+		} else {
+			var method *app.ProtoMethod
+			method, err = s.callerService.Method(route.Instance, route.ProtoService, route.ProtoMethod)
+			if err == nil {
+				op = s.operation(schemas, route, method.Request(), method.Response(), method.IsServerStreaming())
+			}
+		}
+		if err != nil {
+			// Route cannot be called, so it is not documented
+			s.log.WarnWrap(err, "route %s is not documented", route.HttpAddress)
+			continue
+		}
+
+		// Same method may be routed several times
+		operationIds[op.OperationId]++
+		if n := operationIds[op.OperationId]; n > 1 {
+			op.OperationId = fmt.Sprintf("%s_%d", op.OperationId, n)
+		}
+
+		doc.AddTag(op.Tags[0])
+		doc.AddOperation(docsPathPrefix+route.HttpAddress, docsHttpMethod(route), op)
+	}
+	return doc, nil
+}
+
+func (s *DocsInteractor) operation(schemas *openapi.Schemas, route *domain.Route,
+	request, response protoreflect.MessageDescriptor, streaming bool) *openapi.Operation {
+
+	jsonOptions := routeJsonOptions(route)
+	schemaOptions := openapi.SchemaOptions{
+		Namespace:      route.Instance,
+		UseProtoNames:  jsonOptions.UseProtoNames,
+		UseEnumNumbers: jsonOptions.UseEnumNumbers,
+	}
+
+	op := &openapi.Operation{
+		Tags:        []string{route.Instance},
+		Summary:     route.ProtoService + "." + route.ProtoMethod,
+		OperationId: route.ProtoService + "_" + route.ProtoMethod,
+		Responses:   map[string]*openapi.Response{},
+	}
+
+	// Path params are fields of request too
+	pathParams := map[string]bool{}
+	for _, part := range strings.Split(route.HttpAddress, "/") {
+		if strings.HasPrefix(part, "{") && strings.HasSuffix(part, "}") {
+			name := strings.Trim(part, "{}")
+			pathParams[name] = true
+			op.Parameters = append(op.Parameters, &openapi.Parameter{
+				Name:     name,
+				In:       "path",
+				Required: true,
+				Schema:   docsParamSchema(schemas, request, name, schemaOptions),
+			})
+		}
+	}
+
+	switch docsHttpMethod(route) {
+	case "get", "delete":
+		op.Parameters = append(op.Parameters, docsQueryParams(schemas, request, pathParams, schemaOptions)...)
+	default:
+		op.RequestBody = &openapi.RequestBody{
+			Content: map[string]*openapi.MediaType{
+				"application/json":       {Schema: schemas.Message(request, schemaOptions)},
+				"application/x-protobuf": {Schema: &openapi.Schema{Type: "string", Format: "binary"}},
+			},
+		}
+	}
+
+	// Responses
+	ok := &openapi.Response{Description: string(response.FullName())}
+	if streaming {
+		ok.Description += " (stream)"
+		ok.Content = map[string]*openapi.MediaType{
+			"text/event-stream":    {Schema: schemas.Message(response, schemaOptions)},
+			"application/x-ndjson": {Schema: schemas.Message(response, schemaOptions)},
+		}
+	} else {
+		ok.Content = map[string]*openapi.MediaType{
+			"application/json":       {Schema: schemas.Message(response, schemaOptions)},
+			"application/x-protobuf": {Schema: &openapi.Schema{Type: "string", Format: "binary"}},
+		}
+	}
+	op.Responses["200"] = ok
+	s.routeResponses(op, route)
+	return op
+}
+
+// compositeOperation documents route which merges results of several calls
+func (s *DocsInteractor) compositeOperation(schemas *openapi.Schemas, route *domain.Route) (*openapi.Operation, error) {
+	jsonOptions := routeJsonOptions(route)
+
+	var names []string
+	request := &openapi.Schema{Type: "object", Properties: map[string]*openapi.Schema{}}
+	response := &openapi.Schema{
+		Type: "object",
+		Properties: map[string]*openapi.Schema{
+			"status": {
+				Type: "object",
+				Properties: map[string]*openapi.Schema{
+					"code":    {Type: "string"},
+					"message": {Type: "string"},
+				},
+			},
+			"errors": {
+				Type:                 "object",
+				Description:          "Errors of failed optional calls",
+				AdditionalProperties: &openapi.Schema{Type: "string"},
+			},
+		},
+	}
+	for _, call := range route.Calls {
+		method, err := s.callerService.Method(call.Instance, call.Service, call.Method)
+		if err != nil {
+			return nil, errors.Wrapf(err, "call %s", call.Name)
+		}
+		schemaOptions := openapi.SchemaOptions{
+			Namespace:      call.Instance,
+			UseProtoNames:  jsonOptions.UseProtoNames,
+			UseEnumNumbers: jsonOptions.UseEnumNumbers,
+		}
+		request.Properties[call.Name] = schemas.Message(method.Request(), schemaOptions)
+		response.Properties[call.Name] = schemas.Message(method.Response(), schemaOptions)
+		names = append(names, call.Name)
+	}
+
+	op := &openapi.Operation{
+		Tags:        []string{docsCompositeTag},
+		Summary:     "Composite: " + strings.Join(names, ", "),
+		OperationId: "composite_" + strings.Join(names, "_"),
+		Responses:   map[string]*openapi.Response{},
+	}
+	for _, part := range strings.Split(route.HttpAddress, "/") {
+		if strings.HasPrefix(part, "{") && strings.HasSuffix(part, "}") {
+			op.Parameters = append(op.Parameters, &openapi.Parameter{
+				Name:     strings.Trim(part, "{}"),
+				In:       "path",
+				Required: true,
+				Schema:   &openapi.Schema{Type: "string"},
+			})
+		}
+	}
+	switch docsHttpMethod(route) {
+	case "get", "delete":
+	default:
+		op.RequestBody = &openapi.RequestBody{
+			Content: map[string]*openapi.MediaType{
+				"application/json": {Schema: request},
+			},
+		}
+	}
+
+	op.Responses["200"] = &openapi.Response{
+		Description: "Results of calls by names",
+		Content: map[string]*openapi.MediaType{
+			"application/json": {Schema: response},
+		},
+	}
+	s.routeResponses(op, route)
+	return op, nil
+}
+
+// routeResponses adds gateway`s responses, field mask and auth of route
+func (s *DocsInteractor) routeResponses(op *openapi.Operation, route *domain.Route) {
+	op.Parameters = append(op.Parameters,
+		&openapi.Parameter{
+			Name:        "fields",
+			In:          "query",
+			Description: "Comma separated response fields",
+			Schema:      &openapi.Schema{Type: "string"},
+		},
+		&openapi.Parameter{
+			Name:        "X-Field-Mask",
+			In:          "header",
+			Description: "Comma separated response fields (instead of fields)",
+			Schema:      &openapi.Schema{Type: "string"},
+		},
+	)
+	op.Responses["400"] = &openapi.Response{
+		Description: "Validation error of request fields",
+		Content: map[string]*openapi.MediaType{
+			"application/json": {Schema: openapi.Ref(docsStatusSchema)},
+		},
+	}
+	op.Responses["default"] = &openapi.Response{
+		Description: "Gateway error",
+		Content: map[string]*openapi.MediaType{
+			"application/json": {Schema: openapi.Ref(docsStatusSchema)},
+		},
+	}
+	if route.RateLimit > 0 {
+		limitBy := route.RateLimitBy
+		if limitBy == "" {
+			limitBy = domain.RateLimitByIp
+		}
+		op.Responses["429"] = &openapi.Response{
+			Description: fmt.Sprintf("Rate limit is %d requests per minute (by %s)", route.RateLimit, limitBy),
+			Headers: map[string]*openapi.Header{
+				"Retry-After": {Schema: &openapi.Schema{Type: "integer"}},
+			},
+		}
+	}
+
+	if route.CacheTtl > 0 {
+		op.Responses["304"] = &openapi.Response{
+			Description: fmt.Sprintf("Not modified (If-None-Match), response is cached for %d seconds", route.CacheTtl),
+		}
+	}
+
+	// Auth
+	if route.AccessRole > core.RoleGuest {
+		op.Description = fmt.Sprintf("Requires access role %s", docsRoleName(route.AccessRole))
+		op.Security = []map[string][]string{{docsBearerAuth: {}}}
+	}
+}
+
+// docsQueryParams are top level scalar fields of request
+func docsQueryParams(schemas *openapi.Schemas, request protoreflect.MessageDescriptor,
+	exclude map[string]bool, opts openapi.SchemaOptions) []*openapi.Parameter {
+
+	var params []*openapi.Parameter
+	fields := request.Fields()
+	for i := 0; i < fields.Len(); i++ {
+		fd := fields.Get(i)
+		if fd.IsMap() || exclude[fd.JSONName()] || exclude[string(fd.Name())] {
+			continue
+		}
+		if fd.Message() != nil && !openapi.IsWellKnown(fd.Message()) {
+			continue // nested messages are passed as field.subfield
+		}
+
+		name := fd.JSONName()
+		if opts.UseProtoNames {
+			name = string(fd.Name())
+		}
+		params = append(params, &openapi.Parameter{
+			Name:   name,
+			In:     "query",
+			Schema: schemas.Field(fd, opts),
+		})
+	}
+	return params
+}
+
+func docsParamSchema(schemas *openapi.Schemas, request protoreflect.MessageDescriptor,
+	name string, opts openapi.SchemaOptions) *openapi.Schema {
+
+	fd := request.Fields().ByJSONName(name)
+	if fd == nil {
+		fd = request.Fields().ByName(protoreflect.Name(name))
+	}
+	if fd == nil {
+		return &openapi.Schema{Type: "string"}
+	}
+	return schemas.Field(fd, opts)
+}
+
+func docsHttpMethod(route *domain.Route) string {
+	if route.HttpMethod == "" {
+		return "post"
+	}
+	return strings.ToLower(route.HttpMethod)
+}
+
+func docsRoleName(role core.AccessRole) string {
+	switch role {
+	case core.RoleUser:
+		return "user"
+	case core.RoleSuperAdmin:
+		return "super admin"
+	}
+	return fmt.Sprintf("%d", role)
+}
@@ -3,29 +3,34 @@ package interactors
 import (
 	"context"
 	"github.com/pkg/errors"
+	"microservice/app"
 	"microservice/app/core"
 	"microservice/domain"
 	"microservice/services"
 	"microservice/tools"
+	"sort"
 )
 
 type InstanceInteractor struct {
 	log           core.Logger
 	servicesRepo  domain.InstancesRepository
 	statusService *services.StatusService
+	protoRegistry *app.ProtoRegistry
 }
 
 func NewInstanceInteractor(log core.Logger,
 	repo domain.InstancesRepository,
-	statusService *services.StatusService) *InstanceInteractor {
+	statusService *services.StatusService,
+	protoRegistry *app.ProtoRegistry) *InstanceInteractor {
 	return &InstanceInteractor{
 		log:           log,
 		servicesRepo:  repo,
 		statusService: statusService,
+		protoRegistry: protoRegistry,
 	}
 }
 
-func (s *InstanceInteractor) All(ctx context.Context) (*domain.InstancesAllResponse, error) {
+func (s *InstanceInteractor) All(ctx context.Context) ([]*domain.Instance, error) {
 	items, err := s.servicesRepo.All(ctx)
 	if err != nil {
 		return nil, errors.Wrap(err, "error while getting services list %s")
@@ -42,10 +47,7 @@ func (s *InstanceInteractor) All(ctx context.Context) (*domain.InstancesAllRespo
 		}
 	}
 
-	return &domain.InstancesAllResponse{
-		StatusCode: core.Success,
-		Instances:  items,
-	}, nil
+	return items, nil
 }
 
 func (s *InstanceInteractor) Update(ctx context.Context, req *tools.UpdateReq) (*core.StatusResponse, error) {
@@ -60,3 +62,51 @@ func (s *InstanceInteractor) Update(ctx context.Context, req *tools.UpdateReq) (
 		},
 	}, nil
 }
+
+// Reload перечитывает proto файлы всех инстансов
+func (s *InstanceInteractor) Reload(ctx context.Context) (*core.StatusResponse, error) {
+	if err := s.protoRegistry.Reload(); err != nil {
+		// Old registry is still in use
+		s.log.ErrorWrap(err, "cannot reload proto registry")
+		return &core.StatusResponse{
+			Status: core.Status{
+				Code:    core.ValidationError,
+				Message: err.Error(),
+			},
+		}, nil
+	}
+	return &core.StatusResponse{
+		Status: core.Status{
+			Code: core.Success,
+		},
+	}, nil
+}
+
+func (s *InstanceInteractor) Status(ctx context.Context) (*domain.InstancesStatusResponse, error) {
+	res := &domain.InstancesStatusResponse{
+		Status: core.Status{
+			Code: core.Success,
+		},
+	}
+
+	names := s.protoRegistry.Instances()
+	sort.Strings(names)
+	for _, name := range names {
+		instance := s.protoRegistry.Instance(name)
+		if instance == nil {
+			continue // registry was reloaded
+		}
+		item := &domain.InstanceStatus{
+			Name:        name,
+			Services:    instance.Services(),
+			HealthCheck: s.statusService.HealthCheck(name),
+		}
+		status, err := s.statusService.GetStatus(ctx, name)
+		if err != nil {
+			item.Error = err.Error()
+		}
+		item.Status = status
+		res.Instances = append(res.Instances, item)
+	}
+	return res, nil
+}
@@ -0,0 +1,195 @@
+package interactors
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"github.com/pkg/errors"
+	"github.com/spf13/viper"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/dynamicpb"
+	"math"
+	"microservice/app"
+	"microservice/app/cache"
+	"microservice/app/core"
+	"microservice/app/metrics"
+	"microservice/domain"
+	"microservice/services"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// routeCached returns response from cache or calls instance and caches it
+func (ucase *RedirectUCase) routeCached(ctx context.Context, resolved *domain.RedirectResolveResponse,
+	req *domain.RedirectRouteRequest, callOptions services.ProtoCall) (*domain.RedirectRouteResponse, error) {
+
+	route := resolved.Route
+	key := cacheKey(resolved, req, callOptions.OutputCodec.ContentType())
+
+	entry, err := ucase.cacheStore.Get(ctx, key)
+	if err != nil {
+		// Cache is unavailable - instance is called
+		ucase.log.WithContext(ctx).ErrorWrap(err, "cannot get cached response (route=%d)", route.Id)
+	}
+
+	now := time.Now()
+	if entry != nil {
+		if entry.IsFresh(now) {
+			metrics.CacheRequests.WithLabelValues(route.HttpAddress, domain.CacheHit).Inc()
+			return cachedResponse(route, entry, domain.CacheHit, now), nil
+		}
+		metrics.CacheRequests.WithLabelValues(route.HttpAddress, domain.CacheStale).Inc()
+		ucase.refresh(ctx, route, key, callOptions)
+		return cachedResponse(route, entry, domain.CacheStale, now), nil
+	}
+	metrics.CacheRequests.WithLabelValues(route.HttpAddress, domain.CacheMiss).Inc()
+
+	res, cacheable, err := ucase.call(ctx, route, callOptions)
+	if err != nil || !cacheable {
+		return res, err
+	}
+	entry = ucase.store(ctx, route, key, res)
+	return cachedResponse(route, entry, domain.CacheMiss, entry.StoredAt), nil
+}
+
+// refresh calls instance for stale entry in background (once per key)
+func (ucase *RedirectUCase) refresh(requestCtx context.Context, route *domain.Route, key string, callOptions services.ProtoCall) {
+	if _, loaded := ucase.refreshing.LoadOrStore(key, true); loaded {
+		return
+	}
+
+	go func() {
+		defer ucase.refreshing.Delete(key)
+
+		timeout := viper.GetDuration("cache.refresh_timeout")
+		if timeout <= 0 {
+			timeout = 30 * time.Second
+		}
+		ctx, cancel := context.WithTimeout(core.WithRequestId(context.Background(), core.RequestId(requestCtx)), timeout)
+		defer cancel()
+
+		res, cacheable, err := ucase.call(ctx, route, callOptions)
+		if err != nil {
+			ucase.log.WithContext(ctx).WarnWrap(err, "cannot refresh cached response (route=%d)", route.Id)
+			return
+		}
+		if cacheable {
+			ucase.store(ctx, route, key, res)
+		}
+	}()
+}
+
+func (ucase *RedirectUCase) store(ctx context.Context, route *domain.Route, key string, res *domain.RedirectRouteResponse) *cache.Entry {
+	now := time.Now()
+	fresh := now.Add(time.Duration(route.CacheTtl) * time.Second)
+	entry := &cache.Entry{
+		Body:        res.Response,
+		ContentType: res.ContentType,
+		ETag:        cache.ETag(res.Response),
+		StoredAt:    now,
+		FreshUntil:  fresh,
+		StaleUntil:  fresh.Add(time.Duration(route.CacheStale) * time.Second),
+	}
+	if err := ucase.cacheStore.Set(context.Background(), key, entry); err != nil {
+		ucase.log.WithContext(ctx).ErrorWrap(err, "cannot cache response (route=%d)", route.Id)
+	}
+	return entry
+}
+
+func cachedResponse(route *domain.Route, entry *cache.Entry, status string, now time.Time) *domain.RedirectRouteResponse {
+	res := &domain.RedirectRouteResponse{
+		Response:    entry.Body,
+		ContentType: entry.ContentType,
+		ETag:        entry.ETag,
+		CacheStatus: status,
+	}
+	res.Status.Code = core.Success
+
+	// Cache-Control for clients, shared caches keep only responses of guest routes
+	visibility := "public"
+	if route.CachePerUser || route.AccessRole > core.RoleGuest {
+		visibility = "private"
+	}
+	maxAge := int64(0)
+	if entry.IsFresh(now) {
+		maxAge = int64(math.Ceil(entry.FreshUntil.Sub(now).Seconds()))
+	}
+	res.CacheControl = fmt.Sprintf("%s, max-age=%d", visibility, maxAge)
+	if route.CacheStale > 0 {
+		res.CacheControl += ", stale-while-revalidate=" + strconv.Itoa(int(route.CacheStale))
+	}
+	return res
+}
+
+// responseStatusCode reads status.code of response encoded by codec ("" - response has no status)
+func (ucase *RedirectUCase) responseStatusCode(route *domain.Route, codec app.ProtoCodec, data []byte) (string, error) {
+	method, err := ucase.caller.Method(route.Instance, route.ProtoService, route.ProtoMethod)
+	if err != nil {
+		return "", err
+	}
+	response := dynamicpb.NewMessage(method.Response())
+	if err = codec.Unmarshal(data, response); err != nil {
+		return "", errors.Wrapf(err, "cannot decode status of %s", method.FullName())
+	}
+
+	status := response.Descriptor().Fields().ByName("status")
+	if status == nil || status.Message() == nil || status.IsList() {
+		return "", nil
+	}
+	code := status.Message().Fields().ByName("code")
+	if code == nil || code.Kind() != protoreflect.StringKind || code.IsList() {
+		return "", nil
+	}
+	return response.Get(status).Message().Get(code).String(), nil
+}
+
+// cacheKey is route:path?query#body|accept[@user]
+func cacheKey(resolved *domain.RedirectResolveResponse, req *domain.RedirectRouteRequest, accept string) string {
+	key := fmt.Sprintf("%d:%s?%s#%s|%s|%s",
+		resolved.Route.Id,
+		req.Address,
+		req.Query.Encode(), // sorted by key
+		bodyHash(req.Data, req.ContentType),
+		accept,
+		strings.Join(req.FieldMask, ","),
+	)
+	if resolved.Route.CachePerUser {
+		if resolved.UserId != nil {
+			key += "@" + strconv.FormatInt(int64(*resolved.UserId), 10)
+		} else {
+			key += "@guest"
+		}
+	}
+	return key
+}
+
+// cacheKeyPath returns request path of cache key
+func cacheKeyPath(key string) string {
+	key = key[strings.Index(key, ":")+1:]
+	if i := strings.Index(key, "?"); i >= 0 {
+		key = key[:i]
+	}
+	return key
+}
+
+// bodyHash hashes JSON body without formatting and keys order
+func bodyHash(data []byte, contentType string) string {
+	if contentType == "" || contentType == app.ContentTypeJson {
+		var value interface{}
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.UseNumber()
+		if len(bytes.TrimSpace(data)) == 0 {
+			data = []byte("{}")
+		} else if err := decoder.Decode(&value); err == nil {
+			if normalized, err := json.Marshal(value); err == nil {
+				data = normalized
+			}
+		}
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:16])
+}
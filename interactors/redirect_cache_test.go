@@ -0,0 +1,229 @@
+package interactors
+
+import (
+	"context"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+	"microservice/app"
+	"microservice/app/cache"
+	"microservice/app/core"
+	"microservice/domain"
+	pb "microservice/pkg/gateway/api"
+	"microservice/services"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+)
+
+// cacheTestCaller answers AdminService.UpdateInstance (StatusResponse) with current response
+type cacheTestCaller struct {
+	mu       sync.Mutex
+	response []byte
+	calls    int
+}
+
+func (c *cacheTestCaller) Method(_, _, _ string) (*app.ProtoMethod, error) {
+	service, err := app.MakeProtoService(pb.File_api_gateway_admin_proto.Services().ByName("AdminService"))
+	if err != nil {
+		return nil, err
+	}
+	return service.Method("UpdateInstance"), nil
+}
+
+func (c *cacheTestCaller) Call(context.Context, services.ProtoCall) ([]byte, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.calls++
+	return c.response, nil
+}
+
+func (c *cacheTestCaller) respond(response []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.response = response
+}
+
+func (c *cacheTestCaller) callsCount() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.calls
+}
+
+func newCacheUCase(caller instanceCaller, store cache.Store) *RedirectUCase {
+	return &RedirectUCase{
+		log:        app.NewDefaultLogger(logrus.New()),
+		caller:     caller,
+		cacheStore: store,
+	}
+}
+
+func cachedRoute() *domain.RedirectResolveResponse {
+	return &domain.RedirectResolveResponse{Route: &domain.Route{
+		Id:          1,
+		HttpAddress: "/instances",
+		CacheTtl:    60,
+		CacheStale:  60,
+	}}
+}
+
+func TestCacheKey(t *testing.T) {
+	resolved := cachedRoute()
+	key := func(query url.Values, body, accept string, fieldMask ...string) string {
+		return cacheKey(resolved, &domain.RedirectRouteRequest{
+			Address:     "/instances",
+			Query:       query,
+			Data:        []byte(body),
+			ContentType: app.ContentTypeJson,
+			FieldMask:   fieldMask,
+		}, accept)
+	}
+
+	// Order of params and JSON formatting are not parts of key
+	base := key(url.Values{"a": {"1"}, "b": {"2"}}, `{"x":1,"y":[1,2]}`, app.ContentTypeJson)
+	require.Equal(t, base, key(url.Values{"b": {"2"}, "a": {"1"}}, "{ \"y\": [1, 2],\n \"x\": 1 }", app.ContentTypeJson))
+	require.Equal(t, key(nil, "", app.ContentTypeJson), key(nil, "{}", app.ContentTypeJson))
+	require.Equal(t, "/instances", cacheKeyPath(base))
+
+	require.NotEqual(t, base, key(url.Values{"a": {"1"}, "b": {"3"}}, `{"x":1,"y":[1,2]}`, app.ContentTypeJson))
+	require.NotEqual(t, base, key(url.Values{"a": {"1"}, "b": {"2"}}, `{"x":1,"y":[2,1]}`, app.ContentTypeJson))
+	require.NotEqual(t, base, key(url.Values{"a": {"1"}, "b": {"2"}}, `{"x":1,"y":[1,2]}`, app.ContentTypeProtobuf))
+	require.NotEqual(t, base, key(url.Values{"a": {"1"}, "b": {"2"}}, `{"x":1,"y":[1,2]}`, app.ContentTypeJson, "id"))
+}
+
+func TestCacheKeyPerUser(t *testing.T) {
+	req := &domain.RedirectRouteRequest{Address: "/instances"}
+	key := func(perUser bool, userId *int32) string {
+		resolved := cachedRoute()
+		resolved.Route.CachePerUser = perUser
+		resolved.UserId = userId
+		return cacheKey(resolved, req, app.ContentTypeJson)
+	}
+	first, second := int32(1), int32(2)
+
+	require.Equal(t, key(false, &first), key(false, &second))
+	require.NotEqual(t, key(true, &first), key(true, &second))
+	require.NotEqual(t, key(true, &first), key(true, nil))
+	require.Contains(t, key(true, nil), "@guest")
+}
+
+func TestRouteCached(t *testing.T) {
+	ctx := context.Background()
+	store := cache.NewMemoryStore(10)
+	caller := &cacheTestCaller{response: []byte(`{"status":{"code":"success","message":"v1"}}`)}
+	ucase := newCacheUCase(caller, store)
+
+	resolved := cachedRoute()
+	req := &domain.RedirectRouteRequest{Address: "/instances"}
+	options := services.ProtoCall{InputCodec: app.JsonCodec(), OutputCodec: app.JsonCodec()}
+	key := cacheKey(resolved, req, app.ContentTypeJson)
+
+	res, err := ucase.routeCached(ctx, resolved, req, options)
+	require.NoError(t, err)
+	require.Equal(t, domain.CacheMiss, res.CacheStatus)
+	require.Equal(t, "public, max-age=60, stale-while-revalidate=60", res.CacheControl)
+	etag := res.ETag
+
+	res, err = ucase.routeCached(ctx, resolved, req, options)
+	require.NoError(t, err)
+	require.Equal(t, domain.CacheHit, res.CacheStatus)
+	require.Equal(t, etag, res.ETag)
+	require.Equal(t, 1, caller.callsCount())
+
+	// Stale entry is returned at once and refreshed in background
+	entry, err := store.Get(ctx, key)
+	require.NoError(t, err)
+	entry.FreshUntil = time.Now().Add(-time.Second)
+	require.NoError(t, store.Set(ctx, key, entry))
+	caller.respond([]byte(`{"status":{"code":"success","message":"v2"}}`))
+
+	res, err = ucase.routeCached(ctx, resolved, req, options)
+	require.NoError(t, err)
+	require.Equal(t, domain.CacheStale, res.CacheStatus)
+	require.Equal(t, "public, max-age=0, stale-while-revalidate=60", res.CacheControl)
+	require.Contains(t, string(res.Response), "v1")
+
+	require.Eventually(t, func() bool {
+		entry, err := store.Get(ctx, key)
+		return err == nil && entry.IsFresh(time.Now()) && entry.ETag != etag
+	}, 5*time.Second, 10*time.Millisecond)
+	require.Equal(t, 2, caller.callsCount())
+
+	res, err = ucase.routeCached(ctx, resolved, req, options)
+	require.NoError(t, err)
+	require.Equal(t, domain.CacheHit, res.CacheStatus)
+	require.Contains(t, string(res.Response), "v2")
+}
+
+func TestRouteCachedSkipsErrors(t *testing.T) {
+	ctx := context.Background()
+	failed, err := proto.Marshal(&pb.StatusResponse{Status: &pb.Status{Code: core.ServerError}})
+	require.NoError(t, err)
+
+	tests := []struct {
+		name     string
+		codec    app.ProtoCodec
+		response []byte
+	}{
+		{"json", app.JsonCodec(), []byte(`{"status":{"code":"server_error"}}`)},
+		{"protobuf", app.BinaryCodec(), failed},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			store := cache.NewMemoryStore(10)
+			caller := &cacheTestCaller{response: test.response}
+			ucase := newCacheUCase(caller, store)
+			options := services.ProtoCall{InputCodec: test.codec, OutputCodec: test.codec}
+
+			for i := 0; i < 2; i++ {
+				res, err := ucase.routeCached(ctx, cachedRoute(), &domain.RedirectRouteRequest{Address: "/instances"}, options)
+				require.NoError(t, err)
+				require.Empty(t, res.ETag)
+			}
+			require.Equal(t, 2, caller.callsCount())
+		})
+	}
+}
+
+func TestCachedResponseVisibility(t *testing.T) {
+	entry := &cache.Entry{ETag: `"1"`, FreshUntil: time.Now().Add(time.Minute)}
+	visibility := func(route *domain.Route) string {
+		res := cachedResponse(route, entry, domain.CacheHit, time.Now())
+		return res.CacheControl[:len("public")+1]
+	}
+
+	require.Equal(t, "public,", visibility(&domain.Route{}))
+	require.Equal(t, "private", visibility(&domain.Route{CachePerUser: true}))
+	require.Equal(t, "private", visibility(&domain.Route{AccessRole: core.RoleUser}))
+}
+
+func TestPurgeCache(t *testing.T) {
+	ctx := context.Background()
+	store := cache.NewMemoryStore(10)
+	interactor := NewRoutesInteractor(app.NewDefaultLogger(logrus.New()), nil, nil, store, nil)
+
+	fill := func() {
+		for _, item := range []struct {
+			routeId int64
+			address string
+		}{{1, "/users/1"}, {1, "/users/2"}, {2, "/users/3/friends"}, {3, "/teams/1"}} {
+			resolved := &domain.RedirectResolveResponse{Route: &domain.Route{Id: item.routeId}}
+			key := cacheKey(resolved, &domain.RedirectRouteRequest{Address: item.address}, app.ContentTypeJson)
+			require.NoError(t, store.Set(ctx, key, &cache.Entry{StaleUntil: time.Now().Add(time.Minute)}))
+		}
+	}
+	purge := func(routeId int64, prefix string) int {
+		fill()
+		res, err := interactor.PurgeCache(ctx, &domain.RoutesPurgeCacheRequest{RouteId: routeId, Prefix: prefix})
+		require.NoError(t, err)
+		require.Equal(t, core.Success, res.Status.Code)
+		return res.Purged
+	}
+
+	require.Equal(t, 2, purge(1, ""))
+	require.Equal(t, 3, purge(0, "/users/"))
+	require.Equal(t, 1, purge(1, "/users/2"))
+	require.Equal(t, 0, purge(3, "/users/"))
+	require.Equal(t, 4, purge(0, ""))
+}
@@ -0,0 +1,346 @@
+package interactors
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/pkg/errors"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"microservice/app"
+	"microservice/app/core"
+	"microservice/domain"
+	"microservice/services"
+	"microservice/tools"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+// compositeRequest is a source name of client`s request in RouteCall.Params
+const compositeRequest = "request"
+
+// instanceCaller finds and calls methods of routes (ProtoCallerService)
+type instanceCaller interface {
+	Method(instance, service, method string) (*app.ProtoMethod, error)
+	Call(ctx context.Context, call services.ProtoCall) ([]byte, error)
+}
+
+// compositeErrorCode is gRPC code name of failed call, instance`s error details are only logged
+func compositeErrorCode(err error) string {
+	if errors.Is(err, services.ErrInstanceUnavailable) {
+		return codes.Unavailable.String()
+	}
+	if st, ok := status.FromError(errors.Cause(err)); ok {
+		return st.Code().String()
+	}
+	return codes.Unknown.String()
+}
+
+// compositeResult is result of one call of composite route
+type compositeResult struct {
+	done  chan struct{}
+	data  json.RawMessage
+	value interface{}
+	err   error
+}
+
+// callComposite calls route`s methods (in parallel if they don`t depend on each other)
+// and merges their JSON results under calls` names
+func (ucase *RedirectUCase) callComposite(ctx context.Context, route *domain.Route, callOptions services.ProtoCall) (*domain.RedirectRouteResponse, bool, error) {
+	if callOptions.InputCodec.ContentType() != app.ContentTypeJson {
+		return &domain.RedirectRouteResponse{
+			Status: core.Status{
+				Code:    core.ValidationError,
+				Message: "composite route accepts only JSON",
+			},
+		}, false, nil
+	}
+
+	// Client`s body, calls take their requests by names
+	body := map[string]json.RawMessage{}
+	if len(bytes.TrimSpace(callOptions.Data)) > 0 {
+		if err := json.Unmarshal(callOptions.Data, &body); err != nil {
+			return &domain.RedirectRouteResponse{
+				Status: core.Status{
+					Code:    core.ValidationError,
+					Message: "request should be JSON object",
+				},
+			}, false, nil
+		}
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	results := make(map[string]*compositeResult, len(route.Calls))
+	for _, call := range route.Calls {
+		results[call.Name] = &compositeResult{done: make(chan struct{})}
+	}
+
+	// First failed required call cancels others
+	var failOnce sync.Once
+	var failed error
+
+	var wg sync.WaitGroup
+	for _, call := range route.Calls {
+		wg.Add(1)
+		go func(call *domain.RouteCall) {
+			defer wg.Done()
+			res := results[call.Name]
+			defer close(res.done)
+
+			res.err = ucase.compositeCall(ctx, call, callOptions, body, results)
+			if res.err != nil && !call.Optional {
+				failOnce.Do(func() {
+					failed = errors.Wrapf(res.err, "composite call %s failed", call.Name)
+					cancel()
+				})
+			}
+		}(call)
+	}
+	wg.Wait()
+
+	if failed != nil {
+		if res := ucase.errorResponse(ctx, route, failed); res != nil {
+			return res, false, nil
+		}
+		return nil, false, failed
+	}
+
+	// Merge
+	response := map[string]interface{}{
+		"status": core.Status{Code: core.Success},
+	}
+	callErrors := map[string]string{}
+	for name, res := range results {
+		if res.err != nil {
+			ucase.log.WithContext(ctx).WarnWrap(res.err, "optional composite call %s failed (route=%d)", name, route.Id)
+			callErrors[name] = compositeErrorCode(res.err)
+			response[name] = nil
+			continue
+		}
+		response[name] = res.data
+	}
+	if len(callErrors) > 0 {
+		response["errors"] = callErrors
+	}
+	if len(callOptions.FieldMask) > 0 {
+		// Calls` results are JSON, so mask is applied to merged response
+		mask := tools.NewFieldMask(append([]string{"status", "errors"}, callOptions.FieldMask...)...)
+		data, err := json.Marshal(response)
+		if err != nil {
+			return nil, false, errors.Wrap(err, "cannot marshal composite response")
+		}
+		response = map[string]interface{}{}
+		if err := decodeJson(data, &response); err != nil {
+			return nil, false, errors.Wrap(err, "cannot parse composite response")
+		}
+		response = mask.Filter(response)
+	}
+
+	data, err := json.Marshal(response)
+	if err != nil {
+		return nil, false, errors.Wrap(err, "cannot marshal composite response")
+	}
+	return &domain.RedirectRouteResponse{
+		Response: data,
+		Status: core.Status{
+			Code: core.Success,
+		},
+		ContentType: app.ContentTypeJson,
+	}, len(callErrors) == 0, nil
+}
+
+// compositeCall waits for dependencies, maps params and calls method
+func (ucase *RedirectUCase) compositeCall(ctx context.Context, call *domain.RouteCall, callOptions services.ProtoCall,
+	body map[string]json.RawMessage, results map[string]*compositeResult) error {
+
+	for _, dep := range compositeDeps(call) {
+		depRes := results[dep]
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-depRes.done:
+		}
+		if depRes.err != nil {
+			return errors.Errorf("dependency %s failed", dep)
+		}
+	}
+
+	params := url.Values{}
+	for target, source := range call.Params {
+		values, err := compositeParam(source, callOptions, body, results)
+		if err != nil {
+			return errors.Wrapf(err, "cannot map param %s", target)
+		}
+		params[target] = values
+	}
+
+	data := []byte("{}")
+	if raw, ok := body[call.Name]; ok {
+		data = raw
+	}
+
+	headers := make(map[string]string, len(callOptions.Headers))
+	for k, v := range callOptions.Headers {
+		headers[k] = v
+	}
+
+	response, err := ucase.caller.Call(ctx, services.ProtoCall{
+		Instance:        call.Instance,
+		Service:         call.Service,
+		Method:          call.Method,
+		Data:            data,
+		Headers:         headers,
+		MaxResponseSize: callOptions.MaxResponseSize,
+		InputCodec:      callOptions.InputCodec,
+		OutputCodec:     callOptions.OutputCodec,
+		Params:          params,
+		Coalesce:        callOptions.Coalesce,
+	})
+	if err != nil {
+		return err
+	}
+
+	res := results[call.Name]
+	res.data = response
+	if err := decodeJson(response, &res.value); err != nil {
+		return errors.Wrap(err, "cannot parse response")
+	}
+	return nil
+}
+
+// compositeParam finds values of "request.<path>" or "<call>.<path>"
+func compositeParam(source string, callOptions services.ProtoCall,
+	body map[string]json.RawMessage, results map[string]*compositeResult) ([]string, error) {
+
+	name, path, _ := strings.Cut(source, ".")
+	var value interface{}
+	if name == compositeRequest {
+		// Query and path params, then body
+		if values, ok := callOptions.Params[path]; ok {
+			return values, nil
+		}
+		var bodyValue interface{}
+		raw, _ := json.Marshal(body)
+		if err := decodeJson(raw, &bodyValue); err != nil {
+			return nil, err
+		}
+		value = bodyValue
+	} else {
+		value = results[name].value
+	}
+
+	found, ok := jsonLookup(value, path)
+	if !ok || found == nil {
+		return nil, errors.Errorf("%s is not found", source)
+	}
+
+	switch found := found.(type) {
+	case []interface{}:
+		var values []string
+		for _, item := range found {
+			values = append(values, fmt.Sprint(item))
+		}
+		return values, nil
+	case map[string]interface{}:
+		return nil, errors.Errorf("%s is an object", source)
+	default:
+		return []string{fmt.Sprint(found)}, nil
+	}
+}
+
+// jsonLookup finds value by dotted path (empty path - value itself)
+func jsonLookup(value interface{}, path string) (interface{}, bool) {
+	if path == "" {
+		return value, true
+	}
+	for _, part := range strings.Split(path, ".") {
+		obj, ok := value.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		if value, ok = obj[part]; !ok {
+			return nil, false
+		}
+	}
+	return value, true
+}
+
+// compositeDeps are explicit dependencies and calls used in params
+func compositeDeps(call *domain.RouteCall) []string {
+	seen := map[string]bool{}
+	var deps []string
+	add := func(name string) {
+		if name != compositeRequest && !seen[name] {
+			seen[name] = true
+			deps = append(deps, name)
+		}
+	}
+	for _, dep := range call.DependsOn {
+		add(dep)
+	}
+	for _, source := range call.Params {
+		name, _, _ := strings.Cut(source, ".")
+		add(name)
+	}
+	return deps
+}
+
+// validateCompositeCalls checks names, methods and that dependencies have no cycles
+func validateCompositeCalls(caller instanceCaller, calls []*domain.RouteCall) error {
+	reserved := map[string]bool{"status": true, "errors": true, compositeRequest: true}
+	byName := map[string]*domain.RouteCall{}
+	for _, call := range calls {
+		if call.Name == "" || reserved[call.Name] {
+			return errors.Errorf("call name %q is not allowed", call.Name)
+		}
+		if _, ok := byName[call.Name]; ok {
+			return errors.Errorf("call name %s is not unique", call.Name)
+		}
+		byName[call.Name] = call
+
+		method, err := caller.Method(call.Instance, call.Service, call.Method)
+		if err != nil {
+			return errors.Wrapf(err, "call %s", call.Name)
+		}
+		if method.IsServerStreaming() || method.IsClientStreaming() {
+			return errors.Errorf("call %s: streaming methods are not supported", call.Name)
+		}
+	}
+
+	// Dependencies are known and have no cycles
+	const (
+		visiting = 1
+		visited  = 2
+	)
+	state := map[string]int{}
+	var visit func(name string) error
+	visit = func(name string) error {
+		switch state[name] {
+		case visiting:
+			return errors.Errorf("calls have cyclic dependency (%s)", name)
+		case visited:
+			return nil
+		}
+		state[name] = visiting
+		for _, dep := range compositeDeps(byName[name]) {
+			if _, ok := byName[dep]; !ok {
+				return errors.Errorf("call %s depends on unknown call %s", name, dep)
+			}
+			if err := visit(dep); err != nil {
+				return err
+			}
+		}
+		state[name] = visited
+		return nil
+	}
+	for _, call := range calls {
+		if err := visit(call.Name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
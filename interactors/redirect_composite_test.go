@@ -0,0 +1,302 @@
+package interactors
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+	"microservice/app"
+	"microservice/app/core"
+	"microservice/domain"
+	"microservice/services"
+	"net/url"
+	"sync"
+	"testing"
+)
+
+// testCaller answers composite calls by method name and records them
+type testCaller struct {
+	responses map[string]func(call services.ProtoCall) ([]byte, error)
+
+	mu    sync.Mutex
+	order []string
+	calls map[string]services.ProtoCall
+}
+
+func newTestCaller(responses map[string]func(call services.ProtoCall) ([]byte, error)) *testCaller {
+	return &testCaller{responses: responses, calls: map[string]services.ProtoCall{}}
+}
+
+// Method returns unary Health.Check or streaming Health.Watch
+func (c *testCaller) Method(_, _, method string) (*app.ProtoMethod, error) {
+	service, err := app.MakeProtoService(grpc_health_v1.File_grpc_health_v1_health_proto.Services().ByName("Health"))
+	if err != nil {
+		return nil, err
+	}
+	if method == "Watch" {
+		return service.Method("Watch"), nil
+	}
+	return service.Method("Check"), nil
+}
+
+func (c *testCaller) Call(_ context.Context, call services.ProtoCall) ([]byte, error) {
+	c.mu.Lock()
+	c.order = append(c.order, call.Method)
+	c.calls[call.Method] = call
+	c.mu.Unlock()
+	return c.responses[call.Method](call)
+}
+
+func (c *testCaller) index(method string) int {
+	for i, m := range c.order {
+		if m == method {
+			return i
+		}
+	}
+	return -1
+}
+
+func respond(data string) func(services.ProtoCall) ([]byte, error) {
+	return func(services.ProtoCall) ([]byte, error) {
+		return []byte(data), nil
+	}
+}
+
+func newCompositeUCase(caller *testCaller) *RedirectUCase {
+	return &RedirectUCase{
+		log:    app.NewDefaultLogger(logrus.New()),
+		caller: caller,
+	}
+}
+
+func compositeCallOptions(body string, params url.Values) services.ProtoCall {
+	return services.ProtoCall{
+		Data:        []byte(body),
+		Params:      params,
+		InputCodec:  app.JsonCodec(),
+		OutputCodec: app.JsonCodec(),
+	}
+}
+
+func TestCompositeDependencyChain(t *testing.T) {
+	caller := newTestCaller(map[string]func(services.ProtoCall) ([]byte, error){
+		"GetUser": respond(`{"user":{"id":7,"team":"red"}}`),
+		"GetTeam": func(call services.ProtoCall) ([]byte, error) {
+			if call.Params.Get("name") != "red" {
+				return nil, errors.Errorf("unexpected team %q", call.Params.Get("name"))
+			}
+			return []byte(`{"team":{"size":3}}`), nil
+		},
+		"GetStats": respond(`{"games":10}`),
+	})
+	route := &domain.Route{Calls: []*domain.RouteCall{
+		{Name: "stats", Method: "GetStats", DependsOn: []string{"team"}},
+		{Name: "team", Method: "GetTeam", Params: map[string]string{"name": "user.user.team"}},
+		{Name: "user", Method: "GetUser", Params: map[string]string{"id": "request.id"}},
+	}}
+
+	res, ok, err := newCompositeUCase(caller).callComposite(context.Background(), route,
+		compositeCallOptions(`{"user":{"extra":true}}`, url.Values{"id": {"7"}}))
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.Equal(t, core.Success, res.Status.Code)
+	require.Less(t, caller.index("GetUser"), caller.index("GetTeam"), "calls are not ordered by dependencies")
+	require.Less(t, caller.index("GetTeam"), caller.index("GetStats"), "calls are not ordered by dependencies")
+	require.Equal(t, "7", caller.calls["GetUser"].Params.Get("id"))
+	require.Equal(t, `{"extra":true}`, string(caller.calls["GetUser"].Data), "call should get its part of body")
+
+	var response map[string]json.RawMessage
+	require.NoError(t, json.Unmarshal(res.Response, &response))
+	for name, expected := range map[string]string{
+		"user":  `{"user":{"id":7,"team":"red"}}`,
+		"team":  `{"team":{"size":3}}`,
+		"stats": `{"games":10}`,
+	} {
+		require.Equal(t, expected, string(response[name]), name)
+	}
+	require.NotContains(t, response, "errors")
+}
+
+func TestCompositeParams(t *testing.T) {
+	tests := []struct {
+		name     string
+		body     string
+		params   url.Values
+		source   string
+		expected []string
+		err      bool
+	}{
+		{"query", `{}`, url.Values{"id": {"1", "2"}}, "request.id", []string{"1", "2"}, false},
+		{"body", `{"filter":{"ids":[3,4]}}`, nil, "request.filter.ids", []string{"3", "4"}, false},
+		{"result", `{}`, nil, "prev.user.name", []string{"bob"}, false},
+		{"result number", `{}`, nil, "prev.user.age", []string{"42"}, false},
+		{"object", `{}`, nil, "prev.user", nil, true},
+		{"missing", `{}`, nil, "prev.user.email", nil, true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			caller := newTestCaller(map[string]func(services.ProtoCall) ([]byte, error){
+				"Prev": respond(`{"user":{"name":"bob","age":42}}`),
+				"Next": respond(`{}`),
+			})
+			route := &domain.Route{Calls: []*domain.RouteCall{
+				{Name: "prev", Method: "Prev"},
+				{Name: "next", Method: "Next", Params: map[string]string{"value": test.source}},
+			}}
+
+			res, _, err := newCompositeUCase(caller).callComposite(context.Background(), route,
+				compositeCallOptions(test.body, test.params))
+			if test.err {
+				require.False(t, err == nil && res.Status.Code == core.Success, "expected mapping error")
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, test.expected, caller.calls["Next"].Params["value"])
+		})
+	}
+}
+
+func TestCompositeFailures(t *testing.T) {
+	failed := func(services.ProtoCall) ([]byte, error) {
+		return nil, errors.New("instance failed")
+	}
+	unavailable := func(services.ProtoCall) ([]byte, error) {
+		return nil, errors.Wrap(services.ErrInstanceUnavailable, "cannot call")
+	}
+
+	t.Run("optional call", func(t *testing.T) {
+		caller := newTestCaller(map[string]func(services.ProtoCall) ([]byte, error){
+			"Main":  respond(`{"id":1}`),
+			"Extra": failed,
+		})
+		route := &domain.Route{Calls: []*domain.RouteCall{
+			{Name: "main", Method: "Main"},
+			{Name: "extra", Method: "Extra", Optional: true},
+		}}
+		res, ok, err := newCompositeUCase(caller).callComposite(context.Background(), route, compositeCallOptions("", nil))
+		require.NoError(t, err)
+		require.False(t, ok, "response with failed optional call should not be cached")
+		var response struct {
+			Status core.Status
+			Main   json.RawMessage
+			Extra  json.RawMessage
+			Errors map[string]string
+		}
+		require.NoError(t, json.Unmarshal(res.Response, &response))
+		require.Equal(t, core.Success, response.Status.Code)
+		require.Equal(t, `{"id":1}`, string(response.Main))
+		require.Equal(t, "null", string(response.Extra))
+		require.Equal(t, "Unknown", response.Errors["extra"], "optional call error is not reported")
+	})
+
+	t.Run("optional call error details", func(t *testing.T) {
+		caller := newTestCaller(map[string]func(services.ProtoCall) ([]byte, error){
+			"Main": respond(`{"id":1}`),
+			"Extra": func(services.ProtoCall) ([]byte, error) {
+				return nil, errors.Wrap(status.Error(codes.NotFound, "row 42 in table users_private"), "cannot call")
+			},
+			"Other": unavailable,
+		})
+		route := &domain.Route{Calls: []*domain.RouteCall{
+			{Name: "main", Method: "Main"},
+			{Name: "extra", Method: "Extra", Optional: true},
+			{Name: "other", Method: "Other", Optional: true},
+		}}
+		res, _, err := newCompositeUCase(caller).callComposite(context.Background(), route, compositeCallOptions("", nil))
+		require.NoError(t, err)
+		var response struct {
+			Errors map[string]string
+		}
+		require.NoError(t, json.Unmarshal(res.Response, &response))
+		require.Equal(t, map[string]string{"extra": "NotFound", "other": "Unavailable"}, response.Errors)
+		require.NotContains(t, string(res.Response), "users_private", "instance`s error is sent to client")
+	})
+
+	t.Run("dependency of failed optional call", func(t *testing.T) {
+		caller := newTestCaller(map[string]func(services.ProtoCall) ([]byte, error){
+			"Extra": failed,
+			"Next":  respond(`{}`),
+		})
+		route := &domain.Route{Calls: []*domain.RouteCall{
+			{Name: "extra", Method: "Extra", Optional: true},
+			{Name: "next", Method: "Next", Optional: true, DependsOn: []string{"extra"}},
+		}}
+		_, _, err := newCompositeUCase(caller).callComposite(context.Background(), route, compositeCallOptions("", nil))
+		require.NoError(t, err)
+		require.Equal(t, -1, caller.index("Next"), "call should not run after failed dependency")
+	})
+
+	t.Run("required call", func(t *testing.T) {
+		caller := newTestCaller(map[string]func(services.ProtoCall) ([]byte, error){
+			"Main":  failed,
+			"Extra": respond(`{}`),
+		})
+		route := &domain.Route{Calls: []*domain.RouteCall{
+			{Name: "main", Method: "Main"},
+			{Name: "extra", Method: "Extra", Optional: true},
+		}}
+		_, _, err := newCompositeUCase(caller).callComposite(context.Background(), route, compositeCallOptions("", nil))
+		require.ErrorContains(t, err, "composite call main failed")
+	})
+
+	t.Run("required call of unavailable instance", func(t *testing.T) {
+		caller := newTestCaller(map[string]func(services.ProtoCall) ([]byte, error){
+			"Main": unavailable,
+		})
+		route := &domain.Route{Instance: "users", Calls: []*domain.RouteCall{
+			{Name: "main", Method: "Main"},
+		}}
+		res, _, err := newCompositeUCase(caller).callComposite(context.Background(), route, compositeCallOptions("", nil))
+		require.NoError(t, err)
+		require.Equal(t, core.Unavailable, res.Status.Code)
+	})
+}
+
+func TestValidateCompositeCalls(t *testing.T) {
+	tests := []struct {
+		name  string
+		calls []*domain.RouteCall
+		err   string
+	}{
+		{"chain", []*domain.RouteCall{
+			{Name: "a"},
+			{Name: "b", Params: map[string]string{"id": "a.id"}},
+			{Name: "c", DependsOn: []string{"b"}, Params: map[string]string{"id": "request.id"}},
+		}, ""},
+		{"cycle", []*domain.RouteCall{
+			{Name: "a", DependsOn: []string{"c"}},
+			{Name: "b", Params: map[string]string{"id": "a.id"}},
+			{Name: "c", DependsOn: []string{"b"}},
+		}, "cyclic dependency"},
+		{"self", []*domain.RouteCall{
+			{Name: "a", Params: map[string]string{"id": "a.id"}},
+		}, "cyclic dependency"},
+		{"unknown dependency", []*domain.RouteCall{
+			{Name: "a", DependsOn: []string{"b"}},
+		}, "unknown call b"},
+		{"duplicate", []*domain.RouteCall{
+			{Name: "a"},
+			{Name: "a"},
+		}, "not unique"},
+		{"reserved", []*domain.RouteCall{
+			{Name: "status"},
+		}, "not allowed"},
+		{"streaming", []*domain.RouteCall{
+			{Name: "a", Method: "Watch"},
+		}, "streaming methods are not supported"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := validateCompositeCalls(newTestCaller(nil), test.calls)
+			if test.err == "" {
+				require.NoError(t, err)
+				return
+			}
+			require.ErrorContains(t, err, test.err)
+		})
+	}
+}
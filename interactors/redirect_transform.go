@@ -0,0 +1,229 @@
+package interactors
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/pkg/errors"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/dynamicpb"
+	"microservice/app"
+	"microservice/app/core"
+	"microservice/domain"
+	"microservice/tools"
+	"sort"
+	"strings"
+)
+
+// transformRequest renames, drops and fills fields of client`s JSON
+func transformRequest(transform *domain.RouteTransform, data []byte) ([]byte, error) {
+	if transform == nil || (len(transform.Rename) == 0 && len(transform.Drop) == 0 && len(transform.Defaults) == 0) {
+		return data, nil
+	}
+
+	obj := map[string]interface{}{}
+	if len(bytes.TrimSpace(data)) > 0 {
+		if err := decodeJson(data, &obj); err != nil {
+			return nil, errors.New("request should be JSON object")
+		}
+	}
+
+	// Renames are applied at once: values are taken before any path is changed
+	froms := renameSources(transform.Rename)
+	values := make(map[string]interface{}, len(froms))
+	for _, from := range froms {
+		if v, ok := jsonLookup(obj, from); ok {
+			values[from] = v
+		}
+	}
+	for _, from := range froms {
+		if _, ok := values[from]; ok {
+			jsonDelete(obj, from)
+		}
+	}
+	for _, from := range froms {
+		if v, ok := values[from]; ok {
+			jsonSet(obj, transform.Rename[from], v)
+		}
+	}
+	for _, path := range transform.Drop {
+		jsonDelete(obj, path)
+	}
+	for path, raw := range transform.Defaults {
+		if v, ok := jsonLookup(obj, path); ok && v != nil {
+			continue
+		}
+		var v interface{}
+		if err := decodeJson(raw, &v); err != nil {
+			return nil, errors.Wrapf(err, "invalid default of %s", path)
+		}
+		jsonSet(obj, path, v)
+	}
+	return json.Marshal(obj)
+}
+
+// transformResponse filters instance`s JSON and wraps or unwraps status
+func transformResponse(transform *domain.RouteTransform, data []byte) ([]byte, error) {
+	if transform == nil || (len(transform.Fields) == 0 && transform.Envelope == "") {
+		return data, nil
+	}
+
+	obj := map[string]interface{}{}
+	if err := decodeJson(data, &obj); err != nil {
+		return data, nil // not an object, nothing to transform
+	}
+
+	if len(transform.Fields) > 0 {
+		mask := tools.NewFieldMask(append([]string{"status"}, transform.Fields...)...)
+		obj = mask.Filter(obj)
+	}
+
+	switch transform.Envelope {
+	case domain.EnvelopeWrap:
+		status, ok := obj["status"]
+		if !ok {
+			status = core.Status{Code: core.Success}
+		}
+		delete(obj, "status")
+		obj = map[string]interface{}{
+			"status": status,
+			"data":   obj,
+		}
+	case domain.EnvelopeUnwrap:
+		delete(obj, "status")
+	}
+	return json.Marshal(obj)
+}
+
+// validateTransform checks transform paths against method`s request and response
+func validateTransform(method *app.ProtoMethod, transform *domain.RouteTransform) error {
+	if transform == nil {
+		return nil
+	}
+
+	switch transform.Envelope {
+	case "", domain.EnvelopeWrap, domain.EnvelopeUnwrap:
+	default:
+		return errors.Errorf("unknown envelope %s", transform.Envelope)
+	}
+
+	froms := renameSources(transform.Rename)
+	for i, from := range froms {
+		to := transform.Rename[from]
+		if from == "" {
+			return errors.New("rename: empty path")
+		}
+		if _, err := descriptorField(method.Request(), to); err != nil {
+			return errors.Wrapf(err, "rename %s", from)
+		}
+
+		// Chained or overlapping renames (a -> b, b -> c) are ambiguous
+		for _, other := range froms[i+1:] {
+			otherTo := transform.Rename[other]
+			if pathsOverlap(from, other) || pathsOverlap(to, otherTo) || pathsOverlap(to, other) || pathsOverlap(otherTo, from) {
+				return errors.Errorf("rename: %s -> %s overlaps %s -> %s", from, to, other, otherTo)
+			}
+		}
+	}
+	for _, path := range transform.Drop {
+		if _, err := descriptorField(method.Request(), path); err != nil {
+			return errors.Wrap(err, "drop")
+		}
+	}
+	for path, raw := range transform.Defaults {
+		if _, err := descriptorField(method.Request(), path); err != nil {
+			return errors.Wrap(err, "defaults")
+		}
+
+		// Default should be valid value of the field
+		var v interface{}
+		if err := decodeJson(raw, &v); err != nil {
+			return errors.Wrapf(err, "default of %s is not JSON", path)
+		}
+		obj := map[string]interface{}{}
+		jsonSet(obj, path, v)
+		data, err := json.Marshal(obj)
+		if err != nil {
+			return errors.Wrapf(err, "default of %s", path)
+		}
+		if err := protojson.Unmarshal(data, dynamicpb.NewMessage(method.Request())); err != nil {
+			return errors.Wrapf(err, "default of %s", path)
+		}
+	}
+	for _, path := range transform.Fields {
+		if _, err := descriptorField(method.Response(), path); err != nil {
+			return errors.Wrap(err, "fields")
+		}
+	}
+	return nil
+}
+
+// descriptorField finds field by dotted path of JSON or proto names
+func descriptorField(md protoreflect.MessageDescriptor, path string) (protoreflect.FieldDescriptor, error) {
+	var fd protoreflect.FieldDescriptor
+	for _, name := range strings.Split(path, ".") {
+		if md == nil {
+			return nil, errors.Errorf("%s: %s is not a message", path, fd.Name())
+		}
+		fd = md.Fields().ByJSONName(name)
+		if fd == nil {
+			fd = md.Fields().ByName(protoreflect.Name(name))
+		}
+		if fd == nil {
+			return nil, errors.Errorf("%s: field %s is not found in %s", path, name, md.FullName())
+		}
+		md = nil
+		if fd.Message() != nil && !fd.IsMap() {
+			md = fd.Message()
+		}
+	}
+	return fd, nil
+}
+
+// renameSources are sorted source paths of renames
+func renameSources(rename map[string]string) []string {
+	froms := make([]string, 0, len(rename))
+	for from := range rename {
+		froms = append(froms, from)
+	}
+	sort.Strings(froms)
+	return froms
+}
+
+// pathsOverlap is true for equal paths or if one path is inside another
+func pathsOverlap(a, b string) bool {
+	return a == b || strings.HasPrefix(a, b+".") || strings.HasPrefix(b, a+".")
+}
+
+func decodeJson(data []byte, v interface{}) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	return decoder.Decode(v)
+}
+
+// jsonSet sets value by dotted path, missing objects are created
+func jsonSet(obj map[string]interface{}, path string, value interface{}) {
+	parts := strings.Split(path, ".")
+	for _, part := range parts[:len(parts)-1] {
+		next, ok := obj[part].(map[string]interface{})
+		if !ok {
+			next = map[string]interface{}{}
+			obj[part] = next
+		}
+		obj = next
+	}
+	obj[parts[len(parts)-1]] = value
+}
+
+// jsonDelete removes value by dotted path
+func jsonDelete(obj map[string]interface{}, path string) {
+	parts := strings.Split(path, ".")
+	for _, part := range parts[:len(parts)-1] {
+		next, ok := obj[part].(map[string]interface{})
+		if !ok {
+			return
+		}
+		obj = next
+	}
+	delete(obj, parts[len(parts)-1])
+}
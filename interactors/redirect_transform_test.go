@@ -0,0 +1,155 @@
+package interactors
+
+import (
+	"encoding/json"
+	"github.com/stretchr/testify/require"
+	"microservice/app"
+	"microservice/domain"
+	pb "microservice/pkg/gateway/api"
+	"testing"
+)
+
+func TestTransformRequest(t *testing.T) {
+	tests := []struct {
+		name      string
+		transform *domain.RouteTransform
+		in        string
+		out       string
+		err       bool
+	}{
+		{"nil", nil, `{"a":1}`, `{"a":1}`, false},
+		{"rename", &domain.RouteTransform{
+			Rename: map[string]string{"dir": "folder", "check.kind": "health_check.protocol"},
+		}, `{"dir":"x","check":{"kind":"grpc","other":1}}`, `{"check":{"other":1},"folder":"x","health_check":{"protocol":"grpc"}}`, false},
+		{"rename of absent path", &domain.RouteTransform{
+			Rename: map[string]string{"dir": "folder"},
+		}, `{"id":1}`, `{"id":1}`, false},
+		{"swap is applied at once", &domain.RouteTransform{
+			Rename: map[string]string{"a": "b", "b": "a"},
+		}, `{"a":1,"b":2}`, `{"a":2,"b":1}`, false},
+		{"drop", &domain.RouteTransform{
+			Drop: []string{"endpoint", "health_check.services", "unknown.path"},
+		}, `{"endpoint":"e","health_check":{"services":["a"],"watch":true}}`, `{"health_check":{"watch":true}}`, false},
+		{"defaults", &domain.RouteTransform{
+			Defaults: map[string]json.RawMessage{
+				"is_active":             json.RawMessage(`true`),
+				"folder":                json.RawMessage(`"default"`),
+				"health_check.protocol": json.RawMessage(`"ping"`),
+			},
+		}, `{"folder":"x","is_active":null}`, `{"folder":"x","health_check":{"protocol":"ping"},"is_active":true}`, false},
+		{"empty body", &domain.RouteTransform{
+			Defaults: map[string]json.RawMessage{"id": json.RawMessage(`1`)},
+		}, ``, `{"id":1}`, false},
+		{"not an object", &domain.RouteTransform{
+			Drop: []string{"id"},
+		}, `[1]`, ``, true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			out, err := transformRequest(test.transform, []byte(test.in))
+			if test.err {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, test.out, string(out))
+		})
+	}
+}
+
+func TestTransformResponse(t *testing.T) {
+	tests := []struct {
+		name      string
+		transform *domain.RouteTransform
+		in        string
+		out       string
+	}{
+		{"fields", &domain.RouteTransform{
+			Fields: []string{"user.name"},
+		}, `{"status":{"code":"success"},"user":{"name":"bob","age":3},"extra":1}`, `{"status":{"code":"success"},"user":{"name":"bob"}}`},
+		{"wrap", &domain.RouteTransform{
+			Envelope: domain.EnvelopeWrap,
+		}, `{"status":{"code":"success"},"id":1}`, `{"data":{"id":1},"status":{"code":"success"}}`},
+		{"wrap without status", &domain.RouteTransform{
+			Envelope: domain.EnvelopeWrap,
+		}, `{"id":1}`, `{"data":{"id":1},"status":{"code":"success","message":""}}`},
+		{"unwrap", &domain.RouteTransform{
+			Envelope: domain.EnvelopeUnwrap,
+		}, `{"status":{"code":"success"},"id":1}`, `{"id":1}`},
+		{"not an object", &domain.RouteTransform{
+			Envelope: domain.EnvelopeUnwrap,
+		}, `[1]`, `[1]`},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			out, err := transformResponse(test.transform, []byte(test.in))
+			require.NoError(t, err)
+			require.Equal(t, test.out, string(out))
+		})
+	}
+}
+
+func TestValidateTransform(t *testing.T) {
+	service, err := app.MakeProtoService(pb.File_api_gateway_admin_proto.Services().ByName("AdminService"))
+	require.NoError(t, err)
+	// UpdateInstanceRequest -> StatusResponse
+	method := service.Method("UpdateInstance")
+
+	tests := []struct {
+		name      string
+		transform *domain.RouteTransform
+		err       string
+	}{
+		{"valid", &domain.RouteTransform{
+			Rename:   map[string]string{"dir": "folder", "kind": "healthCheck.protocol"},
+			Drop:     []string{"endpoint"},
+			Defaults: map[string]json.RawMessage{"is_active": json.RawMessage(`true`)},
+			Fields:   []string{"status.code"},
+			Envelope: domain.EnvelopeWrap,
+		}, ""},
+		{"envelope", &domain.RouteTransform{Envelope: "box"}, "unknown envelope"},
+		{"empty rename", &domain.RouteTransform{
+			Rename: map[string]string{"": "folder"},
+		}, "empty path"},
+		{"unknown rename target", &domain.RouteTransform{
+			Rename: map[string]string{"dir": "directory"},
+		}, "field directory is not found"},
+		{"chained renames", &domain.RouteTransform{
+			Rename: map[string]string{"dir": "folder", "folder": "endpoint"},
+		}, "overlaps"},
+		{"same target", &domain.RouteTransform{
+			Rename: map[string]string{"a": "folder", "b": "folder"},
+		}, "overlaps"},
+		{"nested targets", &domain.RouteTransform{
+			Rename: map[string]string{"a": "health_check", "b": "health_check.protocol"},
+		}, "overlaps"},
+		{"nested sources", &domain.RouteTransform{
+			Rename: map[string]string{"a": "folder", "a.b": "endpoint"},
+		}, "overlaps"},
+		{"unknown drop", &domain.RouteTransform{
+			Drop: []string{"health_check.unknown"},
+		}, "drop"},
+		{"default is not JSON", &domain.RouteTransform{
+			Defaults: map[string]json.RawMessage{"folder": json.RawMessage(`{`)},
+		}, "is not JSON"},
+		{"default of wrong type", &domain.RouteTransform{
+			Defaults: map[string]json.RawMessage{"id": json.RawMessage(`"x"`)},
+		}, "default of id"},
+		{"unknown response field", &domain.RouteTransform{
+			Fields: []string{"status.unknown"},
+		}, "fields"},
+		{"field inside scalar", &domain.RouteTransform{
+			Fields: []string{"status.code.value"},
+		}, "is not a message"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := validateTransform(method, test.transform)
+			if test.err == "" {
+				require.NoError(t, err)
+				return
+			}
+			require.ErrorContains(t, err, test.err)
+		})
+	}
+}
@@ -3,10 +3,17 @@ package interactors
 import (
 	"context"
 	"github.com/pkg/errors"
+	"github.com/spf13/viper"
+	"microservice/app"
+	"microservice/app/accesslog"
+	"microservice/app/cache"
 	"microservice/app/core"
 	"microservice/domain"
 	"microservice/services"
+	"net/url"
 	"strconv"
+	"strings"
+	"sync"
 )
 
 type RedirectUCase struct {
@@ -14,83 +21,196 @@ type RedirectUCase struct {
 	routesRepo    domain.RoutesRepository
 	authService   *services.AuthService
 	callerService *services.ProtoCallerService
+	cacheStore    cache.Store
+	templates     *services.RouteTemplates
+
+	// Calls of routes (callerService)
+	caller instanceCaller
+
+	// Keys of stale cache entries which are being refreshed
+	refreshing sync.Map
 }
 
 func NewRedirectUCase(log core.Logger,
 	routesRepo domain.RoutesRepository,
 	authService *services.AuthService,
-	callerService *services.ProtoCallerService) *RedirectUCase {
+	callerService *services.ProtoCallerService,
+	cacheStore cache.Store,
+	templates *services.RouteTemplates) *RedirectUCase {
 	return &RedirectUCase{
 		log:           log,
 		routesRepo:    routesRepo,
 		authService:   authService,
 		callerService: callerService,
+		cacheStore:    cacheStore,
+		templates:     templates,
+
+		caller: callerService,
 	}
 }
 
-// Route Перенаправляет входящий REST запрос на микросервис
-func (ucase *RedirectUCase) Route(ctx context.Context, req *domain.RedirectRouteRequest) (*domain.RedirectRouteResponse, error) {
+// Resolve находит маршрут и проверяет доступ к нему
+func (ucase *RedirectUCase) Resolve(ctx context.Context, req *domain.RedirectResolveRequest) (*domain.RedirectResolveResponse, error) {
 	if req == nil {
 		return nil, errors.Errorf("empty request")
 	}
 
 	// Find same route in db
-	route, err := ucase.routesRepo.GetByAddress(ctx, req.Address)
+	route, err := ucase.routesRepo.GetByAddress(ctx, req.Method, req.Address)
 	if err != nil {
 		return nil, errors.Wrapf(err, "error while fetching route for routing %s", req.Address)
 	}
+
+	// Or route with path captures
+	var pathParams map[string]string
 	if route == nil {
-		return &domain.RedirectRouteResponse{
+		route, pathParams, err = ucase.templates.Match(ctx, req.Method, req.Address)
+		if err != nil {
+			return nil, errors.Wrapf(err, "error while matching route for routing %s", req.Address)
+		}
+	}
+	if route == nil {
+		return &domain.RedirectResolveResponse{
 			Status: core.Status{
 				Code: core.NotFound,
 			},
 		}, nil
 	}
 
-	// For call into Microservice
-	callOptions := services.ProtoCall{
-		Instance: route.Instance,
-		Service:  route.ProtoService,
-		Method:   route.ProtoMethod,
-		Data:     req.Data,
-		Headers:  map[string]string{},
+	return ucase.authorize(ctx, route, pathParams, req.AuthToken)
+}
+
+// ResolveMethod находит маршрут по gRPC методу (/package.Service/Method) и проверяет доступ к нему
+func (ucase *RedirectUCase) ResolveMethod(ctx context.Context, req *domain.RedirectResolveMethodRequest) (*domain.RedirectResolveResponse, error) {
+	if req == nil {
+		return nil, errors.Errorf("empty request")
 	}
 
-	// AUTH
+	// /pb.DBCService/GetCategories
+	parts := strings.Split(strings.TrimPrefix(req.FullMethod, "/"), "/")
+	if len(parts) != 2 {
+		return &domain.RedirectResolveResponse{
+			Status: core.Status{
+				Code: core.NotFound,
+			},
+		}, nil
+	}
+	serviceFullName, methodName := parts[0], parts[1]
+	serviceName := serviceFullName[strings.LastIndex(serviceFullName, ".")+1:]
+
+	routes, err := ucase.routesRepo.GetByMethod(ctx, serviceName, methodName)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error while fetching route for method %s", req.FullMethod)
+	}
+
+	// Same service name may be in several instances (StatusService)
+	for _, route := range routes {
+		method, err := ucase.callerService.Method(route.Instance, route.ProtoService, route.ProtoMethod)
+		if err == nil && method.FullName() == req.FullMethod {
+			return ucase.authorize(ctx, route, nil, req.AuthToken)
+		}
+	}
+	return &domain.RedirectResolveResponse{
+		Status: core.Status{
+			Code: core.NotFound,
+		},
+	}, nil
+}
+
+// authorize checks access to found route
+func (ucase *RedirectUCase) authorize(ctx context.Context, route *domain.Route, pathParams map[string]string, authToken *string) (*domain.RedirectResolveResponse, error) {
+	res := &domain.RedirectResolveResponse{
+		Status: core.Status{
+			Code: core.Success,
+		},
+		Route:      route,
+		PathParams: pathParams,
+	}
+	accesslog.SetRoute(ctx, route.Id, route.Instance, route.ProtoService, route.ProtoMethod)
+	if method, err := ucase.callerService.Method(route.Instance, route.ProtoService, route.ProtoMethod); err == nil {
+		res.Streaming = method.IsServerStreaming()
+	}
 	if route.AccessRole > core.RoleGuest {
-		if req.AuthToken == nil {
-			return &domain.RedirectRouteResponse{
+		if authToken == nil {
+			return &domain.RedirectResolveResponse{
 				Status: core.Status{
 					Code: core.Unauthorised,
 				},
 			}, nil
 		}
 
-		user, err := ucase.authService.Verify(ctx, *req.AuthToken, route.AccessRole)
+		user, err := ucase.authService.Verify(ctx, *authToken, route.AccessRole)
 		if err != nil {
 			return nil, errors.Wrapf(err, "error while verifying request")
 		}
 		if user == nil {
-			return &domain.RedirectRouteResponse{
+			return &domain.RedirectResolveResponse{
 				Status: core.Status{
 					Code: core.Unauthorised,
 				},
 			}, nil
 		}
+		res.UserId = &user.Id
+		accesslog.SetUser(ctx, user.Id)
+	}
+
+	return res, nil
+}
 
-		// Set headers
-		callOptions.Headers["user_id"] = strconv.FormatInt(int64(user.Id), 10)
+// Route Перенаправляет входящий REST запрос на микросервис
+func (ucase *RedirectUCase) Route(ctx context.Context, req *domain.RedirectRouteRequest) (*domain.RedirectRouteResponse, error) {
+	resolved, err := ucase.resolved(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	if resolved.Status.Code != core.Success {
+		return &domain.RedirectRouteResponse{
+			Status: resolved.Status,
+		}, nil
 	}
+	route := resolved.Route
 
 	//
 	// HERE REQUEST IS AUTHORIZED!
 	//
 
-	// Call
-	response := &core.StatusResponse{}
-	bytes, err := ucase.callerService.CallAndParse(ctx, callOptions, response)
+	callOptions := ucase.callOptions(resolved, req)
+	if route.CacheTtl > 0 {
+		return ucase.routeCached(ctx, resolved, req, callOptions)
+	}
+
+	res, _, err := ucase.call(ctx, route, callOptions)
+	return res, err
+}
+
+// call calls instance, cacheable is false for not success responses
+func (ucase *RedirectUCase) call(ctx context.Context, route *domain.Route, callOptions services.ProtoCall) (*domain.RedirectRouteResponse, bool, error) {
+	if route.IsComposite() {
+		return ucase.callComposite(ctx, route, callOptions)
+	}
+	if res := ucase.transformRequest(route, &callOptions); res != nil {
+		return res, false, nil
+	}
+
+	bytes, err := ucase.caller.Call(ctx, callOptions)
+	cacheable := false
+	if err == nil && route.CacheTtl > 0 {
+		code, statusErr := ucase.responseStatusCode(route, callOptions.OutputCodec, bytes)
+		if statusErr != nil {
+			ucase.log.WithContext(ctx).WarnWrap(statusErr, "response is not cached (route=%d)", route.Id)
+		}
+		cacheable = statusErr == nil && (code == core.Success || code == "") // without status
+	}
+	if res := ucase.errorResponse(ctx, route, err); res != nil {
+		return res, false, nil
+	}
 	if err != nil {
-		return nil, errors.Wrapf(err, "error while call instance method")
+		return nil, false, errors.Wrapf(err, "error while call instance method")
+	}
+	if callOptions.OutputCodec.ContentType() == app.ContentTypeJson {
+		if bytes, err = transformResponse(route.Transform, bytes); err != nil {
+			return nil, false, errors.Wrapf(err, "cannot transform response")
+		}
 	}
 
 	return &domain.RedirectRouteResponse{
@@ -98,5 +218,235 @@ func (ucase *RedirectUCase) Route(ctx context.Context, req *domain.RedirectRoute
 		Status: core.Status{
 			Code: core.Success,
 		},
+		ContentType: callOptions.OutputCodec.ContentType(),
+	}, cacheable, nil
+}
+
+// Stream Перенаправляет запрос на server-streaming метод, каждое сообщение уходит в send
+func (ucase *RedirectUCase) Stream(ctx context.Context, req *domain.RedirectRouteRequest, send func([]byte) error) (*domain.RedirectRouteResponse, error) {
+	resolved, err := ucase.resolved(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	if resolved.Status.Code != core.Success {
+		return &domain.RedirectRouteResponse{
+			Status: resolved.Status,
+		}, nil
+	}
+	route := resolved.Route
+
+	// Streams are always JSON
+	req.Accept = app.ContentTypeJson
+	callOptions := ucase.callOptions(resolved, req)
+	if res := ucase.transformRequest(route, &callOptions); res != nil {
+		return res, nil
+	}
+
+	err = ucase.callerService.Stream(ctx, callOptions, func(msg []byte) error {
+		msg, err := transformResponse(route.Transform, msg)
+		if err != nil {
+			return errors.Wrapf(err, "cannot transform message")
+		}
+		return send(msg)
+	})
+	if res := ucase.errorResponse(ctx, route, err); res != nil {
+		return res, nil
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "error while stream instance method")
+	}
+
+	return &domain.RedirectRouteResponse{
+		Status: core.Status{
+			Code: core.Success,
+		},
+		ContentType: callOptions.OutputCodec.ContentType(),
 	}, nil
 }
+
+// OpenStream открывает поток к client-streaming или bidirectional методу
+func (ucase *RedirectUCase) OpenStream(ctx context.Context, req *domain.RedirectRouteRequest) (*domain.RedirectStreamResponse, error) {
+	resolved, err := ucase.resolved(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	if resolved.Status.Code != core.Success {
+		return &domain.RedirectStreamResponse{
+			Status: resolved.Status,
+		}, nil
+	}
+	route := resolved.Route
+
+	if route.IsComposite() {
+		return &domain.RedirectStreamResponse{
+			Status: core.Status{
+				Code:    core.ValidationError,
+				Message: "method is not streaming",
+			},
+		}, nil
+	}
+	method, err := ucase.callerService.Method(route.Instance, route.ProtoService, route.ProtoMethod)
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot find method of route %s", route.HttpAddress)
+	}
+	if !method.IsClientStreaming() && !method.IsServerStreaming() {
+		return &domain.RedirectStreamResponse{
+			Status: core.Status{
+				Code:    core.ValidationError,
+				Message: "method is not streaming",
+			},
+		}, nil
+	}
+
+	// Streams are always JSON
+	req.ContentType = app.ContentTypeJson
+	req.Accept = app.ContentTypeJson
+	callOptions := ucase.callOptions(resolved, req)
+
+	stream, err := ucase.callerService.OpenStream(ctx, callOptions)
+	if res := ucase.errorResponse(ctx, route, err); res != nil {
+		return &domain.RedirectStreamResponse{
+			Status: res.Status,
+		}, nil
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "error while open instance stream")
+	}
+	return &domain.RedirectStreamResponse{
+		Status: core.Status{
+			Code: core.Success,
+		},
+		Stream: stream,
+	}, nil
+}
+
+// resolved returns already resolved route or resolves it
+func (ucase *RedirectUCase) resolved(ctx context.Context, req *domain.RedirectRouteRequest) (*domain.RedirectResolveResponse, error) {
+	if req == nil {
+		return nil, errors.Errorf("empty request")
+	}
+	if req.Resolved != nil {
+		return req.Resolved, nil
+	}
+	return ucase.Resolve(ctx, &domain.RedirectResolveRequest{
+		AuthToken: req.AuthToken,
+		Address:   req.Address,
+	})
+}
+
+// callOptions makes call into Microservice
+func (ucase *RedirectUCase) callOptions(resolved *domain.RedirectResolveResponse, req *domain.RedirectRouteRequest) services.ProtoCall {
+	route := resolved.Route
+
+	callOptions := services.ProtoCall{
+		Instance:        route.Instance,
+		Service:         route.ProtoService,
+		Method:          route.ProtoMethod,
+		Data:            req.Data,
+		Headers:         map[string]string{},
+		MaxResponseSize: route.MaxResponseSize,
+		Coalesce:        route.Coalesce,
+		FieldMask:       req.FieldMask,
+	}
+	if callOptions.MaxResponseSize <= 0 {
+		callOptions.MaxResponseSize = viper.GetInt64("rest.max_response_size")
+	}
+
+	// Path captures override query
+	callOptions.Params = url.Values{}
+	for k, v := range req.Query {
+		callOptions.Params[k] = v
+	}
+	for k, v := range resolved.PathParams {
+		callOptions.Params.Set(k, v)
+	}
+
+	// Set headers
+	if resolved.UserId != nil {
+		callOptions.Headers["user_id"] = strconv.FormatInt(int64(*resolved.UserId), 10)
+	}
+
+	// Codecs
+	jsonOptions := routeJsonOptions(route)
+	callOptions.InputCodec = app.CodecByContentType(req.ContentType, jsonOptions)
+	callOptions.OutputCodec = app.CodecByContentType(req.Accept, jsonOptions)
+	if route.IsComposite() {
+		// Results are merged as JSON
+		callOptions.OutputCodec = app.CodecByContentType(app.ContentTypeJson, jsonOptions)
+	}
+
+	return callOptions
+}
+
+// transformRequest applies route`s request transform to JSON body
+func (ucase *RedirectUCase) transformRequest(route *domain.Route, callOptions *services.ProtoCall) *domain.RedirectRouteResponse {
+	if route.Transform == nil || callOptions.InputCodec.ContentType() != app.ContentTypeJson {
+		return nil
+	}
+	data, err := transformRequest(route.Transform, callOptions.Data)
+	if err != nil {
+		return &domain.RedirectRouteResponse{
+			Status: core.Status{
+				Code:    core.ValidationError,
+				Message: err.Error(),
+			},
+		}
+	}
+	callOptions.Data = data
+	return nil
+}
+
+// errorResponse converts known call errors to client`s status
+func (ucase *RedirectUCase) errorResponse(ctx context.Context, route *domain.Route, err error) *domain.RedirectRouteResponse {
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, services.ErrInstanceUnavailable):
+		return &domain.RedirectRouteResponse{
+			Status: core.Status{
+				Code:    core.Unavailable,
+				Message: "instance " + route.Instance + " is unavailable",
+			},
+		}
+	case errors.Is(err, services.ErrResponseTooLarge):
+		ucase.log.WithContext(ctx).WarnWrap(err, "response of route %s was dropped", route.HttpAddress)
+		return &domain.RedirectRouteResponse{
+			Status: core.Status{
+				Code: core.PayloadTooLarge,
+			},
+		}
+	case app.IsValidationError(err):
+		status := core.Status{
+			Code:    core.ValidationError,
+			Message: errors.Cause(err).Error(),
+		}
+		for _, field := range app.ValidationFields(err) {
+			status.Errors = append(status.Errors, core.FieldError{Path: field.Path, Message: field.Message})
+		}
+		return &domain.RedirectRouteResponse{
+			Status: status,
+		}
+	}
+	return nil
+}
+
+// routeJsonOptions makes protojson options: config -> instance config -> route
+func routeJsonOptions(route *domain.Route) app.JsonOptions {
+	option := func(name string, routeVal *bool) bool {
+		if routeVal != nil {
+			return *routeVal
+		}
+		instanceKey := "proto.instances." + route.Instance + ".json." + name
+		if viper.IsSet(instanceKey) {
+			return viper.GetBool(instanceKey)
+		}
+		return viper.GetBool("proto.json." + name)
+	}
+
+	return app.JsonOptions{
+		UseProtoNames:   option("use_proto_names", route.Json.UseProtoNames),
+		EmitUnpopulated: option("emit_unpopulated", route.Json.EmitUnpopulated),
+		UseEnumNumbers:  option("use_enum_numbers", route.Json.UseEnumNumbers),
+		DiscardUnknown:  option("discard_unknown", route.Json.DiscardUnknown),
+	}
+}
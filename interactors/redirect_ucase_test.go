@@ -0,0 +1,76 @@
+package interactors
+
+import (
+	"context"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+	"microservice/app"
+	"microservice/app/core"
+	"microservice/domain"
+	"microservice/services"
+	"testing"
+)
+
+// testRoutesRepo selects routes like RoutesRepo: exact address by method, route of method is preferred
+type testRoutesRepo struct {
+	domain.RoutesRepository
+	routes []*domain.Route
+}
+
+func (r *testRoutesRepo) All(context.Context) ([]*domain.Route, error) {
+	return r.routes, nil
+}
+
+func (r *testRoutesRepo) GetByAddress(_ context.Context, method, addr string) (*domain.Route, error) {
+	var found *domain.Route
+	for _, route := range r.routes {
+		if route.HttpAddress != addr || !services.MethodMatches(route, method) {
+			continue
+		}
+		if found == nil || found.HttpMethod == "" && route.HttpMethod != "" {
+			found = route
+		}
+	}
+	return found, nil
+}
+
+func TestResolveExactRouteByMethod(t *testing.T) {
+	log := app.NewDefaultLogger(logrus.New())
+	repo := &testRoutesRepo{routes: []*domain.Route{
+		{Id: 1, HttpMethod: "GET", HttpAddress: "/x"},
+		{Id: 2, HttpMethod: "POST", HttpAddress: "/x"},
+		{Id: 3, HttpAddress: "/y"},
+		{Id: 4, HttpMethod: "DELETE", HttpAddress: "/y"},
+	}}
+	ucase := &RedirectUCase{
+		log:           log,
+		routesRepo:    repo,
+		callerService: services.NewProtoCallerService(log, app.NewProtoRegistry(), nil, nil),
+		templates:     services.NewRouteTemplates(log, repo),
+	}
+
+	tests := []struct {
+		method, address string
+		id              int64
+	}{
+		{"GET", "/x", 1},
+		{"post", "/x", 2},
+		{"PUT", "/x", 0},
+		{"GET", "/y", 3},
+		{"DELETE", "/y", 4},
+	}
+	for _, test := range tests {
+		res, err := ucase.Resolve(context.Background(), &domain.RedirectResolveRequest{
+			Method:  test.method,
+			Address: test.address,
+		})
+		require.NoError(t, err)
+		name := test.method + " " + test.address
+		if test.id == 0 {
+			require.Equal(t, core.NotFound, res.Status.Code, name)
+			continue
+		}
+		require.Equal(t, core.Success, res.Status.Code, name)
+		require.Equal(t, test.id, res.Route.Id, name)
+	}
+}
@@ -0,0 +1,168 @@
+package interactors
+
+import (
+	"context"
+	"github.com/pkg/errors"
+	"microservice/app"
+	"microservice/app/cache"
+	"microservice/app/core"
+	"microservice/domain"
+	"microservice/services"
+	"strconv"
+	"strings"
+)
+
+type RoutesInteractor struct {
+	log           core.Logger
+	routesRepo    domain.RoutesRepository
+	callerService *services.ProtoCallerService
+	cacheStore    cache.Store
+	templates     *services.RouteTemplates
+}
+
+func NewRoutesInteractor(log core.Logger,
+	routesRepo domain.RoutesRepository,
+	callerService *services.ProtoCallerService,
+	cacheStore cache.Store,
+	templates *services.RouteTemplates) *RoutesInteractor {
+	return &RoutesInteractor{
+		log:           log,
+		routesRepo:    routesRepo,
+		callerService: callerService,
+		cacheStore:    cacheStore,
+		templates:     templates,
+	}
+}
+
+func (s *RoutesInteractor) All(ctx context.Context) (*domain.RoutesAllResponse, error) {
+	items, err := s.routesRepo.All(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "error while getting routes list")
+	}
+	return &domain.RoutesAllResponse{
+		Status: core.Status{
+			Code: core.Success,
+		},
+		Routes: items,
+	}, nil
+}
+
+// Create добавляет маршрут, метод должен существовать в proto файлах инстанса
+func (s *RoutesInteractor) Create(ctx context.Context, route *domain.Route) (*domain.RoutesCreateResponse, error) {
+	if route == nil {
+		return nil, errors.Errorf("empty request")
+	}
+
+	if !strings.HasPrefix(route.HttpAddress, "/") {
+		return &domain.RoutesCreateResponse{
+			Status: core.Status{
+				Code:    core.ValidationError,
+				Message: "http_address should start with /",
+			},
+		}, nil
+	}
+	if route.CacheTtl < 0 || route.CacheStale < 0 {
+		return &domain.RoutesCreateResponse{
+			Status: core.Status{
+				Code:    core.ValidationError,
+				Message: "cache_ttl and cache_stale should not be negative",
+			},
+		}, nil
+	}
+	// Composite route calls several methods instead of one
+	var err error
+	if route.IsComposite() {
+		err = validateCompositeCalls(s.callerService, route.Calls)
+		if err == nil && route.Transform != nil {
+			err = errors.New("transform is not supported by composite routes")
+		}
+	} else {
+		var method *app.ProtoMethod
+		method, err = s.callerService.Method(route.Instance, route.ProtoService, route.ProtoMethod)
+		if err == nil {
+			err = validateTransform(method, route.Transform)
+		}
+	}
+	if err != nil {
+		return &domain.RoutesCreateResponse{
+			Status: core.Status{
+				Code:    core.ValidationError,
+				Message: err.Error(),
+			},
+		}, nil
+	}
+
+	switch route.RateLimitBy {
+	case "":
+		route.RateLimitBy = domain.RateLimitByIp
+	case domain.RateLimitByIp, domain.RateLimitByUser, domain.RateLimitByApiKey, domain.RateLimitByRoute:
+	default:
+		return &domain.RoutesCreateResponse{
+			Status: core.Status{
+				Code:    core.ValidationError,
+				Message: "unknown rate_limit_by " + route.RateLimitBy,
+			},
+		}, nil
+	}
+
+	if err := s.routesRepo.Insert(ctx, route); err != nil {
+		return nil, errors.Wrap(err, "error while inserting route")
+	}
+	s.reloadTemplates(ctx)
+	return &domain.RoutesCreateResponse{
+		Status: core.Status{
+			Code: core.Success,
+		},
+		Id: route.Id,
+	}, nil
+}
+
+func (s *RoutesInteractor) Delete(ctx context.Context, id int64) (*core.StatusResponse, error) {
+	if err := s.routesRepo.Delete(ctx, id); err != nil {
+		return nil, errors.Wrapf(err, "error while deleting route %d", id)
+	}
+	s.reloadTemplates(ctx)
+	return &core.StatusResponse{
+		Status: core.Status{
+			Code: core.Success,
+		},
+	}, nil
+}
+
+// PurgeCache удаляет кэшированные ответы маршрута и/или по префиксу пути (пустой запрос - весь кэш)
+func (s *RoutesInteractor) PurgeCache(ctx context.Context, req *domain.RoutesPurgeCacheRequest) (*domain.RoutesPurgeCacheResponse, error) {
+	if req == nil {
+		return nil, errors.Errorf("empty request")
+	}
+
+	keyPrefix := ""
+	if req.RouteId > 0 {
+		keyPrefix = strconv.FormatInt(req.RouteId, 10) + ":"
+	}
+	var match func(key string) bool
+	if req.Prefix != "" {
+		match = func(key string) bool {
+			return strings.HasPrefix(cacheKeyPath(key), req.Prefix)
+		}
+	}
+
+	purged, err := s.cacheStore.Purge(ctx, keyPrefix, match)
+	if err != nil {
+		return nil, errors.Wrap(err, "error while purging cache")
+	}
+	s.log.Info("Cache purged (route=%d, prefix=%s): %d entries", req.RouteId, req.Prefix, purged)
+
+	return &domain.RoutesPurgeCacheResponse{
+		Status: core.Status{
+			Code: core.Success,
+		},
+		Purged: purged,
+	}, nil
+}
+
+// reloadTemplates applies changed routes at once (other replicas reload them by routes.reload_interval)
+func (s *RoutesInteractor) reloadTemplates(ctx context.Context) {
+	if err := s.templates.Reload(ctx); err != nil {
+		s.log.WithContext(ctx).ErrorWrap(err, "cannot reload route templates")
+	}
+}
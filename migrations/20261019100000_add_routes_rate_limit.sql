@@ -0,0 +1,15 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE routes
+    ADD COLUMN IF NOT EXISTS rate_limit    int          default 0,
+    ADD COLUMN IF NOT EXISTS rate_burst    int          default 0,
+    ADD COLUMN IF NOT EXISTS rate_limit_by varchar(255) default 'ip';
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE routes
+    DROP COLUMN IF EXISTS rate_limit,
+    DROP COLUMN IF EXISTS rate_burst,
+    DROP COLUMN IF EXISTS rate_limit_by;
+-- +goose StatementEnd
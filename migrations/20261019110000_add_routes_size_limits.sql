@@ -0,0 +1,13 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE routes
+    ADD COLUMN IF NOT EXISTS max_request_size  bigint default 0,
+    ADD COLUMN IF NOT EXISTS max_response_size bigint default 0;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE routes
+    DROP COLUMN IF EXISTS max_request_size,
+    DROP COLUMN IF EXISTS max_response_size;
+-- +goose StatementEnd
@@ -0,0 +1,17 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE routes
+    ADD COLUMN IF NOT EXISTS json_use_proto_names   boolean default null,
+    ADD COLUMN IF NOT EXISTS json_emit_unpopulated  boolean default null,
+    ADD COLUMN IF NOT EXISTS json_use_enum_numbers  boolean default null,
+    ADD COLUMN IF NOT EXISTS json_discard_unknown   boolean default null;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE routes
+    DROP COLUMN IF EXISTS json_use_proto_names,
+    DROP COLUMN IF EXISTS json_emit_unpopulated,
+    DROP COLUMN IF EXISTS json_use_enum_numbers,
+    DROP COLUMN IF EXISTS json_discard_unknown;
+-- +goose StatementEnd
@@ -0,0 +1,15 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE routes
+    ADD COLUMN IF NOT EXISTS cache_ttl      int     not null default 0,
+    ADD COLUMN IF NOT EXISTS cache_swr      int     not null default 0,
+    ADD COLUMN IF NOT EXISTS cache_per_user boolean not null default false;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE routes
+    DROP COLUMN IF EXISTS cache_ttl,
+    DROP COLUMN IF EXISTS cache_swr,
+    DROP COLUMN IF EXISTS cache_per_user;
+-- +goose StatementEnd
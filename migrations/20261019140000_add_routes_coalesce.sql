@@ -0,0 +1,11 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE routes
+    ADD COLUMN IF NOT EXISTS coalesce boolean not null default false;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE routes
+    DROP COLUMN IF EXISTS coalesce;
+-- +goose StatementEnd
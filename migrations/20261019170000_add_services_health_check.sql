@@ -0,0 +1,11 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE services
+    ADD COLUMN IF NOT EXISTS health_check jsonb default null;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE services
+    DROP COLUMN IF EXISTS health_check;
+-- +goose StatementEnd
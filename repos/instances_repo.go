@@ -27,7 +27,8 @@ func (r *InstancesRepo) All(ctx context.Context) ([]*domain.Instance, error) {
 	query := `SELECT id, 
        			folder, 
        			endpoint, 
-       			is_active
+       			is_active,
+       			health_check
 			FROM services 
 			WHERE deleted_at is null
 			ORDER BY created_at;`
@@ -40,7 +41,8 @@ func (r *InstancesRepo) All(ctx context.Context) ([]*domain.Instance, error) {
 		err = raws.Scan(&item.Id,
 			&item.Folder,
 			&item.Endpoint,
-			&item.IsActive)
+			&item.IsActive,
+			&jsonColumn{&item.HealthCheck})
 		if err != nil {
 			return nil, err
 		}
@@ -56,14 +58,16 @@ func (r *InstancesRepo) GetByFolder(ctx context.Context, folder string) (*domain
 	query := `SELECT id, 
        			folder, 
        			endpoint, 
-       			is_active
+       			is_active,
+       			health_check
 			FROM services 
 			WHERE deleted_at is null and folder=$1
 			ORDER BY created_at;`
 	err := r.db.QueryRowContext(ctx, query, folder).Scan(&item.Id,
 		&item.Folder,
 		&item.Endpoint,
-		&item.IsActive)
+		&item.IsActive,
+		&jsonColumn{&item.HealthCheck})
 	switch err {
 	case nil:
 		return item, nil
@@ -96,7 +100,7 @@ func (r *InstancesRepo) Delete(ctx context.Context, id int32) error {
 }
 
 func (r *InstancesRepo) Update(ctx context.Context, req *tools.UpdateReq) error {
-	k, v := req.BuildFor("folder", "endpoint", "is_active")
+	k, v := req.BuildFor("folder", "endpoint", "is_active", "health_check")
 	query := fmt.Sprintf("UPDATE services SET %s, updated_at=now() WHERE id=$1", k)
 	_, err := r.db.ExecContext(ctx, query, v...)
 	if err != nil {
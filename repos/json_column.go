@@ -0,0 +1,38 @@
+package repos
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"github.com/pkg/errors"
+)
+
+// jsonColumn scans jsonb column into v and writes v as jsonb (nil - NULL)
+type jsonColumn struct {
+	v interface{}
+}
+
+func (c *jsonColumn) Scan(src interface{}) error {
+	var data []byte
+	switch src := src.(type) {
+	case nil:
+		return nil
+	case []byte:
+		data = src
+	case string:
+		data = []byte(src)
+	default:
+		return errors.Errorf("cannot scan %T into json column", src)
+	}
+	return json.Unmarshal(data, c.v)
+}
+
+func (c *jsonColumn) Value() (driver.Value, error) {
+	data, err := json.Marshal(c.v)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot marshal json column")
+	}
+	if string(data) == "null" {
+		return nil, nil
+	}
+	return string(data), nil
+}
@@ -3,7 +3,9 @@ package repos
 import (
 	"context"
 	"database/sql"
+	"go.opentelemetry.io/otel/attribute"
 	"microservice/app/core"
+	"microservice/app/tracing"
 	"microservice/domain"
 )
 
@@ -19,64 +21,87 @@ func NewRoutesRepo(log core.Logger, db *sql.DB) *RoutesRepo {
 	}
 }
 
-func (r *RoutesRepo) All(ctx context.Context) ([]*domain.Route, error) {
-	var items []*domain.Route
-
-	query := `SELECT id, 
+// routeColumns are selected in the same order as routeFields
+const routeColumns = `id, 
        			from_method, 
        			from_address,
        			instance,
        			proto_service, 
        			proto_method, 
        			access_role,
-       			is_active
+       			is_active,
+       			rate_limit,
+       			rate_burst,
+       			rate_limit_by,
+       			max_request_size,
+       			max_response_size,
+       			json_use_proto_names,
+       			json_emit_unpopulated,
+       			json_use_enum_numbers,
+       			json_discard_unknown,
+       			cache_ttl,
+       			cache_swr,
+       			cache_per_user,
+       			coalesce,
+       			calls,
+       			transform`
+
+func routeFields(item *domain.Route) []interface{} {
+	return []interface{}{
+		&item.Id,
+		&item.HttpMethod,
+		&item.HttpAddress,
+		&item.Instance,
+		&item.ProtoService,
+		&item.ProtoMethod,
+		&item.AccessRole,
+		&item.IsActive,
+		&item.RateLimit,
+		&item.RateBurst,
+		&item.RateLimitBy,
+		&item.MaxRequestSize,
+		&item.MaxResponseSize,
+		&item.Json.UseProtoNames,
+		&item.Json.EmitUnpopulated,
+		&item.Json.UseEnumNumbers,
+		&item.Json.DiscardUnknown,
+		&item.CacheTtl,
+		&item.CacheStale,
+		&item.CachePerUser,
+		&item.Coalesce,
+		&jsonColumn{&item.Calls},
+		&jsonColumn{&item.Transform},
+	}
+}
+
+func (r *RoutesRepo) All(ctx context.Context) (_ []*domain.Route, err error) {
+	ctx, span := tracing.Start(ctx, "RoutesRepo.All")
+	defer func() { tracing.End(span, err) }()
+
+	query := `SELECT ` + routeColumns + `
 			FROM routes 
 			WHERE deleted_at is null
 			ORDER BY created_at;`
-	raws, err := r.db.QueryContext(ctx, query)
-	if err != nil {
-		return nil, err
-	}
-	for raws.Next() {
-		item := &domain.Route{}
-		err = raws.Scan(&item.Id,
-			&item.HttpMethod,
-			&item.HttpAddress,
-			&item.Instance,
-			&item.ProtoService,
-			&item.ProtoMethod,
-			&item.AccessRole,
-			&item.IsActive)
-		if err != nil {
-			return nil, err
-		}
-		items = append(items, item)
-	}
-	return items, nil
+	return r.list(ctx, query)
 }
 
-func (r *RoutesRepo) GetByAddress(ctx context.Context, addr string) (*domain.Route, error) {
+// GetByAddress returns route of address and HTTP method (empty method - any),
+// route of this method is preferred to route of any method
+func (r *RoutesRepo) GetByAddress(ctx context.Context, method, addr string) (_ *domain.Route, err error) {
+	ctx, span := tracing.Start(ctx, "RoutesRepo.GetByAddress",
+		attribute.String("http.method", method),
+		attribute.String("route.address", addr),
+	)
+	defer func() { tracing.End(span, err) }()
+
 	item := &domain.Route{}
 
-	query := `SELECT id, 
-       			from_method, 
-       			from_address, 
-       			instance,
-       			proto_service, 
-       			proto_method, 
-       			access_role,
-       			is_active
+	query := `SELECT ` + routeColumns + `
 			FROM routes 
 			WHERE deleted_at is null and from_address=$1
-			ORDER BY created_at;`
-	err := r.db.QueryRowContext(ctx, query, addr).Scan(&item.Id,
-		&item.HttpMethod,
-		&item.HttpAddress,
-		&item.Instance,
-		&item.ProtoService,
-		&item.ProtoMethod,
-		&item.AccessRole,
-		&item.IsActive)
+				and ($2 = '' or coalesce(from_method, '') = '' or upper(from_method) = upper($2))
+			ORDER BY coalesce(from_method, '') = '', created_at;`
+	err = r.db.QueryRowContext(ctx, query, addr, method).Scan(routeFields(item)...)
 
 	switch err {
 	case nil:
@@ -88,15 +113,69 @@ func (r *RoutesRepo) GetByAddress(ctx context.Context, addr string) (*domain.Rou
 	}
 }
 
+// GetByMethod returns routes to proto method (short service name)
+func (r *RoutesRepo) GetByMethod(ctx context.Context, service, method string) (_ []*domain.Route, err error) {
+	ctx, span := tracing.Start(ctx, "RoutesRepo.GetByMethod",
+		attribute.String("rpc.service", service),
+		attribute.String("rpc.method", method),
+	)
+	defer func() { tracing.End(span, err) }()
+
+	query := `SELECT ` + routeColumns + `
+			FROM routes 
+			WHERE deleted_at is null and proto_service=$1 and proto_method=$2
+			ORDER BY created_at;`
+	return r.list(ctx, query, service, method)
+}
+
+func (r *RoutesRepo) list(ctx context.Context, query string, args ...interface{}) ([]*domain.Route, error) {
+	var items []*domain.Route
+
+	raws, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer raws.Close()
+	for raws.Next() {
+		item := &domain.Route{}
+		err = raws.Scan(routeFields(item)...)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
+
 func (r *RoutesRepo) Insert(ctx context.Context, item *domain.Route) error {
 	var id int64
-	query := "INSERT INTO routes (from_method, from_address, instance, proto_service, proto_method, access_role) VALUES ($1, $2, $3, $4, $5, $6) returning id"
+	query := `INSERT INTO routes (from_method, from_address, instance, proto_service, proto_method, access_role,
+                    rate_limit, rate_burst, rate_limit_by, max_request_size, max_response_size,
+                    json_use_proto_names, json_emit_unpopulated, json_use_enum_numbers, json_discard_unknown,
+                    cache_ttl, cache_swr, cache_per_user, coalesce, calls, transform)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20, $21) returning id`
 	err := r.db.QueryRowContext(ctx, query,
 		item.HttpMethod,
 		item.HttpAddress,
 		item.Instance,
 		item.ProtoService,
-		item.ProtoMethod).Scan(&id)
+		item.ProtoMethod,
+		item.AccessRole,
+		item.RateLimit,
+		item.RateBurst,
+		item.RateLimitBy,
+		item.MaxRequestSize,
+		item.MaxResponseSize,
+		item.Json.UseProtoNames,
+		item.Json.EmitUnpopulated,
+		item.Json.UseEnumNumbers,
+		item.Json.DiscardUnknown,
+		item.CacheTtl,
+		item.CacheStale,
+		item.CachePerUser,
+		item.Coalesce,
+		&jsonColumn{item.Calls},
+		&jsonColumn{item.Transform}).Scan(&id)
 	if err != nil {
 		return err
 	}
@@ -105,7 +184,7 @@ func (r *RoutesRepo) Insert(ctx context.Context, item *domain.Route) error {
 }
 
 func (r *RoutesRepo) Delete(ctx context.Context, id int64) error {
-	query := "UPDATE services SET deleted_at=now() WHERE id=$1"
+	query := "UPDATE routes SET deleted_at=now() WHERE id=$1"
 	_, err := r.db.ExecContext(ctx, query, id)
 	if err != nil {
 		return err
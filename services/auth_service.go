@@ -2,12 +2,16 @@ package services
 
 import (
 	"context"
-	"fmt"
 	"github.com/pkg/errors"
 	"github.com/spf13/viper"
+	"go.opentelemetry.io/otel/attribute"
 	"google.golang.org/grpc/metadata"
+	"microservice/app/accesslog"
 	"microservice/app/core"
+	"microservice/app/metrics"
+	"microservice/app/tracing"
 	"microservice/pkg/auth_service/api"
+	"time"
 )
 
 // AuthService calls remote proto
@@ -36,7 +40,27 @@ func (s *AuthService) syncServerClient(ctx context.Context) error {
 	return nil
 }
 
+// Verify returns user of token if user`s role is enough (nil - access denied)
 func (s *AuthService) Verify(ctx context.Context, authToken string, needRole core.AccessRole) (*api.User, error) {
+	ctx, span := tracing.Start(ctx, "AuthService.Verify")
+	start := time.Now()
+	user, err := s.verify(ctx, authToken, needRole)
+
+	result := "ok"
+	switch {
+	case err != nil:
+		result = "error"
+	case user == nil:
+		result = "denied"
+	}
+	metrics.AuthVerifyDuration.WithLabelValues(result).Observe(metrics.Since(start))
+	accesslog.AddAuth(ctx, time.Since(start))
+	span.SetAttributes(attribute.String("auth.result", result))
+	tracing.End(span, err)
+	return user, err
+}
+
+func (s *AuthService) verify(ctx context.Context, authToken string, needRole core.AccessRole) (*api.User, error) {
 
 	// if endpoint didnt change than not updating
 	err := s.syncServerClient(ctx)
@@ -55,17 +79,17 @@ func (s *AuthService) Verify(ctx context.Context, authToken string, needRole cor
 
 	verifyRes, err := s.client.Verify(ctx, verifyReq)
 	if err != nil {
-		return nil, errors.Wrapf(err, "error while verifying access %s", authToken)
+		return nil, errors.Wrap(err, "error while verifying access")
 	}
 
 	if verifyRes.Status.Code != "success" || verifyRes.User == nil {
-		s.log.Debug(fmt.Sprintf("incorrect token (%s) for auth_service", authToken))
+		s.log.WithContext(ctx).With(core.Fields{"token_hash": core.TokenHash(authToken)}).Debug("incorrect token for auth_service")
 		return nil, nil
 	}
 
 	realRole := core.AccessRole(verifyRes.User.Role)
 	if realRole < needRole {
-		s.log.Debug("user role (%d) < need role (%d): token = %s", realRole, needRole, authToken)
+		s.log.WithContext(ctx).With(core.Fields{"token_hash": core.TokenHash(authToken)}).Debug("user role (%d) < need role (%d)", realRole, needRole)
 		return nil, nil
 	}
 
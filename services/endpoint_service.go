@@ -5,8 +5,11 @@ import (
 	"github.com/pkg/errors"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials/insecure"
+	"microservice/app"
 	"microservice/app/core"
+	"microservice/app/tracing"
 	"microservice/domain"
+	"sync"
 )
 
 // EndpointConnectionService get endpoint for service instance
@@ -14,6 +17,7 @@ type EndpointConnectionService struct {
 	log           core.Logger
 	instancesRepo domain.InstancesRepository
 
+	mu        sync.Mutex
 	clients   map[string]*grpc.ClientConn
 	endpoints map[string]string
 }
@@ -41,6 +45,10 @@ func (s *EndpointConnectionService) GetConnWithStatus(ctx context.Context, insta
 		return nil, false, errors.Errorf("%s not found", instanceName)
 	}
 
+	// Health checks get connections concurrently with calls
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
 	// If endpoint is the same then not updating
 	if c, ok1 := s.clients[instanceName]; ok1 {
 		if v, ok2 := s.endpoints[instanceName]; ok2 && v == instance.Endpoint {
@@ -49,7 +57,11 @@ func (s *EndpointConnectionService) GetConnWithStatus(ctx context.Context, insta
 	}
 
 	// creating new connection
-	conn, err := grpc.Dial(instance.Endpoint, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	conn, err := grpc.Dial(instance.Endpoint,
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithChainUnaryInterceptor(tracing.UnaryClientInterceptor(), app.RequestIdUnaryInterceptor()),
+		grpc.WithChainStreamInterceptor(tracing.StreamClientInterceptor(), app.RequestIdStreamInterceptor()),
+	)
 	if err != nil {
 		return nil, false, errors.Wrapf(err, "cannot make Dial for AuthService with address %s", s.endpoints[instanceName])
 	}
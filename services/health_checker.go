@@ -0,0 +1,246 @@
+package services
+
+import (
+	"context"
+	"github.com/pkg/errors"
+	"github.com/spf13/viper"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health/grpc_health_v1"
+	"microservice/app/core"
+	"microservice/app/metrics"
+	"microservice/domain"
+	"sync"
+	"time"
+)
+
+// healthChecker runs active health check of one instance until it is stopped
+type healthChecker struct {
+	log      core.Logger
+	instance string
+	config   domain.InstanceHealthCheck
+	health   *InstancesHealth
+
+	conn func(ctx context.Context) (*grpc.ClientConn, error)
+	ping func(ctx context.Context) error
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// healthCheckConfig fills zero values of instance`s config from health.* config
+func healthCheckConfig(config domain.InstanceHealthCheck) domain.InstanceHealthCheck {
+	if config.Protocol == "" {
+		config.Protocol = domain.HealthCheckPing
+	}
+	if config.IntervalMs <= 0 {
+		config.IntervalMs = int32(durationOr(viper.GetDuration("health.interval"), 10*time.Second).Milliseconds())
+	}
+	if config.TimeoutMs <= 0 {
+		config.TimeoutMs = int32(durationOr(viper.GetDuration("health.timeout"), 2*time.Second).Milliseconds())
+	}
+	if config.HealthyThreshold <= 0 {
+		config.HealthyThreshold = int32(intOr(viper.GetInt("health.healthy_threshold"), 2))
+	}
+	if config.UnhealthyThreshold <= 0 {
+		config.UnhealthyThreshold = int32(intOr(viper.GetInt("health.unhealthy_threshold"), 3))
+	}
+	return config
+}
+
+// ValidateHealthCheck checks instance`s health check config
+func ValidateHealthCheck(config *domain.InstanceHealthCheck) error {
+	switch config.Protocol {
+	case "", domain.HealthCheckPing:
+		if len(config.Services) != 0 || config.Watch {
+			return errors.New("services and watch are supported only by grpc health check")
+		}
+	case domain.HealthCheckGrpc:
+	default:
+		return errors.Errorf("unknown health check protocol %s", config.Protocol)
+	}
+	if config.IntervalMs < 0 || config.TimeoutMs < 0 || config.HealthyThreshold < 0 || config.UnhealthyThreshold < 0 {
+		return errors.New("health check interval, timeout and thresholds should not be negative")
+	}
+	return nil
+}
+
+func (c *healthChecker) start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	c.cancel = cancel
+	c.done = make(chan struct{})
+	go func() {
+		defer close(c.done)
+		if c.config.Protocol == domain.HealthCheckGrpc && c.config.Watch {
+			c.watch(ctx)
+		} else {
+			c.poll(ctx)
+		}
+	}()
+}
+
+func (c *healthChecker) stop() {
+	c.cancel()
+	<-c.done
+}
+
+func (c *healthChecker) interval() time.Duration {
+	return time.Duration(c.config.IntervalMs) * time.Millisecond
+}
+
+func (c *healthChecker) timeout() time.Duration {
+	return time.Duration(c.config.TimeoutMs) * time.Millisecond
+}
+
+func (c *healthChecker) poll(ctx context.Context) {
+	ticker := time.NewTicker(c.interval())
+	defer ticker.Stop()
+	for {
+		err := c.check(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		c.report(err, c.config.HealthyThreshold, c.config.UnhealthyThreshold)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (c *healthChecker) check(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout())
+	defer cancel()
+
+	if c.config.Protocol != domain.HealthCheckGrpc {
+		return c.ping(ctx)
+	}
+
+	conn, err := c.conn(ctx)
+	if err != nil {
+		return err
+	}
+	client := grpc_health_v1.NewHealthClient(conn)
+	for _, service := range c.services() {
+		res, err := client.Check(ctx, &grpc_health_v1.HealthCheckRequest{Service: service})
+		if err != nil {
+			return errors.Wrapf(err, "health check of service %q", service)
+		}
+		if res.Status != grpc_health_v1.HealthCheckResponse_SERVING {
+			return errors.Errorf("service %q is %s", service, res.Status)
+		}
+	}
+	return nil
+}
+
+// watch keeps Health/Watch stream of each service. Each status sent by instance is applied at once,
+// broken stream is a failed check and it is counted by thresholds as in poll
+func (c *healthChecker) watch(ctx context.Context) {
+	services := c.services()
+
+	var mu sync.Mutex
+	statuses := make(map[string]error, len(services))
+	update := func(service string, err error, healthyThreshold, unhealthyThreshold int32) {
+		mu.Lock()
+		defer mu.Unlock()
+		statuses[service] = err
+		if len(statuses) < len(services) {
+			return // wait for first status of each service
+		}
+		var failed error
+		for _, s := range services {
+			if statuses[s] != nil {
+				failed = statuses[s]
+				break
+			}
+		}
+		c.report(failed, healthyThreshold, unhealthyThreshold)
+	}
+	status := func(service string, err error) {
+		update(service, err, 1, 1)
+	}
+
+	var wg sync.WaitGroup
+	for _, service := range services {
+		wg.Add(1)
+		go func(service string) {
+			defer wg.Done()
+			for {
+				err := c.watchService(ctx, service, status)
+				if ctx.Err() != nil {
+					return
+				}
+				update(service, err, c.config.HealthyThreshold, c.config.UnhealthyThreshold)
+
+				// Reconnect after interval
+				select {
+				case <-ctx.Done():
+					return
+				case <-time.After(c.interval()):
+				}
+			}
+		}(service)
+	}
+	wg.Wait()
+}
+
+func (c *healthChecker) watchService(ctx context.Context, service string, status func(string, error)) error {
+	openCtx, cancel := context.WithTimeout(ctx, c.timeout())
+	conn, err := c.conn(openCtx)
+	cancel()
+	if err != nil {
+		return err
+	}
+
+	stream, err := grpc_health_v1.NewHealthClient(conn).Watch(ctx, &grpc_health_v1.HealthCheckRequest{Service: service})
+	if err != nil {
+		return errors.Wrapf(err, "cannot watch health of service %q", service)
+	}
+	for {
+		res, err := stream.Recv()
+		if err != nil {
+			return errors.Wrapf(err, "health watch of service %q", service)
+		}
+		if res.Status == grpc_health_v1.HealthCheckResponse_SERVING {
+			status(service, nil)
+		} else {
+			status(service, errors.Errorf("service %q is %s", service, res.Status))
+		}
+	}
+}
+
+func (c *healthChecker) services() []string {
+	if len(c.config.Services) == 0 {
+		return []string{""} // whole server
+	}
+	return c.config.Services
+}
+
+func (c *healthChecker) report(err error, healthyThreshold, unhealthyThreshold int32) {
+	if !c.health.report(c.instance, err, healthyThreshold, unhealthyThreshold) {
+		return
+	}
+	state, _ := c.health.Get(c.instance)
+	if state.Healthy {
+		metrics.InstanceUp.WithLabelValues(c.instance).Set(1)
+		c.log.Info("instance %s is healthy", c.instance)
+	} else {
+		metrics.InstanceUp.WithLabelValues(c.instance).Set(0)
+		c.log.Warn("instance %s is unhealthy: %s", c.instance, state.Error)
+	}
+}
+
+func durationOr(d, def time.Duration) time.Duration {
+	if d <= 0 {
+		return def
+	}
+	return d
+}
+
+func intOr(v, def int) int {
+	if v <= 0 {
+		return def
+	}
+	return v
+}
@@ -0,0 +1,167 @@
+package services
+
+import (
+	"context"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health"
+	"google.golang.org/grpc/health/grpc_health_v1"
+	"microservice/app"
+	"microservice/domain"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestInstancesHealthThresholds(t *testing.T) {
+	h := NewInstancesHealth()
+	failed := errors.New("failed")
+
+	require.True(t, h.Healthy("a"), "instance without checks should be healthy")
+
+	// First result is applied at once
+	require.True(t, h.report("a", failed, 2, 3))
+	require.False(t, h.Healthy("a"), "first failure should make instance unhealthy")
+	require.False(t, h.report("a", nil, 2, 3))
+	require.False(t, h.Healthy("a"), "one success is below healthy threshold")
+	require.True(t, h.report("a", nil, 2, 3))
+	require.True(t, h.Healthy("a"), "second success should make instance healthy")
+	for i := 0; i < 2; i++ {
+		require.False(t, h.report("a", failed, 2, 3))
+		require.True(t, h.Healthy("a"), "failure %d is below unhealthy threshold", i+1)
+	}
+	require.False(t, h.report("a", nil, 2, 3))
+	require.True(t, h.Healthy("a"), "success should keep instance healthy")
+	for i := 0; i < 3; i++ {
+		h.report("a", failed, 2, 3)
+	}
+	require.False(t, h.Healthy("a"), "three failures should make instance unhealthy")
+	state, _ := h.Get("a")
+	require.Equal(t, "failed", state.Error)
+
+	h.remove("a")
+	require.True(t, h.Healthy("a"), "removed instance should be healthy")
+}
+
+func TestValidateHealthCheck(t *testing.T) {
+	valid := []domain.InstanceHealthCheck{
+		{},
+		{Protocol: domain.HealthCheckPing, IntervalMs: 1000},
+		{Protocol: domain.HealthCheckGrpc, Services: []string{"a"}, Watch: true},
+	}
+	for _, item := range valid {
+		require.NoError(t, ValidateHealthCheck(&item), "%+v", item)
+	}
+
+	invalid := []domain.InstanceHealthCheck{
+		{Protocol: "http"},
+		{Protocol: domain.HealthCheckPing, Watch: true},
+		{Protocol: domain.HealthCheckGrpc, TimeoutMs: -1},
+	}
+	for _, item := range invalid {
+		require.Error(t, ValidateHealthCheck(&item), "%+v should be invalid", item)
+	}
+}
+
+func newTestHealthServer(t *testing.T) (*health.Server, *grpc.ClientConn, *grpc.Server) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	healthServer := health.NewServer()
+	server := grpc.NewServer()
+	grpc_health_v1.RegisterHealthServer(server, healthServer)
+	go server.Serve(listener)
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.Dial(listener.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	return healthServer, conn, server
+}
+
+func newTestHealthChecker(conn *grpc.ClientConn, config domain.InstanceHealthCheck) *healthChecker {
+	return &healthChecker{
+		log:      app.NewDefaultLogger(logrus.New()),
+		instance: "test",
+		config:   healthCheckConfig(config),
+		health:   NewInstancesHealth(),
+		conn: func(ctx context.Context) (*grpc.ClientConn, error) {
+			return conn, nil
+		},
+	}
+}
+
+func TestHealthCheckerGrpcCheck(t *testing.T) {
+	healthServer, conn, _ := newTestHealthServer(t)
+	healthServer.SetServingStatus("users", grpc_health_v1.HealthCheckResponse_SERVING)
+
+	checker := newTestHealthChecker(conn, domain.InstanceHealthCheck{
+		Protocol: domain.HealthCheckGrpc,
+		Services: []string{"users"},
+	})
+	require.NoError(t, checker.check(context.Background()))
+
+	healthServer.SetServingStatus("users", grpc_health_v1.HealthCheckResponse_NOT_SERVING)
+	require.Error(t, checker.check(context.Background()), "not serving service should fail check")
+
+	checker.config.Services = []string{"unknown"}
+	require.Error(t, checker.check(context.Background()), "unknown service should fail check")
+}
+
+func TestHealthCheckerGrpcWatch(t *testing.T) {
+	healthServer, conn, _ := newTestHealthServer(t)
+	healthServer.SetServingStatus("users", grpc_health_v1.HealthCheckResponse_SERVING)
+
+	checker := newTestHealthChecker(conn, domain.InstanceHealthCheck{
+		Protocol: domain.HealthCheckGrpc,
+		Services: []string{"users"},
+		Watch:    true,
+	})
+	checker.start()
+	defer checker.stop()
+
+	waitHealth(t, checker, func(state InstanceHealth) bool { return state.Healthy })
+	healthServer.SetServingStatus("users", grpc_health_v1.HealthCheckResponse_NOT_SERVING)
+	waitHealth(t, checker, func(state InstanceHealth) bool { return !state.Healthy })
+	healthServer.SetServingStatus("users", grpc_health_v1.HealthCheckResponse_SERVING)
+	waitHealth(t, checker, func(state InstanceHealth) bool { return state.Healthy })
+}
+
+func TestHealthCheckerGrpcWatchDropped(t *testing.T) {
+	healthServer, conn, server := newTestHealthServer(t)
+	healthServer.SetServingStatus("users", grpc_health_v1.HealthCheckResponse_SERVING)
+
+	checker := newTestHealthChecker(conn, domain.InstanceHealthCheck{
+		Protocol:           domain.HealthCheckGrpc,
+		Services:           []string{"users"},
+		Watch:              true,
+		IntervalMs:         200,
+		TimeoutMs:          100,
+		UnhealthyThreshold: 3,
+	})
+	checker.start()
+	defer checker.stop()
+
+	waitHealth(t, checker, func(state InstanceHealth) bool { return state.Healthy })
+	server.Stop()
+
+	// Broken stream is one failed check, instance stays healthy until threshold
+	state := waitHealth(t, checker, func(state InstanceHealth) bool { return state.failures > 0 })
+	require.True(t, state.Healthy, "instance is unhealthy after %d failures", state.failures)
+	require.Less(t, state.failures, int32(3))
+	state = waitHealth(t, checker, func(state InstanceHealth) bool { return !state.Healthy })
+	require.Equal(t, int32(3), state.failures)
+}
+
+// waitHealth waits until state of checked instance is ok
+func waitHealth(t *testing.T, checker *healthChecker, ok func(state InstanceHealth) bool) InstanceHealth {
+	var reached InstanceHealth
+	require.Eventually(t, func() bool {
+		state, found := checker.health.Get("test")
+		reached = state
+		return found && ok(state)
+	}, 5*time.Second, 10*time.Millisecond, "instance health state is not reached")
+	return reached
+}
@@ -0,0 +1,103 @@
+package services
+
+import (
+	"github.com/pkg/errors"
+	"sync"
+	"time"
+)
+
+// ErrInstanceUnavailable is returned for calls to instance which failed active health checks
+var ErrInstanceUnavailable = errors.New("instance is unavailable")
+
+// InstanceHealth is state of instance by active health checks
+type InstanceHealth struct {
+	Healthy   bool
+	Error     string
+	CheckedAt time.Time
+
+	// Consecutive results of the same kind
+	successes int32
+	failures  int32
+}
+
+// InstancesHealth keeps states of instances with active health checks,
+// instances without checks (or not checked yet) are treated as healthy
+type InstancesHealth struct {
+	mu     sync.RWMutex
+	states map[string]*InstanceHealth
+}
+
+func NewInstancesHealth() *InstancesHealth {
+	return &InstancesHealth{
+		states: map[string]*InstanceHealth{},
+	}
+}
+
+// Healthy is false only for instance which is known as unhealthy
+func (h *InstancesHealth) Healthy(instance string) bool {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	state, ok := h.states[instance]
+	return !ok || state.Healthy
+}
+
+// Get returns copy of instance`s state
+func (h *InstancesHealth) Get(instance string) (InstanceHealth, bool) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	state, ok := h.states[instance]
+	if !ok {
+		return InstanceHealth{}, false
+	}
+	return *state, true
+}
+
+// report applies check result with thresholds, returns true if state is changed
+func (h *InstancesHealth) report(instance string, err error, healthyThreshold, unhealthyThreshold int32) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	state, ok := h.states[instance]
+	if !ok {
+		// First result sets state at once
+		h.states[instance] = &InstanceHealth{
+			Healthy:   err == nil,
+			Error:     errorText(err),
+			CheckedAt: time.Now(),
+		}
+		return true
+	}
+
+	state.CheckedAt = time.Now()
+	state.Error = errorText(err)
+	if err == nil {
+		state.successes++
+		state.failures = 0
+		if !state.Healthy && state.successes >= healthyThreshold {
+			state.Healthy = true
+			return true
+		}
+	} else {
+		state.failures++
+		state.successes = 0
+		if state.Healthy && state.failures >= unhealthyThreshold {
+			state.Healthy = false
+			return true
+		}
+	}
+	return false
+}
+
+// remove forgets instance (its check is removed)
+func (h *InstancesHealth) remove(instance string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	delete(h.states, instance)
+}
+
+func errorText(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
@@ -2,20 +2,62 @@ package services
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"github.com/pkg/errors"
 	"github.com/spf13/viper"
+	"golang.org/x/sync/singleflight"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/dynamicpb"
+	"io"
 	"microservice/app"
+	"microservice/app/accesslog"
 	"microservice/app/core"
+	"microservice/app/metrics"
+	"microservice/app/tracing"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
 )
 
+// ErrResponseTooLarge is returned if instance`s response exceeds ProtoCall.MaxResponseSize
+var ErrResponseTooLarge = errors.New("instance response is too large")
+
+// ErrInvalidMessage is returned by ProtoCallStream.Send for client`s message which cannot be decoded
+var ErrInvalidMessage = errors.New("invalid message")
+
 type ProtoCall struct {
 	Instance string
 	Service  string
 	Method   string
 	Data     []byte
 	Headers  map[string]string
+
+	// MaxResponseSize in bytes, 0 - no limit
+	MaxResponseSize int64
+
+	// Codecs of Data and response (JSON if nil)
+	InputCodec  app.ProtoCodec
+	OutputCodec app.ProtoCodec
+
+	// Params are query and path parameters set over Data
+	Params url.Values
+
+	// Coalesce shares one instance call between identical concurrent calls
+	Coalesce bool
+
+	// FieldMask keeps only these response fields (nil - all),
+	// it is passed into request`s read_mask (field_mask) field too
+	FieldMask []string
+
+	// IgnoreHealthCheck calls instance which is unhealthy (health checks themselves)
+	IgnoreHealthCheck bool
 }
 
 // ProtoCallerService делает вызов к микросервисам и парсит запрос
@@ -27,29 +69,65 @@ type ProtoCallerService struct {
 
 	// Описание api сервисов
 	protoRegistry *app.ProtoRegistry
+
+	// Одинаковые одновременные вызовы
+	calls singleflight.Group
+
+	// Результаты активных проверок инстансов
+	health *InstancesHealth
 }
 
 func NewProtoCallerService(log core.Logger,
 	protoRegistry *app.ProtoRegistry,
-	endpointService *EndpointConnectionService) *ProtoCallerService {
+	endpointService *EndpointConnectionService,
+	health *InstancesHealth) *ProtoCallerService {
 	return &ProtoCallerService{
 		log:             log,
 		protoRegistry:   protoRegistry,
 		endpointService: endpointService,
+		health:          health,
 	}
 }
 
-func (s *ProtoCallerService) Call(ctx context.Context, call ProtoCall) ([]byte, error) {
+// preparedCall has found method, connection and decoded request
+type preparedCall struct {
+	ProtoCall
+	method  *app.ProtoMethod
+	conn    *grpc.ClientConn
+	request *dynamicpb.Message
+	opts    []grpc.CallOption
+}
 
-	// Find in proto registry
-	protoInstance := s.protoRegistry.Instance(call.Instance)
+// Method finds method in proto registry
+func (s *ProtoCallerService) Method(instance, service, method string) (*app.ProtoMethod, error) {
+	protoInstance := s.protoRegistry.Instance(instance)
 	if protoInstance == nil {
-		return nil, errors.Errorf("cannot find instance %s", call.Instance)
+		return nil, errors.Errorf("cannot find instance %s", instance)
+	}
+
+	serviceObj := protoInstance.Service(service)
+	if serviceObj == nil {
+		return nil, errors.Errorf("cannot find service %s in instance %s", service, instance)
+	}
+
+	methodObj := serviceObj.Method(method)
+	if methodObj == nil {
+		return nil, errors.Errorf("cannot find method %s in service %s", method, service)
+	}
+	return methodObj, nil
+}
+
+func (s *ProtoCallerService) prepare(ctx context.Context, call ProtoCall) (*preparedCall, error) {
+
+	// Unhealthy instance is not called
+	if !call.IgnoreHealthCheck && !s.health.Healthy(call.Instance) {
+		return nil, errors.Wrapf(ErrInstanceUnavailable, "cannot call %s", call.Instance)
 	}
 
-	service := protoInstance.Service(call.Service)
-	if service == nil {
-		return nil, errors.Errorf("cannot find service %s in instance %s", call.Service, call.Instance)
+	// Find in proto registry
+	method, err := s.Method(call.Instance, call.Service, call.Method)
+	if err != nil {
+		return nil, err
 	}
 
 	// Get connection
@@ -59,8 +137,11 @@ func (s *ProtoCallerService) Call(ctx context.Context, call ProtoCall) ([]byte,
 	}
 
 	// Call
-	if call.Data == nil {
-		call.Data = []byte("{}")
+	if call.InputCodec == nil {
+		call.InputCodec = app.JsonCodec()
+	}
+	if call.OutputCodec == nil {
+		call.OutputCodec = app.JsonCodec()
 	}
 	if call.Headers == nil {
 		call.Headers = make(map[string]string)
@@ -70,15 +151,218 @@ func (s *ProtoCallerService) Call(ctx context.Context, call ProtoCall) ([]byte,
 	appSecret := viper.GetString("app.secret")
 	call.Headers["Authorization"] = appSecret
 
-	res, err := service.CallJsonWithContext(conn, ctx, call.Method, call.Data, call.Headers)
+	var opts []grpc.CallOption
+	if call.MaxResponseSize > 0 {
+		opts = append(opts, grpc.MaxCallRecvMsgSize(int(call.MaxResponseSize)))
+	}
+
+	return &preparedCall{
+		ProtoCall: call,
+		method:    method,
+		conn:      conn,
+		opts:      opts,
+	}, nil
+}
+
+// decodeRequest makes request message from Data and Params
+func (s *ProtoCallerService) decodeRequest(call *preparedCall) error {
+	request, err := call.method.NewRequest(call.Data, call.InputCodec)
+	if err != nil {
+		return errors.Wrapf(err, "cannot make request for %s.%s.%s", call.Instance, call.Service, call.Method)
+	}
+	if err = app.SetParams(request, call.Params); err != nil {
+		return errors.Wrapf(err, "cannot set params for %s.%s.%s", call.Instance, call.Service, call.Method)
+	}
+	if errs := app.ValidateMessage(request); len(errs) > 0 {
+		return errors.Wrapf(errs, "invalid request for %s.%s.%s", call.Instance, call.Service, call.Method)
+	}
+
+	// Instance can also do less work
+	if len(call.FieldMask) > 0 {
+		paths, err := app.FieldMaskProtoPaths(call.method.Response(), call.FieldMask)
+		if err != nil {
+			return errors.Wrapf(err, "invalid field mask for %s.%s.%s", call.Instance, call.Service, call.Method)
+		}
+		app.SetRequestFieldMask(request, paths)
+	}
+	call.request = request
+	return nil
+}
+
+func (s *ProtoCallerService) Call(ctx context.Context, call ProtoCall) ([]byte, error) {
+	prepared, err := s.prepare(ctx, call)
+	if err != nil {
+		return nil, err
+	}
+	if err = s.decodeRequest(prepared); err != nil {
+		return nil, err
+	}
+	call = prepared.ProtoCall
+
+	start := time.Now()
+	response, err := s.invoke(ctx, prepared)
+	observeCall(ctx, call.Instance, call.Service, call.Method, start, err)
+	if err != nil {
+		return nil, s.wrapInstanceError(call, err)
+	}
+	if call.Coalesce && len(call.FieldMask) > 0 {
+		// Response is shared with other callers
+		response = proto.Clone(response).(*dynamicpb.Message)
+	}
+
+	res, err := s.marshal(call, response)
+	if err != nil {
+		return nil, err
+	}
+	if call.OutputCodec.ContentType() == app.ContentTypeJson {
+		s.log.WithContext(ctx).Debug(fmt.Sprintf("response from %s: %s", call.Instance, (string)(res)))
+	}
+
+	return res, nil
+}
+
+// invoke calls instance, coalesced calls wait for the same response message
+func (s *ProtoCallerService) invoke(ctx context.Context, call *preparedCall) (*dynamicpb.Message, error) {
+	if !call.Coalesce {
+		return call.method.InvokeWithContext(ctx, call.conn, call.request, call.Headers, call.opts...)
+	}
+
+	key, err := coalesceKey(call)
+	if err != nil {
+		return nil, err
+	}
+	results := s.calls.DoChan(key, func() (interface{}, error) {
+		// Shared call should not be canceled by the first client
+		timeout := viper.GetDuration("rest.coalesce_timeout")
+		if timeout <= 0 {
+			timeout = 30 * time.Second
+		}
+		detached := core.WithRequestId(tracing.Detach(ctx), core.RequestId(ctx))
+		ctx, cancel := context.WithTimeout(detached, timeout)
+		defer cancel()
+		return call.method.InvokeWithContext(ctx, call.conn, call.request, call.Headers, call.opts...)
+	})
+
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case res := <-results:
+		if res.Shared {
+			s.log.WithContext(ctx).Debug("coalesced call to %s.%s.%s", call.Instance, call.Service, call.Method)
+		}
+		if res.Err != nil {
+			return nil, res.Err
+		}
+		return res.Val.(*dynamicpb.Message), nil
+	}
+}
+
+// coalesceKey identifies call by method, headers, limits and request bytes
+func coalesceKey(call *preparedCall) (string, error) {
+	data, err := proto.MarshalOptions{Deterministic: true}.Marshal(call.request)
+	if err != nil {
+		return "", errors.Wrapf(err, "cannot marshal request of %s.%s.%s", call.Instance, call.Service, call.Method)
+	}
+
+	headers := make([]string, 0, len(call.Headers))
+	for k, v := range call.Headers {
+		headers = append(headers, k+"="+v)
+	}
+	sort.Strings(headers)
+
+	sum := sha256.Sum256(data)
+	return fmt.Sprintf("%s/%s/%s|%s|%d|%s", call.Instance, call.Service, call.Method,
+		strings.Join(headers, "&"), call.MaxResponseSize, hex.EncodeToString(sum[:])), nil
+}
+
+// Stream calls server-streaming method and sends each response message
+func (s *ProtoCallerService) Stream(ctx context.Context, call ProtoCall, send func([]byte) error) error {
+	prepared, err := s.prepare(ctx, call)
+	if err != nil {
+		return err
+	}
+	if err = s.decodeRequest(prepared); err != nil {
+		return err
+	}
+	call = prepared.ProtoCall
+
+	start := time.Now()
+	err = prepared.method.StreamWithContext(ctx, prepared.conn, prepared.request, call.Headers, func(response *dynamicpb.Message) error {
+		res, err := s.marshal(call, response)
+		if err != nil {
+			return err
+		}
+		return send(res)
+	}, prepared.opts...)
+	observeCall(ctx, call.Instance, call.Service, call.Method, start, err)
+	if err != nil {
+		return s.wrapInstanceError(call, err)
+	}
+	return nil
+}
+
+// OpenStream opens client-streaming or bidirectional stream (Data and Params are not used)
+func (s *ProtoCallerService) OpenStream(ctx context.Context, call ProtoCall) (*ProtoCallStream, error) {
+	prepared, err := s.prepare(ctx, call)
+	if err != nil {
+		return nil, err
+	}
+
+	stream, err := prepared.method.OpenStream(ctx, prepared.conn, prepared.Headers, prepared.opts...)
 	if err != nil {
-		return nil, errors.Wrapf(err, "in instance error (%s.%s.%s)", call.Instance, call.Service, call.Method)
+		return nil, s.wrapInstanceError(prepared.ProtoCall, err)
+	}
+	return &ProtoCallStream{
+		service: s,
+		call:    prepared,
+		stream:  stream,
+	}, nil
+}
+
+func (s *ProtoCallerService) marshal(call ProtoCall, response *dynamicpb.Message) ([]byte, error) {
+	if len(call.FieldMask) > 0 {
+		paths := call.FieldMask
+		if response.Descriptor().Fields().ByName("status") != nil {
+			// Status is required for each response
+			paths = append(paths[:len(paths):len(paths)], "status")
+		}
+		if err := app.ApplyFieldMask(response, paths); err != nil {
+			return nil, errors.Wrapf(err, "cannot apply field mask to %s.%s.%s", call.Instance, call.Service, call.Method)
+		}
 	}
-	s.log.Debug(fmt.Sprintf("response from %s: %s", call.Instance, (string)(res)))
 
+	res, err := call.OutputCodec.Marshal(response)
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot marshal response of %s.%s.%s", call.Instance, call.Service, call.Method)
+	}
+	if call.MaxResponseSize > 0 && int64(len(res)) > call.MaxResponseSize {
+		return nil, errors.Wrapf(ErrResponseTooLarge, "in instance error (%s.%s.%s)", call.Instance, call.Service, call.Method)
+	}
 	return res, nil
 }
 
+// observeCall records instance call metrics and access log latency (code is gRPC status code)
+func observeCall(ctx context.Context, instance, service, method string, start time.Time, err error) {
+	code := status.Code(errors.Cause(err)).String()
+	metrics.UpstreamCalls.WithLabelValues(instance, service, method, code).Inc()
+	metrics.UpstreamDuration.WithLabelValues(instance, service, method).Observe(metrics.Since(start))
+	accesslog.AddUpstream(ctx, time.Since(start), code)
+}
+
+// isMessageTooLarge is true for grpc client`s own "received message larger than max" error,
+// ResourceExhausted of instance (rate limits, quotas) is passed as is
+func isMessageTooLarge(err error) bool {
+	st, ok := status.FromError(errors.Cause(err))
+	return ok && st.Code() == codes.ResourceExhausted && strings.Contains(st.Message(), "larger than max")
+}
+
+func (s *ProtoCallerService) wrapInstanceError(call ProtoCall, err error) error {
+	if call.MaxResponseSize > 0 && isMessageTooLarge(err) {
+		err = ErrResponseTooLarge
+	}
+	return errors.Wrapf(err, "in instance error (%s.%s.%s)", call.Instance, call.Service, call.Method)
+}
+
 func (s *ProtoCallerService) CallAndParse(ctx context.Context, call ProtoCall, out interface{}) ([]byte, error) {
 	response, err := s.Call(ctx, call)
 	if err != nil {
@@ -92,3 +376,37 @@ func (s *ProtoCallerService) CallAndParse(ctx context.Context, call ProtoCall, o
 	}
 	return response, nil
 }
+
+// ProtoCallStream sends and receives messages encoded by call`s codecs
+type ProtoCallStream struct {
+	service *ProtoCallerService
+	call    *preparedCall
+	stream  *app.ProtoStream
+}
+
+func (st *ProtoCallStream) Send(data []byte) error {
+	request, err := st.call.method.NewRequest(data, st.call.InputCodec)
+	if err != nil {
+		return errors.Wrap(ErrInvalidMessage, err.Error())
+	}
+	if errs := app.ValidateMessage(request); len(errs) > 0 {
+		return errs
+	}
+	return st.stream.Send(request)
+}
+
+// Recv returns io.EOF when stream is finished
+func (st *ProtoCallStream) Recv() ([]byte, error) {
+	response, err := st.stream.Recv()
+	if err == io.EOF {
+		return nil, err
+	}
+	if err != nil {
+		return nil, st.service.wrapInstanceError(st.call.ProtoCall, err)
+	}
+	return st.service.marshal(st.call.ProtoCall, response)
+}
+
+func (st *ProtoCallStream) CloseSend() error {
+	return st.stream.CloseSend()
+}
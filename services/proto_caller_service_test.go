@@ -0,0 +1,131 @@
+package services
+
+import (
+	"context"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"microservice/app"
+	"net"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestWrapInstanceErrorTooLarge(t *testing.T) {
+	s := &ProtoCallerService{}
+	call := ProtoCall{Instance: "i", Service: "s", Method: "m", MaxResponseSize: 10}
+
+	tooLarge := status.Error(codes.ResourceExhausted, "grpc: received message larger than max (20 vs. 10)")
+	require.ErrorIs(t, s.wrapInstanceError(call, tooLarge), ErrResponseTooLarge)
+
+	quota := status.Error(codes.ResourceExhausted, "quota exceeded")
+	err := s.wrapInstanceError(call, quota)
+	require.NotErrorIs(t, err, ErrResponseTooLarge, "instance`s ResourceExhausted should be passed as is")
+	st, _ := status.FromError(errors.Cause(err))
+	require.Equal(t, "quota exceeded", st.Message())
+}
+
+// slowHealthServer counts Check calls by user_id and answers after release
+type slowHealthServer struct {
+	grpc_health_v1.UnimplementedHealthServer
+	release chan struct{}
+
+	mu    sync.Mutex
+	calls map[string]int
+}
+
+func (h *slowHealthServer) Check(ctx context.Context, _ *grpc_health_v1.HealthCheckRequest) (*grpc_health_v1.HealthCheckResponse, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	h.mu.Lock()
+	h.calls[md.Get("user_id")[0]]++
+	h.mu.Unlock()
+
+	<-h.release
+	return &grpc_health_v1.HealthCheckResponse{Status: grpc_health_v1.HealthCheckResponse_SERVING}, nil
+}
+
+func (h *slowHealthServer) total() int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	total := 0
+	for _, n := range h.calls {
+		total += n
+	}
+	return total
+}
+
+func TestInvokeCoalescesIdenticalCalls(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	backend := &slowHealthServer{release: make(chan struct{}), calls: map[string]int{}}
+	server := grpc.NewServer()
+	grpc_health_v1.RegisterHealthServer(server, backend)
+	go server.Serve(listener)
+	defer server.Stop()
+
+	conn, err := grpc.Dial(listener.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	defer conn.Close()
+
+	service, err := app.MakeProtoService(grpc_health_v1.File_grpc_health_v1_health_proto.Services().ByName("Health"))
+	require.NoError(t, err)
+	method := service.Method("Check")
+	s := &ProtoCallerService{log: app.NewDefaultLogger(logrus.New())}
+
+	call := func(userId string) (*preparedCall, error) {
+		request, err := method.NewRequest([]byte(`{"service":"users"}`), app.JsonCodec())
+		return &preparedCall{
+			ProtoCall: ProtoCall{
+				Instance: "test",
+				Service:  "Health",
+				Method:   "Check",
+				Headers:  map[string]string{"user_id": userId},
+				Coalesce: true,
+			},
+			method:  method,
+			conn:    conn,
+			request: request,
+		}, err
+	}
+
+	// 10 identical calls of each of 2 users
+	const perUser = 10
+	var wg sync.WaitGroup
+	errs := make(chan error, 2*perUser)
+	for _, userId := range []string{"1", "2"} {
+		for i := 0; i < perUser; i++ {
+			prepared, err := call(userId)
+			require.NoError(t, err)
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				response, err := s.invoke(context.Background(), prepared)
+				if err == nil && response == nil {
+					err = errors.New("empty response")
+				}
+				errs <- err
+			}()
+		}
+	}
+
+	// Instance is answering while all calls wait
+	require.Eventually(t, func() bool { return backend.total() >= 2 }, 5*time.Second, 5*time.Millisecond)
+	time.Sleep(100 * time.Millisecond) // other calls join the first ones
+	close(backend.release)
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		require.NoError(t, err)
+	}
+	backend.mu.Lock()
+	defer backend.mu.Unlock()
+	require.Equal(t, map[string]int{"1": 1, "2": 1}, backend.calls, "expected one instance call per user")
+}
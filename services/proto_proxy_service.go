@@ -0,0 +1,141 @@
+package services
+
+import (
+	"context"
+	"github.com/pkg/errors"
+	"github.com/spf13/viper"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"io"
+	"microservice/app"
+	"microservice/app/core"
+	"strings"
+	"time"
+)
+
+// gatewayMetadata are keys set only by gateway, client`s values are dropped
+// (user_id of guest route would be trusted by instance), request id of ctx is added by endpoint`s interceptor
+var gatewayMetadata = []string{"user_id", "authorization", strings.ToLower(core.RequestIdHeader)}
+
+// ProtoProxyService forwards native gRPC frames to instances without decoding
+type ProtoProxyService struct {
+	log             core.Logger
+	endpointService *EndpointConnectionService
+	health          *InstancesHealth
+}
+
+func NewProtoProxyService(log core.Logger,
+	endpointService *EndpointConnectionService,
+	health *InstancesHealth) *ProtoProxyService {
+	return &ProtoProxyService{
+		log:             log,
+		endpointService: endpointService,
+		health:          health,
+	}
+}
+
+// Proxy forwards server stream to instance`s method, headers replace client`s metadata
+func (s *ProtoProxyService) Proxy(ctx context.Context, instance, fullMethod string, serverStream grpc.ServerStream, headers map[string]string) (err error) {
+	service, method, _ := strings.Cut(strings.TrimPrefix(fullMethod, "/"), "/")
+	start := time.Now()
+	defer func() {
+		observeCall(ctx, instance, service, method, start, err)
+	}()
+
+	if !s.health.Healthy(instance) {
+		return status.Errorf(codes.Unavailable, "instance %s is unavailable", instance)
+	}
+
+	conn, err := s.endpointService.GetConn(ctx, instance)
+	if err != nil {
+		return errors.Wrapf(err, "cannot get endpoint client for %s", instance)
+	}
+
+	// Client`s metadata with gateway`s auth
+	md, _ := metadata.FromIncomingContext(ctx)
+	md = md.Copy()
+	for _, k := range gatewayMetadata {
+		delete(md, k)
+	}
+	for k, v := range headers {
+		md.Set(k, v)
+	}
+	md.Set("authorization", viper.GetString("app.secret"))
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	ctx = metadata.NewOutgoingContext(ctx, md)
+
+	desc := &grpc.StreamDesc{
+		ServerStreams: true,
+		ClientStreams: true,
+	}
+	clientStream, err := conn.NewStream(ctx, desc, fullMethod, grpc.ForceCodec(app.RawCodec()))
+	if err != nil {
+		return err
+	}
+
+	// Client -> instance
+	sendErr := make(chan error, 1)
+	go func() {
+		frame := &app.RawFrame{}
+		for {
+			if err := serverStream.RecvMsg(frame); err != nil {
+				if err == io.EOF {
+					err = clientStream.CloseSend()
+				}
+				sendErr <- err
+				return
+			}
+			if err := clientStream.SendMsg(frame); err != nil {
+				sendErr <- err
+				return
+			}
+		}
+	}()
+
+	// Instance -> client
+	recvErr := make(chan error, 1)
+	go func() {
+		header, err := clientStream.Header()
+		if err != nil {
+			recvErr <- err
+			return
+		}
+		if err = serverStream.SendHeader(header); err != nil {
+			recvErr <- err
+			return
+		}
+
+		frame := &app.RawFrame{}
+		for {
+			if err := clientStream.RecvMsg(frame); err != nil {
+				recvErr <- err
+				return
+			}
+			if err := serverStream.SendMsg(frame); err != nil {
+				recvErr <- err
+				return
+			}
+		}
+	}()
+
+	for {
+		select {
+		case err := <-sendErr:
+			if err != nil {
+				// Client failed - instance call is canceled
+				return err
+			}
+			sendErr = nil // client finished sending, wait for instance
+		case err := <-recvErr:
+			serverStream.SetTrailer(clientStream.Trailer())
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+	}
+}
@@ -0,0 +1,131 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"io"
+	"microservice/app"
+	"microservice/domain"
+	"net"
+	"testing"
+)
+
+type testInstancesRepo struct {
+	domain.InstancesRepository
+	endpoint string
+}
+
+func (r *testInstancesRepo) GetByFolder(_ context.Context, name string) (*domain.Instance, error) {
+	return &domain.Instance{Name: name, Folder: name, Endpoint: r.endpoint}, nil
+}
+
+// serveTestGrpc serves handler of any method with raw codec
+func serveTestGrpc(t *testing.T, handler grpc.StreamHandler) string {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	server := grpc.NewServer(
+		grpc.ForceServerCodec(app.RawCodec()),
+		grpc.UnknownServiceHandler(handler),
+	)
+	go server.Serve(listener)
+	t.Cleanup(server.Stop)
+	return listener.Addr().String()
+}
+
+// newTestProxy runs gateway with proxy to echo instance, instance sends back metadata user_id with each message
+func newTestProxy(t *testing.T, headers map[string]string) *grpc.ClientConn {
+	instance := serveTestGrpc(t, func(srv interface{}, stream grpc.ServerStream) error {
+		md, _ := metadata.FromIncomingContext(stream.Context())
+		userId := fmt.Sprint(md.Get("user_id"))
+		for {
+			frame := &app.RawFrame{}
+			if err := stream.RecvMsg(frame); err != nil {
+				if err == io.EOF {
+					return nil
+				}
+				return err
+			}
+			frame.Data = append(frame.Data, userId...)
+			if err := stream.SendMsg(frame); err != nil {
+				return err
+			}
+		}
+	})
+
+	log := app.NewDefaultLogger(logrus.New())
+	proxy := NewProtoProxyService(log,
+		NewEndpointConnectionService(log, &testInstancesRepo{endpoint: instance}),
+		NewInstancesHealth())
+	gateway := serveTestGrpc(t, func(srv interface{}, stream grpc.ServerStream) error {
+		method, _ := grpc.MethodFromServerStream(stream)
+		return proxy.Proxy(stream.Context(), "echo", method, stream, headers)
+	})
+
+	conn, err := grpc.Dial(gateway, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+func openTestStream(t *testing.T, conn *grpc.ClientConn, ctx context.Context) grpc.ClientStream {
+	stream, err := conn.NewStream(ctx, &grpc.StreamDesc{ServerStreams: true, ClientStreams: true},
+		"/test.Echo/Chat", grpc.ForceCodec(app.RawCodec()))
+	require.NoError(t, err)
+	return stream
+}
+
+func TestProxyStreamsMessages(t *testing.T) {
+	conn := newTestProxy(t, nil)
+	stream := openTestStream(t, conn, context.Background())
+
+	// Messages of different sizes are sent without waiting for responses
+	const count = 200
+	expected := make([][]byte, count)
+	for i := range expected {
+		expected[i] = bytes.Repeat([]byte{byte('a' + i%26)}, 1+(i*977)%5000)
+	}
+	go func() {
+		for _, data := range expected {
+			if err := stream.SendMsg(&app.RawFrame{Data: data}); err != nil {
+				return
+			}
+		}
+		_ = stream.CloseSend()
+	}()
+
+	for i, data := range expected {
+		frame := &app.RawFrame{}
+		require.NoError(t, stream.RecvMsg(frame), "message %d", i)
+		require.True(t, bytes.Equal(frame.Data, append(data, "[]"...)), "message %d is corrupted", i)
+	}
+	require.Equal(t, io.EOF, stream.RecvMsg(&app.RawFrame{}), "expected end of stream")
+}
+
+func TestProxyDropsClientGatewayMetadata(t *testing.T) {
+	tests := []struct {
+		name     string
+		headers  map[string]string
+		expected string
+	}{
+		{"guest route", nil, "[]"},
+		{"user route", map[string]string{"user_id": "7"}, "[7]"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			conn := newTestProxy(t, test.headers)
+			ctx := metadata.AppendToOutgoingContext(context.Background(), "user_id", "1")
+			stream := openTestStream(t, conn, ctx)
+
+			require.NoError(t, stream.SendMsg(&app.RawFrame{Data: []byte("x")}))
+			frame := &app.RawFrame{}
+			require.NoError(t, stream.RecvMsg(frame))
+			require.Equal(t, test.expected, string(frame.Data[1:]), "user_id got by instance")
+		})
+	}
+}
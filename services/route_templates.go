@@ -0,0 +1,136 @@
+package services
+
+import (
+	"context"
+	"github.com/pkg/errors"
+	"github.com/spf13/viper"
+	"microservice/app/core"
+	"microservice/domain"
+	"microservice/tools"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RouteTemplates keeps compiled routes with path captures (/challenges/{id}) in memory,
+// so misses of exact address lookup don`t scan routes table
+type RouteTemplates struct {
+	log        core.Logger
+	routesRepo domain.RoutesRepository
+
+	mu        sync.RWMutex
+	templates []routeTemplate
+	loaded    bool
+
+	// Failed load is not retried by requests more often than once per loadRetry
+	loadMu     sync.Mutex
+	loadFailed time.Time
+}
+
+type routeTemplate struct {
+	route *domain.Route
+	path  *tools.PathTemplate
+}
+
+// loadRetry is min delay between loads of failed table started by requests
+const loadRetry = time.Second
+
+func NewRouteTemplates(log core.Logger, routesRepo domain.RoutesRepository) *RouteTemplates {
+	return &RouteTemplates{
+		log:        log,
+		routesRepo: routesRepo,
+	}
+}
+
+// Reload replaces table with routes from db
+func (t *RouteTemplates) Reload(ctx context.Context) error {
+	routes, err := t.routesRepo.All(ctx)
+	if err != nil {
+		return errors.Wrap(err, "cannot load routes")
+	}
+
+	var templates []routeTemplate
+	for _, route := range routes {
+		if !tools.IsPathTemplate(route.HttpAddress) {
+			continue
+		}
+		templates = append(templates, routeTemplate{
+			route: route,
+			path:  tools.ParsePathTemplate(route.HttpAddress),
+		})
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.templates = templates
+	t.loaded = true
+	return nil
+}
+
+// Match finds route by template and HTTP method (empty method of route or request - any)
+func (t *RouteTemplates) Match(ctx context.Context, method, address string) (*domain.Route, map[string]string, error) {
+	if err := t.load(ctx); err != nil {
+		return nil, nil, err
+	}
+
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	for _, item := range t.templates {
+		if !MethodMatches(item.route, method) {
+			continue
+		}
+		if params, ok := item.path.Match(address); ok {
+			return item.route, params, nil
+		}
+	}
+	return nil, nil, nil
+}
+
+// Watch reloads table every routes.reload_interval until ctx is done (routes may be changed by other replicas)
+func (t *RouteTemplates) Watch(ctx context.Context) {
+	ticker := time.NewTicker(durationOr(viper.GetDuration("routes.reload_interval"), 30*time.Second))
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if err := t.Reload(ctx); err != nil && ctx.Err() == nil {
+			t.log.ErrorWrap(err, "cannot reload route templates")
+		}
+	}
+}
+
+// load makes first load of table by request
+func (t *RouteTemplates) load(ctx context.Context) error {
+	t.mu.RLock()
+	loaded := t.loaded
+	t.mu.RUnlock()
+	if loaded {
+		return nil
+	}
+
+	t.loadMu.Lock()
+	defer t.loadMu.Unlock()
+
+	t.mu.RLock()
+	loaded = t.loaded
+	t.mu.RUnlock()
+	if loaded {
+		return nil
+	}
+	if time.Since(t.loadFailed) < loadRetry {
+		return errors.New("route templates are not loaded")
+	}
+	if err := t.Reload(ctx); err != nil {
+		t.loadFailed = time.Now()
+		return err
+	}
+	return nil
+}
+
+// MethodMatches checks route`s HTTP method (empty method of route or request - any)
+func MethodMatches(route *domain.Route, method string) bool {
+	return route.HttpMethod == "" || method == "" || strings.EqualFold(route.HttpMethod, method)
+}
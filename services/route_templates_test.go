@@ -0,0 +1,83 @@
+package services
+
+import (
+	"context"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+	"microservice/app"
+	"microservice/domain"
+	"sync/atomic"
+	"testing"
+)
+
+type testRoutesRepo struct {
+	domain.RoutesRepository
+	routes []*domain.Route
+	err    error
+	calls  int32
+}
+
+func (r *testRoutesRepo) All(context.Context) ([]*domain.Route, error) {
+	atomic.AddInt32(&r.calls, 1)
+	return r.routes, r.err
+}
+
+func TestRouteTemplatesMatch(t *testing.T) {
+	repo := &testRoutesRepo{routes: []*domain.Route{
+		{Id: 1, HttpMethod: "GET", HttpAddress: "/challenges/{id}"},
+		{Id: 2, HttpMethod: "POST", HttpAddress: "/challenges/{id}"},
+		{Id: 3, HttpAddress: "/users/{id}/friends"},
+		{Id: 4, HttpAddress: "/static"},
+	}}
+	templates := NewRouteTemplates(app.NewDefaultLogger(logrus.New()), repo)
+	ctx := context.Background()
+
+	tests := []struct {
+		method, address string
+		id              int64
+		params          map[string]string
+	}{
+		{"GET", "/challenges/5", 1, map[string]string{"id": "5"}},
+		{"post", "/challenges/5", 2, map[string]string{"id": "5"}},
+		{"DELETE", "/challenges/5", 0, nil},
+		{"PUT", "/users/7/friends", 3, map[string]string{"id": "7"}},
+		{"GET", "/users//friends", 0, nil},
+		{"GET", "/static", 0, nil}, // exact routes are not in table
+		{"GET", "/unknown", 0, nil},
+	}
+	for _, test := range tests {
+		route, params, err := templates.Match(ctx, test.method, test.address)
+		require.NoError(t, err)
+		if test.id == 0 {
+			require.Nil(t, route, "%s %s", test.method, test.address)
+			continue
+		}
+		require.NotNil(t, route, "%s %s", test.method, test.address)
+		require.Equal(t, test.id, route.Id, "%s %s", test.method, test.address)
+		for k, v := range test.params {
+			require.Equal(t, v, params[k], "%s %s: param %s", test.method, test.address, k)
+		}
+	}
+
+	require.Equal(t, int32(1), repo.calls, "routes should be loaded once")
+}
+
+func TestRouteTemplatesReload(t *testing.T) {
+	repo := &testRoutesRepo{err: errors.New("db is down")}
+	templates := NewRouteTemplates(app.NewDefaultLogger(logrus.New()), repo)
+	ctx := context.Background()
+
+	for i := 0; i < 10; i++ {
+		_, _, err := templates.Match(ctx, "GET", "/a/1")
+		require.Error(t, err)
+	}
+	require.Equal(t, int32(1), repo.calls, "failed load should not be retried at once")
+
+	repo.err = nil
+	repo.routes = []*domain.Route{{Id: 1, HttpAddress: "/a/{id}"}}
+	require.NoError(t, templates.Reload(ctx))
+	route, _, err := templates.Match(ctx, "GET", "/a/1")
+	require.NoError(t, err)
+	require.NotNil(t, route, "route is not matched after reload")
+}
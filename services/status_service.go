@@ -3,64 +3,110 @@ package services
 import (
 	"context"
 	"github.com/pkg/errors"
+	"github.com/spf13/viper"
+	"google.golang.org/grpc"
 	"microservice/app/core"
+	"microservice/app/metrics"
 	"microservice/domain"
+	"reflect"
+	"sync"
+	"time"
 )
 
-// StatusService проверяет статус микросервисов, отправляя им ping периодически
+// StatusService проверяет статус микросервисов: StatusService.Ping статус джобом
+// или активными проверками (grpc.health.v1 / Ping) с настройками инстанса
 type StatusService struct {
-	log           core.Logger
-	instancesRepo domain.InstancesRepository
-	callerService *ProtoCallerService
+	log             core.Logger
+	instancesRepo   domain.InstancesRepository
+	callerService   *ProtoCallerService
+	endpointService *EndpointConnectionService
+	health          *InstancesHealth
 
+	mu     sync.RWMutex
 	cache  map[string]bool
 	errors map[string]string
+
+	// Active health checks by instance name
+	checkersMu sync.Mutex
+	checkers   map[string]*healthChecker
 }
 
 func NewStatusService(log core.Logger,
 	instancesRepo domain.InstancesRepository,
-	callerService *ProtoCallerService) *StatusService {
+	callerService *ProtoCallerService,
+	endpointService *EndpointConnectionService,
+	health *InstancesHealth) *StatusService {
 	return &StatusService{
-		log:           log,
-		instancesRepo: instancesRepo,
-		callerService: callerService,
+		log:             log,
+		instancesRepo:   instancesRepo,
+		callerService:   callerService,
+		endpointService: endpointService,
+		health:          health,
 
 		cache: map[string]bool{},
 
 		// Причины недоступности
 		errors: map[string]string{},
+
+		checkers: map[string]*healthChecker{},
 	}
 }
 
-// CheckAll checks every service for itself status
+// CheckAll checks every service without active health check for itself status
 func (s *StatusService) CheckAll(ctx context.Context) error {
 	items, err := s.instancesRepo.All(ctx)
 	if err != nil {
 		return errors.Wrap(err, "cannot check services for status")
 	}
 	for _, item := range items {
-		status, err := s.GetStatus(ctx, item.Name)
+		if s.HealthCheck(item.Name) != "" {
+			continue // metrics are set by checker
+		}
+		status, err := s.ping(ctx, item.Name)
+		if status {
+			metrics.InstanceUp.WithLabelValues(item.Name).Set(1)
+		} else {
+			metrics.InstanceUp.WithLabelValues(item.Name).Set(0)
+		}
+
+		s.mu.Lock()
+		s.cache[item.Folder] = status
 		if err != nil {
-			s.cache[item.Folder] = status
 			s.errors[item.Folder] = err.Error()
 		} else {
-			s.cache[item.Folder] = status // only read here (no need for mutex)
 			s.errors[item.Folder] = ""
 		}
+		s.mu.Unlock()
 	}
 	return nil
 }
 
 func (s *StatusService) GetStatus(ctx context.Context, instanceName string) (bool, error) {
 
-	if cache, ok := s.cache[instanceName]; ok {
+	// Result of active health check
+	if state, ok := s.health.Get(instanceName); ok {
+		if !state.Healthy {
+			return false, errors.New(state.Error)
+		}
+		return true, nil
+	}
+
+	s.mu.RLock()
+	cache, ok := s.cache[instanceName]
+	s.mu.RUnlock()
+	if ok {
 		return cache, nil
 	}
+	return s.ping(ctx, instanceName)
+}
 
+// ping calls legacy StatusService.Ping of instance
+func (s *StatusService) ping(ctx context.Context, instanceName string) (bool, error) {
 	callOptions := ProtoCall{
-		Instance: instanceName,
-		Service:  "StatusService",
-		Method:   "Ping",
+		Instance:          instanceName,
+		Service:           "StatusService",
+		Method:            "Ping",
+		IgnoreHealthCheck: true,
 	}
 
 	response := &core.StatusResponse{}
@@ -73,3 +119,113 @@ func (s *StatusService) GetStatus(ctx context.Context, instanceName string) (boo
 	}
 	return true, nil
 }
+
+// HealthCheck returns protocol of instance`s active health check ("" - no check)
+func (s *StatusService) HealthCheck(instanceName string) string {
+	s.checkersMu.Lock()
+	defer s.checkersMu.Unlock()
+	if checker, ok := s.checkers[instanceName]; ok {
+		return checker.config.Protocol
+	}
+	return ""
+}
+
+// Watch syncs active health checks with instances table every health.sync_interval until ctx is done
+func (s *StatusService) Watch(ctx context.Context) {
+	ticker := time.NewTicker(durationOr(viper.GetDuration("health.sync_interval"), 30*time.Second))
+	defer ticker.Stop()
+	for {
+		if err := s.Sync(ctx); err != nil {
+			s.log.ErrorWrap(err, "cannot sync health checks")
+		}
+		select {
+		case <-ctx.Done():
+			s.stopCheckers()
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Sync starts, restarts (config is changed) and stops active health checks of instances
+func (s *StatusService) Sync(ctx context.Context) error {
+	items, err := s.instancesRepo.All(ctx)
+	if err != nil {
+		return errors.Wrap(err, "cannot get instances for health checks")
+	}
+
+	// Checkers are stopped after lock is released, stop waits for running check
+	var stopped []*healthChecker
+	var removed []string
+	defer func() {
+		for _, checker := range stopped {
+			checker.stop()
+		}
+		for _, name := range removed {
+			s.health.remove(name)
+			s.log.Info("health check of %s is stopped", name)
+		}
+	}()
+
+	s.checkersMu.Lock()
+	defer s.checkersMu.Unlock()
+
+	configured := map[string]bool{}
+	for _, item := range items {
+		if item.HealthCheck == nil || !item.IsActive {
+			continue
+		}
+		if err = ValidateHealthCheck(item.HealthCheck); err != nil {
+			s.log.WarnWrap(err, "health check of %s is skipped", item.Name)
+			continue
+		}
+		configured[item.Name] = true
+
+		config := healthCheckConfig(*item.HealthCheck)
+		if checker, ok := s.checkers[item.Name]; ok {
+			if reflect.DeepEqual(checker.config, config) {
+				continue
+			}
+			stopped = append(stopped, checker)
+		}
+		s.checkers[item.Name] = s.newChecker(item.Name, config)
+		s.checkers[item.Name].start()
+		s.log.Info("health check of %s is started (%s)", item.Name, config.Protocol)
+	}
+
+	for name, checker := range s.checkers {
+		if !configured[name] {
+			stopped = append(stopped, checker)
+			removed = append(removed, name)
+			delete(s.checkers, name)
+		}
+	}
+	return nil
+}
+
+func (s *StatusService) newChecker(instanceName string, config domain.InstanceHealthCheck) *healthChecker {
+	return &healthChecker{
+		log:      s.log,
+		instance: instanceName,
+		config:   config,
+		health:   s.health,
+		conn: func(ctx context.Context) (*grpc.ClientConn, error) {
+			return s.endpointService.GetConn(ctx, instanceName)
+		},
+		ping: func(ctx context.Context) error {
+			_, err := s.ping(ctx, instanceName)
+			return err
+		},
+	}
+}
+
+func (s *StatusService) stopCheckers() {
+	s.checkersMu.Lock()
+	checkers := s.checkers
+	s.checkers = map[string]*healthChecker{}
+	s.checkersMu.Unlock()
+
+	for _, checker := range checkers {
+		checker.stop()
+	}
+}
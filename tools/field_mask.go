@@ -34,3 +34,71 @@ func (m *FieldMask) Extract(x interface{}) ([]interface{}, error) {
 	}
 	return r, nil
 }
+
+func (m *FieldMask) Paths() []string {
+	return m.paths
+}
+
+func (m *FieldMask) IsEmpty() bool {
+	return len(m.paths) == 0
+}
+
+// Filter keeps only mask`s dotted paths of decoded JSON object,
+// arrays of objects are filtered item by item
+func (m *FieldMask) Filter(obj map[string]interface{}) map[string]interface{} {
+	tree := fieldMaskTree{}
+	for _, path := range m.paths {
+		node := tree
+		parts := strings.Split(path, ".")
+		for i, part := range parts {
+			next, ok := node[part]
+			if i == len(parts)-1 {
+				// Whole field is kept
+				node[part] = nil
+				break
+			}
+			if ok && next == nil {
+				break // parent is already kept whole
+			}
+			if next == nil {
+				next = fieldMaskTree{}
+				node[part] = next
+			}
+			node = next
+		}
+	}
+	return tree.filter(obj)
+}
+
+// fieldMaskTree is parsed paths, nil node keeps field whole
+type fieldMaskTree map[string]fieldMaskTree
+
+func (t fieldMaskTree) filter(obj map[string]interface{}) map[string]interface{} {
+	res := make(map[string]interface{}, len(t))
+	for name, sub := range t {
+		v, ok := obj[name]
+		if !ok {
+			continue
+		}
+		if sub != nil {
+			v = sub.filterValue(v)
+		}
+		res[name] = v
+	}
+	return res
+}
+
+func (t fieldMaskTree) filterValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		return t.filter(v)
+	case []interface{}:
+		items := make([]interface{}, len(v))
+		for i, item := range v {
+			items[i] = t.filterValue(item)
+		}
+		return items
+	default:
+		return v
+	}
+}
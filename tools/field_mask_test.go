@@ -0,0 +1,29 @@
+package tools
+
+import (
+	"encoding/json"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestFieldMaskFilter(t *testing.T) {
+	var obj map[string]interface{}
+	err := json.Unmarshal([]byte(`{
+		"status": {"code": "success"},
+		"user": {"id": 1, "name": "a", "email": "b"},
+		"items": [{"id": 1, "title": "x"}, {"id": 2, "title": "y"}],
+		"total": 2
+	}`), &obj)
+	require.NoError(t, err)
+
+	mask := NewFieldMask("status", "user.name", "items.id", "user.missing")
+	data, err := json.Marshal(mask.Filter(obj))
+	require.NoError(t, err)
+	require.Equal(t, `{"items":[{"id":1},{"id":2}],"status":{"code":"success"},"user":{"name":"a"}}`, string(data))
+
+	// Parent path keeps whole object
+	mask = NewFieldMask("user.name", "user")
+	data, err = json.Marshal(mask.Filter(obj))
+	require.NoError(t, err)
+	require.Equal(t, `{"user":{"email":"b","id":1,"name":"a"}}`, string(data))
+}
@@ -0,0 +1,61 @@
+package tools
+
+import "strings"
+
+// PathTemplate is parsed template like /challenges/{id}
+type PathTemplate struct {
+	parts []pathPart
+}
+
+type pathPart struct {
+	literal string
+	capture string // name of {capture}, empty for literal part
+}
+
+// ParsePathTemplate splits template into literal and captured segments
+func ParsePathTemplate(template string) *PathTemplate {
+	split := strings.Split(strings.Trim(template, "/"), "/")
+	t := &PathTemplate{parts: make([]pathPart, len(split))}
+	for i, part := range split {
+		if strings.HasPrefix(part, "{") && strings.HasSuffix(part, "}") {
+			t.parts[i].capture = part[1 : len(part)-1]
+			continue
+		}
+		t.parts[i].literal = part
+	}
+	return t
+}
+
+// Match matches address with template and returns captured segments
+func (t *PathTemplate) Match(address string) (map[string]string, bool) {
+	aParts := strings.Split(strings.Trim(address, "/"), "/")
+	if len(t.parts) != len(aParts) {
+		return nil, false
+	}
+
+	captures := make(map[string]string)
+	for i, part := range t.parts {
+		if part.capture != "" {
+			if aParts[i] == "" {
+				return nil, false
+			}
+			captures[part.capture] = aParts[i]
+			continue
+		}
+		if part.literal != aParts[i] {
+			return nil, false
+		}
+	}
+	return captures, true
+}
+
+// MatchPath matches address with template like /challenges/{id}
+// and returns captured segments
+func MatchPath(template, address string) (map[string]string, bool) {
+	return ParsePathTemplate(template).Match(address)
+}
+
+// IsPathTemplate checks if address has captures
+func IsPathTemplate(address string) bool {
+	return strings.Contains(address, "{")
+}